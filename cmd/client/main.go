@@ -2,12 +2,13 @@
 // Labo 2 SDR
 
 // Package main est le point d'entrée du programme permettant de démarrer le client.
-// Il gère aussi un flag number qui permet de choisir le serveur auquel se connecter.
-// Si le flag est omis, le client se connecte à un serveur au hasard présent dans le fichier de configuration.
+// Il gère aussi un flag number qui permet de choisir le serveur auquel se connecter, un flag text qui permet
+// d'utiliser le protocole texte des anciennes versions du serveur et un flag config qui indique le fichier de
+// configuration lu au démarrage.
+// Si le flag number est omis, le client se connecte à un serveur au hasard présent dans le fichier de configuration.
 package main
 
 import (
-	_ "embed"
 	"flag"
 	"log"
 	"math/rand"
@@ -18,30 +19,40 @@ import (
 	"github.com/Lazzzer/labo1-sdr/internal/utils/types"
 )
 
-//go:embed config.json
-var config string
+// defaultConfigPath est le fichier de configuration lu si ni le flag config ni la variable d'environnement configEnv ne
+// sont renseignés, relatif au dossier courant.
+const defaultConfigPath = "cmd/client/config.json"
+
+// configEnv est la variable d'environnement indiquant le fichier de configuration lorsque le flag config n'est pas
+// renseigné.
+const configEnv = "SDR_CLIENT_CONFIG"
 
 // main est la méthode d'entrée du programme
 func main() {
 	number := flag.Int("number", -1, "Integer: Number of the server to connect to, Default is -1")
+	text := flag.Bool("text", false, "Boolean: Use the text protocol instead of the framed protocol, Default is false")
+	configFlag := flag.String("config", "", "String: Path of the config file, Default is $"+configEnv+" or "+defaultConfigPath)
 	flag.Parse()
 
 	if flag.Arg(0) == "" {
-		log.Fatal("Invalid argument, usage: -number=1 <client name>")
+		log.Fatal("Invalid argument, usage: -number=1 -config=<path> <client name>")
 	}
 
-	config := utils.GetConfig[types.Config](config)
+	config, err := utils.LoadConfig[types.Config](utils.ResolvePath(*configFlag, configEnv, defaultConfigPath))
+	if err != nil {
+		log.Fatal(err)
+	}
 
 	if *number == -1 {
 		rand.Seed(time.Now().UnixNano())
-		randomPos := rand.Intn(len(config.Servers) + 1)
-		config.Address = config.Servers[randomPos]
+		numbers := utils.MapKeysToArray(config.Servers)
+		config.Address = config.Servers[numbers[rand.Intn(len(numbers))]]
 	} else if address, ok := config.Servers[*number]; ok {
 		config.Address = address
 	} else {
 		log.Fatal("Invalid server number")
 	}
 
-	cl := client.Client{Name: flag.Arg(0), Config: config}
+	cl := client.Client{Name: flag.Arg(0), Config: config, Text: *text}
 	cl.Run()
 }
@@ -2,11 +2,11 @@
 // Labo 2 SDR
 
 // Package main est le point d'entrée du programme permettant de démarrer le serveur.
-// Il gère aussi les flags du serveur pour le lancer en mode "debug" ou em mode "silent".
+// Il gère aussi les flags du serveur pour le lancer en mode "debug" ou em mode "silent", ainsi que les flags config et
+// entities qui indiquent les fichiers de configuration et des entités lus au démarrage.
 package main
 
 import (
-	_ "embed"
 	"flag"
 	"log"
 	"strconv"
@@ -17,27 +17,46 @@ import (
 	"github.com/Lazzzer/labo1-sdr/internal/utils/types"
 )
 
-//go:embed config.json
-var config string
+// Fichiers lus au démarrage si ni leur flag ni leur variable d'environnement ne sont renseignés, relatifs au dossier
+// courant.
+const (
+	defaultConfigPath   = "cmd/server/config.json"
+	defaultEntitiesPath = "internal/server/entities.json"
+)
+
+// Variables d'environnement indiquant les fichiers lus au démarrage lorsque leur flag n'est pas renseigné.
+const (
+	configEnv   = "SDR_SERVER_CONFIG"
+	entitiesEnv = "SDR_ENTITIES"
+)
 
 // main est la méthode d'entrée du programme
 func main() {
 
 	debug := flag.Bool("debug", false, "Boolean: Run server in debug mode. Default is false")
 	silent := flag.Bool("silent", false, "Boolean: Run server in silent mode. Default is false")
+	configFlag := flag.String("config", "", "String: Path of the config file. Default is $"+configEnv+" or "+defaultConfigPath)
+	entitiesFlag := flag.String("entities", "", "String: Path of the entities file. Default is $"+entitiesEnv+" or "+defaultEntitiesPath)
 
 	flag.Parse()
 
 	if flag.Arg(0) == "" {
-		log.Fatal("Invalid argument, usage: -debug -silent <server number>")
+		log.Fatal("Invalid argument, usage: -debug -silent -config=<path> -entities=<path> <server number>")
 	}
 
 	number, err := strconv.Atoi(flag.Arg(0))
 	if err != nil {
-		log.Fatal("Invalid argument, usage: -debug -silent <server number>")
+		log.Fatal("Invalid argument, usage: -debug -silent -config=<path> -entities=<path> <server number>")
 	}
 
-	config := utils.GetConfig[types.ServerConfig](config)
+	config, err := utils.LoadConfig[types.ServerConfig](utils.ResolvePath(*configFlag, configEnv, defaultConfigPath))
+	if err != nil {
+		log.Fatal(err)
+	}
+	entities, err := utils.LoadEntities(utils.ResolvePath(*entitiesFlag, entitiesEnv, defaultEntitiesPath))
+	if err != nil {
+		log.Fatal(err)
+	}
 
 	if address, ok := config.Servers[number]; ok {
 		config.Address = address
@@ -53,6 +72,8 @@ func main() {
 		config.Silent = true
 	}
 
-	serv := server.Server{Number: number, Port: strings.Split(config.Address, ":")[1], ClientPort: config.ClientPorts[number], Config: config}
+	serv := server.NewServer(number, strings.Split(config.Address, ":")[1], config.ClientPorts[number], config, entities)
+	serv.HTTPPort = config.HTTPPorts[number]
+	serv.DataDir = config.DataDirs[number]
 	serv.Run()
 }
@@ -0,0 +1,106 @@
+// Auteurs: Jonathan Friedli, Lazar Pavicevic
+// Labo 2 SDR
+
+// Package main est le point d'entrée du programme permettant d'analyser hors ligne un snapshot global.
+// Il charge les fichiers écrits par chaque serveur pour un même snapshot et affiche un résumé de la coupe cohérente :
+// l'état de chaque serveur, les communications en transit et les manifestations qui diffèrent entre les serveurs.
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strconv"
+
+	"github.com/Lazzzer/labo1-sdr/internal/utils"
+	"github.com/Lazzzer/labo1-sdr/internal/utils/types"
+)
+
+// main est la méthode d'entrée du programme
+func main() {
+	if len(os.Args) < 2 {
+		log.Fatal("Invalid argument, usage: <snapshot file> [<snapshot file>...]")
+	}
+
+	var snapshots []types.Snapshot
+	for _, path := range os.Args[1:] {
+		snapshot, err := utils.GetSnapshot(path)
+		if err != nil {
+			log.Fatal(path + ": " + err.Error())
+		}
+		if len(snapshots) > 0 && snapshot.Id != snapshots[0].Id {
+			log.Fatal(path + ": snapshot " + snapshot.Id + " does not belong to snapshot " + snapshots[0].Id)
+		}
+		snapshots = append(snapshots, snapshot)
+	}
+	sort.Slice(snapshots, func(i, j int) bool { return snapshots[i].Server < snapshots[j].Server })
+
+	fmt.Println(utils.BOLD + "Snapshot " + snapshots[0].Id + utils.RESET + "\n")
+
+	inFlight := 0
+	for _, snapshot := range snapshots {
+		fmt.Println(utils.CYAN + "S" + strconv.Itoa(snapshot.Server) + utils.RESET + " recorded at " + snapshot.Time.Format("15:04:05.000") + ", stamp " + strconv.Itoa(snapshot.Stamp))
+		fmt.Println("  Events: " + strconv.Itoa(len(snapshot.Events)))
+		for _, resource := range utils.MapKeysToArray(snapshot.Mutexes) {
+			fmt.Println("  Mutex " + strconv.Itoa(resource) + ": " + snapshot.Mutexes[resource].Status)
+		}
+		for _, number := range utils.MapKeysToArray(snapshot.Channels) {
+			for _, comm := range snapshot.Channels[number] {
+				fmt.Println("  In transit from S" + strconv.Itoa(number) + ": " + string(comm.Type) + strconv.Itoa(comm.Stamp) + " on resource " + strconv.Itoa(comm.Resource))
+				inFlight++
+			}
+		}
+	}
+
+	fmt.Println("\nCommunications in transit: " + strconv.Itoa(inFlight))
+	if diverging := divergingEvents(snapshots); len(diverging) == 0 {
+		fmt.Println(utils.GREEN + "Events are identical on all servers" + utils.RESET)
+	} else {
+		fmt.Println(utils.ORANGE + "Events differing between servers: " + fmt.Sprint(diverging) + utils.RESET)
+	}
+}
+
+// divergingEvents retourne les ids des manifestations dont la version diffère entre les serveurs, ce qui est attendu
+// lorsque les opérations qui les modifient sont en transit.
+func divergingEvents(snapshots []types.Snapshot) []int {
+	ids := make(map[int]bool)
+	for _, snapshot := range snapshots {
+		for id := range snapshot.Events {
+			ids[id] = true
+		}
+	}
+
+	var diverging []int
+	for _, id := range utils.MapKeysToArray(ids) {
+		event, ok := snapshots[0].Events[id]
+		for _, snapshot := range snapshots[1:] {
+			other, otherOk := snapshot.Events[id]
+			if ok != otherOk || !sameEvent(event, other) {
+				diverging = append(diverging, id)
+				break
+			}
+		}
+	}
+	return diverging
+}
+
+// sameEvent indique si deux versions d'une manifestation sont identiques, y compris les bénévoles de chaque job.
+func sameEvent(a, b types.Event) bool {
+	if a.Name != b.Name || a.Closed != b.Closed || a.CreatorId != b.CreatorId || len(a.Jobs) != len(b.Jobs) {
+		return false
+	}
+
+	for id, job := range a.Jobs {
+		other, ok := b.Jobs[id]
+		if !ok || job.Name != other.Name || job.NbVolunteers != other.NbVolunteers || len(job.VolunteerIds) != len(other.VolunteerIds) {
+			return false
+		}
+		for i, volunteer := range job.VolunteerIds {
+			if volunteer != other.VolunteerIds[i] {
+				return false
+			}
+		}
+	}
+	return true
+}
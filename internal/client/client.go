@@ -10,6 +10,10 @@
 // Les commandes protégées par des credentials activent un prompt pour y passer ses identifiants.
 // Les commandes qui n'existent pas ou contenant des typos (par exemple: "shutdownServer" ou "helpp") ne sont même pas envoyées au serveur.
 // Un CTRL+C signale quand même au serveur que le client se déconnecte et le client se termine "gracefully".
+// Le client utilise le protocole framed, qui délimite chaque réponse du serveur, sauf si le protocole texte est demandé.
+// Les notifications des manifestations suivies avec la commande "watch" sont affichées dès leur réception, sauf pendant
+// la saisie des identifiants où elles attendent la fin du prompt. Lorsque l'entrée standard est un terminal, la saisie
+// est gérée par le client qui réaffiche la ligne en cours de saisie après chaque notification.
 package client
 
 import (
@@ -21,6 +25,7 @@ import (
 	"os"
 	"os/signal"
 	"strings"
+	"sync"
 	"syscall"
 
 	"github.com/Lazzzer/labo1-sdr/internal/utils"
@@ -32,12 +37,21 @@ import (
 type Client struct {
 	Name   string       // Nom du client
 	Config types.Config // Configuration du client
+	Text   bool         // Utilise le protocole texte au lieu du protocole framed
+
+	mu         sync.Mutex // Protège l'envoi des commandes, le CTRL+C pouvant envoyer "quit" pendant une autre commande
+	nbRequests uint32     // Nombre de requêtes envoyées avec le protocole framed
+	prompt     sync.Mutex // Bloqué pendant la saisie des identifiants pour ne pas y afficher de notification
+
+	term    *term.Terminal // Gère la saisie lorsque l'entrée standard est un terminal, nil sinon
+	restore func()         // Restaure le mode initial du terminal, nil si la saisie n'est pas gérée par le client
 }
 
 // Run lance le client et se connecte à un serveur.
 //
 // Les réponses du serveur et le CTRL+C sont gérés par des goroutines.
 // Lorsqu'un utilisateur souhaite quitter l'application, le client envoie quoiqu'il arrive un message au serveur pour fermer la connexion.
+// En mode raw, le CTRL+C n'est pas un signal et termine la saisie comme la fin de l'entrée standard.
 func (c *Client) Run() {
 
 	intChan := make(chan os.Signal, 1) // Catch du CTRL+C
@@ -51,10 +65,19 @@ func (c *Client) Run() {
 		fmt.Println(utils.MESSAGE.Title)
 	}
 
-	_, err = conn.Write([]byte(c.Name + "\n"))
+	hello := c.Name
+	if !c.Text {
+		hello += " " + utils.FRAMED_PROTOCOL
+	}
+	_, err = conn.Write([]byte(hello + "\n"))
 	if err != nil {
 		log.Fatal("❌ " + utils.RED + "Could not send name to the server." + utils.RESET)
 	}
+	if !c.Text {
+		if ack, err := utils.ReadFrame(conn); err != nil || ack.Body != utils.FRAMED_PROTOCOL {
+			log.Fatal("❌ " + utils.RED + "The server does not support the framed protocol, use the text protocol instead." + utils.RESET)
+		}
+	}
 
 	defer func(conn net.Conn) {
 		err := conn.Close()
@@ -63,52 +86,162 @@ func (c *Client) Run() {
 		}
 	}(conn)
 
+	c.openTerminal()
+	defer c.closeTerminal()
+
 	go func() {
 		<-intChan
-		_, err = conn.Write([]byte("quit\n"))
+		err := c.send(conn, utils.QUIT.Name)
 		if err != nil {
 			log.Println(err)
 		}
-		fmt.Println(utils.MESSAGE.Goodbye)
-		os.Exit(0)
+		fmt.Fprintln(c.output(), utils.MESSAGE.Goodbye)
+		c.exit()
 	}()
 
-	go func() {
-		_, errFrom := io.Copy(os.Stdout, conn) // Lecture des réponses du serveur
-		if errFrom != nil {
-			os.Exit(0)
-		}
-	}()
+	go c.readResponses(conn) // Lecture des réponses du serveur
 
 	reader := bufio.NewReader(os.Stdin)
 	for {
-		input, _ := reader.ReadString('\n')
+		input, err := c.readLine(reader)
+		if err == io.EOF {
+			// Termine la ligne interrompue par le CTRL+C sans la réafficher
+			if c.term != nil {
+				fmt.Print("\r\n")
+			}
+			input = utils.QUIT.Name
+		}
 		processedInput, err := c.processInput(input)
 
 		if err != nil {
 			if err.Error() == "invalid input" {
-				fmt.Print(utils.MESSAGE.Error.InvalidCommand)
+				fmt.Fprint(c.output(), utils.MESSAGE.Error.InvalidCommand)
 			}
 			continue
 		}
 
-		_, err = io.Copy(conn, strings.NewReader(processedInput+"\n")) // Passage de l'input traité au serveur
+		err = c.send(conn, processedInput) // Passage de l'input traité au serveur
 		if err != nil {
 			log.Println(err)
 		}
 
 		if processedInput == utils.QUIT.Name {
+			c.closeTerminal()
 			fmt.Println(utils.MESSAGE.Goodbye)
 			break
 		}
 	}
 }
 
+// openTerminal passe l'entrée standard en mode raw et confie la saisie à un terminal lorsque l'entrée standard est un
+// terminal. Le terminal efface la ligne en cours de saisie avant chaque affichage puis la réaffiche.
+func (c *Client) openTerminal() {
+	fd := int(os.Stdin.Fd())
+	if !term.IsTerminal(fd) {
+		return
+	}
+	state, err := term.MakeRaw(fd)
+	if err != nil {
+		return
+	}
+
+	c.term = term.NewTerminal(struct {
+		io.Reader
+		io.Writer
+	}{os.Stdin, os.Stdout}, "")
+	c.restore = func() {
+		if err := term.Restore(fd, state); err != nil {
+			log.Println(err)
+		}
+	}
+}
+
+// closeTerminal restaure le mode initial du terminal si la saisie était gérée par le client.
+func (c *Client) closeTerminal() {
+	if c.restore != nil {
+		c.restore()
+	}
+}
+
+// exit restaure le terminal et termine le client.
+func (c *Client) exit() {
+	c.closeTerminal()
+	os.Exit(0)
+}
+
+// output retourne la sortie sur laquelle le client affiche les réponses et les messages.
+func (c *Client) output() io.Writer {
+	if c.term != nil {
+		return c.term
+	}
+	return os.Stdout
+}
+
+// readLine attend la prochaine ligne saisie par l'utilisateur, depuis le terminal s'il est géré par le client.
+func (c *Client) readLine(reader *bufio.Reader) (string, error) {
+	if c.term != nil {
+		return c.term.ReadLine()
+	}
+	return reader.ReadString('\n')
+}
+
+// send envoie une commande au serveur avec le protocole du client. Les requêtes du protocole framed sont numérotées dans
+// l'ordre de leur envoi.
+func (c *Client) send(conn net.Conn, input string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.Text {
+		_, err := io.Copy(conn, strings.NewReader(input+"\n"))
+		return err
+	}
+	c.nbRequests++
+	return utils.WriteFrame(conn, types.Frame{Id: c.nbRequests, Body: input})
+}
+
+// readResponses affiche les réponses du serveur et termine le client lorsque la connexion est fermée.
+func (c *Client) readResponses(conn net.Conn) {
+	if c.Text {
+		_, errFrom := io.Copy(c.output(), conn)
+		if errFrom != nil {
+			c.exit()
+		}
+		return
+	}
+
+	for {
+		frame, err := utils.ReadFrame(conn)
+		if err != nil || frame.Status == types.StatusClosed {
+			c.exit()
+		}
+		if frame.Status == types.StatusNotification {
+			// Le terminal efface et réaffiche lui-même la ligne en cours de saisie. Sans terminal, la ligne est seulement
+			// effacée, la saisie n'étant pas connue du client.
+			c.prompt.Lock()
+			if c.term != nil {
+				fmt.Fprint(c.term, frame.Body)
+			} else {
+				fmt.Print("\r\033[K" + frame.Body)
+			}
+			c.prompt.Unlock()
+			continue
+		}
+		fmt.Fprint(c.output(), frame.Body)
+	}
+}
+
 // askCredentials crée un prompt et attend l'input de l'utilisateur pour son username et son password.
 // L'insertion du password est en mode sans echo.
 func (c *Client) askCredentials() (string, error) {
-	fmt.Println(utils.MESSAGE.LoginStart)
-	defer fmt.Println(utils.MESSAGE.LoginEnd)
+	c.prompt.Lock()
+	defer c.prompt.Unlock()
+
+	fmt.Fprintln(c.output(), utils.MESSAGE.LoginStart)
+	defer fmt.Fprintln(c.output(), utils.MESSAGE.LoginEnd)
+
+	if c.term != nil {
+		return c.askTerminalCredentials()
+	}
 
 	fmt.Print(utils.BOLD + "Enter Username: " + utils.RESET)
 	username, errUsername := bufio.NewReader(os.Stdin).ReadString('\n')
@@ -129,6 +262,25 @@ func (c *Client) askCredentials() (string, error) {
 	return username + " " + string(bytePassword), nil
 }
 
+// askTerminalCredentials demande le username et le password à travers le terminal géré par le client.
+func (c *Client) askTerminalCredentials() (string, error) {
+	c.term.SetPrompt(utils.BOLD + "Enter Username: " + utils.RESET)
+	username, errUsername := c.term.ReadLine()
+	c.term.SetPrompt("")
+	usernameArr := strings.Fields(username)
+
+	if errUsername != nil || len(usernameArr) != 1 {
+		return "", fmt.Errorf("invalid username")
+	}
+
+	password, errPassword := c.term.ReadPassword(utils.BOLD + "Enter Password: " + utils.RESET)
+	if errPassword != nil {
+		return "", errPassword
+	}
+
+	return usernameArr[0] + " " + password, nil
+}
+
 // processInput traite l'input de l'utilisateur et vérifie si l'input peut être mappé à une commande.
 // La méthode vérifie aussi si une authentification est nécessaire et s'il y a une entrée vide.
 func (c *Client) processInput(input string) (string, error) {
@@ -0,0 +1,318 @@
+// Auteurs: Jonathan Friedli, Lazar Pavicevic
+// Labo 2 SDR
+
+package repository
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/Lazzzer/labo1-sdr/internal/utils/types"
+)
+
+// KV est l'implémentation de Repository enregistrant les entités dans un fichier clé-valeur embarqué.
+//
+// Le fichier est un journal auquel chaque modification ajoute une ligne JSON contenant la clé de l'entité modifiée
+// ("user/<id>" ou "event/<id>") et sa nouvelle valeur complète. Seule la position de la dernière valeur de chaque clé
+// est gardée en mémoire, les valeurs étant lues dans le fichier à chaque accès. À l'ouverture, le fichier est parcouru
+// pour reconstruire ces positions et réécrit sans ses anciennes valeurs lorsqu'elles sont plus nombreuses que les
+// valeurs actuelles. Une ligne incomplète à la fin du fichier, écrite pendant un crash, est supprimée.
+type KV struct {
+	path     string                // Chemin du fichier
+	file     *os.File              // Fichier ouvert en lecture et en ajout
+	index    map[string]kvPosition // Position de la dernière valeur de chaque clé dans le fichier
+	size     int64                 // Taille du fichier
+	nbEvents int                   // Nombre de clés de manifestations
+	nbStale  int                   // Nombre de lignes du fichier remplacées par une valeur plus récente
+}
+
+// kvPosition représente la position d'une ligne dans le fichier d'un KV.
+type kvPosition struct {
+	offset int64 // Position du début de la ligne
+	length int   // Longueur de la ligne, sans le retour à la ligne
+}
+
+// kvRecord représente une ligne du fichier d'un KV.
+type kvRecord struct {
+	Key   string          `json:"key"`   // Clé de l'entité
+	Value json.RawMessage `json:"value"` // Valeur JSON de l'entité
+}
+
+const (
+	userPrefix  = "user/"  // Préfixe des clés des utilisateurs
+	eventPrefix = "event/" // Préfixe des clés des manifestations
+)
+
+// OpenKV ouvre le fichier clé-valeur au chemin donné, en le créant si nécessaire. Un fichier vide reçoit les entités
+// données, qui sont ignorées si le fichier contient déjà des entités.
+func OpenKV(path string, entities types.Entities) (*KV, error) {
+	kv := &KV{path: path}
+	if err := kv.open(); err != nil {
+		return nil, err
+	}
+
+	if len(kv.index) == 0 {
+		if err := kv.Replace(entities); err != nil {
+			kv.file.Close()
+			return nil, err
+		}
+	} else if kv.nbStale > len(kv.index) {
+		if err := kv.compact(); err != nil {
+			kv.file.Close()
+			return nil, err
+		}
+	}
+	return kv, nil
+}
+
+// open ouvre le fichier du KV et reconstruit la position de chaque clé.
+func (kv *KV) open() error {
+	file, err := os.OpenFile(kv.path, os.O_CREATE|os.O_RDWR|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+
+	kv.file = file
+	kv.index = make(map[string]kvPosition)
+	kv.size = 0
+	kv.nbEvents = 0
+	kv.nbStale = 0
+
+	reader := bufio.NewReader(file)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if err == io.EOF {
+			if len(line) > 0 {
+				// La ligne incomplète est supprimée pour que la prochaine valeur commence sur une nouvelle ligne
+				err = file.Truncate(kv.size)
+			} else {
+				err = nil
+			}
+			if err != nil {
+				file.Close()
+			}
+			return err
+		}
+		if err != nil {
+			file.Close()
+			return err
+		}
+
+		var record kvRecord
+		if err := json.Unmarshal(line, &record); err != nil {
+			file.Close()
+			return errors.New("corrupted record at offset " + strconv.FormatInt(kv.size, 10) + " in " + kv.path + ": " + err.Error())
+		}
+		kv.track(record.Key, kvPosition{offset: kv.size, length: len(line) - 1})
+		kv.size += int64(len(line))
+	}
+}
+
+// track enregistre la position de la dernière valeur d'une clé.
+func (kv *KV) track(key string, position kvPosition) {
+	if _, ok := kv.index[key]; ok {
+		kv.nbStale++
+	} else if strings.HasPrefix(key, eventPrefix) {
+		kv.nbEvents++
+	}
+	kv.index[key] = position
+}
+
+// get lit la dernière valeur d'une clé et la décode dans value. La méthode retourne false si la clé n'existe pas ou si
+// sa valeur ne peut pas être lue.
+func (kv *KV) get(key string, value any) bool {
+	position, ok := kv.index[key]
+	if !ok {
+		return false
+	}
+
+	line := make([]byte, position.length)
+	if _, err := kv.file.ReadAt(line, position.offset); err != nil {
+		return false
+	}
+	var record kvRecord
+	if err := json.Unmarshal(line, &record); err != nil {
+		return false
+	}
+	return json.Unmarshal(record.Value, value) == nil
+}
+
+// put ajoute la nouvelle valeur d'une clé à la fin du fichier et attend qu'elle soit écrite sur le disque.
+func (kv *KV) put(key string, value any) error {
+	content, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	line, err := json.Marshal(kvRecord{Key: key, Value: content})
+	if err != nil {
+		return err
+	}
+
+	if _, err := kv.file.Write(append(line, '\n')); err != nil {
+		return err
+	}
+	if err := kv.file.Sync(); err != nil {
+		return err
+	}
+	kv.track(key, kvPosition{offset: kv.size, length: len(line)})
+	kv.size += int64(len(line)) + 1
+	return nil
+}
+
+// ids retourne les ids des clés ayant le préfixe donné.
+func (kv *KV) ids(prefix string) []int {
+	var ids []int
+	for key := range kv.index {
+		if !strings.HasPrefix(key, prefix) {
+			continue
+		}
+		if id, err := strconv.Atoi(strings.TrimPrefix(key, prefix)); err == nil {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+// User retourne l'utilisateur ayant l'id donné et un booléen indiquant sa présence.
+func (kv *KV) User(id int) (types.User, bool) {
+	var user types.User
+	ok := kv.get(userPrefix+strconv.Itoa(id), &user)
+	return user, ok
+}
+
+// Users retourne tous les utilisateurs lus dans le fichier.
+func (kv *KV) Users() map[int]types.User {
+	users := make(map[int]types.User)
+	for _, id := range kv.ids(userPrefix) {
+		if user, ok := kv.User(id); ok {
+			users[id] = user
+		}
+	}
+	return users
+}
+
+// Event retourne la manifestation ayant l'id donné et un booléen indiquant sa présence.
+func (kv *KV) Event(id int) (types.Event, bool) {
+	var event types.Event
+	ok := kv.get(eventPrefix+strconv.Itoa(id), &event)
+	return event, ok
+}
+
+// Events retourne toutes les manifestations lues dans le fichier.
+func (kv *KV) Events() map[int]types.Event {
+	events := make(map[int]types.Event, kv.nbEvents)
+	for _, id := range kv.ids(eventPrefix) {
+		if event, ok := kv.Event(id); ok {
+			events[id] = event
+		}
+	}
+	return events
+}
+
+// NbEvents retourne le nombre de manifestations.
+func (kv *KV) NbEvents() int {
+	return kv.nbEvents
+}
+
+// CreateEvent ajoute une manifestation, si aucune manifestation n'a déjà son id.
+func (kv *KV) CreateEvent(id int, event types.Event) error {
+	if _, ok := kv.index[eventPrefix+strconv.Itoa(id)]; ok {
+		return ErrEventExists
+	}
+	return kv.SaveEvent(id, event)
+}
+
+// UpdateJob remplace un job d'une manifestation en ajoutant la nouvelle version de la manifestation au fichier.
+func (kv *KV) UpdateJob(idEvent, idJob int, job types.Job) error {
+	event, ok := kv.Event(idEvent)
+	if !ok {
+		return ErrEventNotFound
+	}
+	if _, ok := event.Jobs[idJob]; !ok {
+		return ErrJobNotFound
+	}
+	event.Jobs[idJob] = job
+	return kv.SaveEvent(idEvent, event)
+}
+
+// CloseEvent ferme une manifestation en ajoutant la nouvelle version de la manifestation au fichier.
+func (kv *KV) CloseEvent(idEvent int) error {
+	event, ok := kv.Event(idEvent)
+	if !ok {
+		return ErrEventNotFound
+	}
+	event.Closed = true
+	return kv.SaveEvent(idEvent, event)
+}
+
+// SaveEvent ajoute ou remplace une manifestation.
+func (kv *KV) SaveEvent(id int, event types.Event) error {
+	return kv.put(eventPrefix+strconv.Itoa(id), event)
+}
+
+// Replace réécrit le fichier avec les entités données. Le nouveau fichier est écrit dans un fichier temporaire renommé
+// une fois complet, un crash pendant l'écriture laissant ainsi l'ancien fichier intact.
+func (kv *KV) Replace(entities types.Entities) error {
+	tmp := kv.path + ".tmp"
+	file, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+
+	writer := bufio.NewWriter(file)
+	write := func(key string, value any) error {
+		content, err := json.Marshal(value)
+		if err != nil {
+			return err
+		}
+		line, err := json.Marshal(kvRecord{Key: key, Value: content})
+		if err != nil {
+			return err
+		}
+		_, err = writer.Write(append(line, '\n'))
+		return err
+	}
+	for id, user := range entities.Users {
+		if err = write(userPrefix+strconv.Itoa(id), user); err != nil {
+			break
+		}
+	}
+	for id, event := range entities.Events {
+		if err != nil {
+			break
+		}
+		err = write(eventPrefix+strconv.Itoa(id), event)
+	}
+	if err == nil {
+		err = writer.Flush()
+	}
+	if err == nil {
+		err = file.Sync()
+	}
+	file.Close()
+	if err != nil {
+		os.Remove(tmp)
+		return err
+	}
+
+	if err := os.Rename(tmp, kv.path); err != nil {
+		return err
+	}
+	kv.file.Close()
+	return kv.open()
+}
+
+// compact réécrit le fichier avec uniquement la dernière valeur de chaque clé.
+func (kv *KV) compact() error {
+	return kv.Replace(types.Entities{Users: kv.Users(), Events: kv.Events()})
+}
+
+// Close ferme le fichier.
+func (kv *KV) Close() error {
+	return kv.file.Close()
+}
@@ -0,0 +1,119 @@
+// Auteurs: Jonathan Friedli, Lazar Pavicevic
+// Labo 2 SDR
+
+package repository
+
+import (
+	"github.com/Lazzzer/labo1-sdr/internal/utils/types"
+)
+
+// Memory est l'implémentation de Repository conservant les entités dans des maps. Les entités sont perdues à l'arrêt
+// du serveur.
+type Memory struct {
+	users  map[int]types.User  // Utilisateurs pouvant s'authentifier
+	events map[int]types.Event // Manifestations
+}
+
+// NewMemory crée un Repository en mémoire contenant les entités données. Les entités ne doivent pas être partagées avec
+// un autre Repository.
+func NewMemory(entities types.Entities) *Memory {
+	m := &Memory{}
+	m.Replace(entities)
+	return m
+}
+
+// User retourne l'utilisateur ayant l'id donné et un booléen indiquant sa présence.
+func (m *Memory) User(id int) (types.User, bool) {
+	user, ok := m.users[id]
+	return user, ok
+}
+
+// Users retourne une copie de la map des utilisateurs.
+func (m *Memory) Users() map[int]types.User {
+	users := make(map[int]types.User, len(m.users))
+	for id, user := range m.users {
+		users[id] = user
+	}
+	return users
+}
+
+// Event retourne la manifestation ayant l'id donné et un booléen indiquant sa présence.
+func (m *Memory) Event(id int) (types.Event, bool) {
+	event, ok := m.events[id]
+	return event, ok
+}
+
+// Events retourne une copie de la map des manifestations.
+func (m *Memory) Events() map[int]types.Event {
+	events := make(map[int]types.Event, len(m.events))
+	for id, event := range m.events {
+		events[id] = event
+	}
+	return events
+}
+
+// NbEvents retourne le nombre de manifestations.
+func (m *Memory) NbEvents() int {
+	return len(m.events)
+}
+
+// CreateEvent ajoute une manifestation, si aucune manifestation n'a déjà son id.
+func (m *Memory) CreateEvent(id int, event types.Event) error {
+	if _, ok := m.events[id]; ok {
+		return ErrEventExists
+	}
+	m.events[id] = copyEvent(event)
+	return nil
+}
+
+// UpdateJob remplace un job dans une copie des jobs de la manifestation, les versions précédentes retournées par Event
+// restant ainsi inchangées.
+func (m *Memory) UpdateJob(idEvent, idJob int, job types.Job) error {
+	event, ok := m.events[idEvent]
+	if !ok {
+		return ErrEventNotFound
+	}
+	if _, ok := event.Jobs[idJob]; !ok {
+		return ErrJobNotFound
+	}
+	event = copyEvent(event)
+	job.VolunteerIds = append([]int{}, job.VolunteerIds...)
+	event.Jobs[idJob] = job
+	m.events[idEvent] = event
+	return nil
+}
+
+// CloseEvent ferme une manifestation.
+func (m *Memory) CloseEvent(idEvent int) error {
+	event, ok := m.events[idEvent]
+	if !ok {
+		return ErrEventNotFound
+	}
+	event.Closed = true
+	m.events[idEvent] = event
+	return nil
+}
+
+// SaveEvent ajoute ou remplace une manifestation.
+func (m *Memory) SaveEvent(id int, event types.Event) error {
+	m.events[id] = copyEvent(event)
+	return nil
+}
+
+// Replace remplace les maps des utilisateurs et des manifestations par des copies des entités données.
+func (m *Memory) Replace(entities types.Entities) error {
+	m.users = make(map[int]types.User, len(entities.Users))
+	for id, user := range entities.Users {
+		m.users[id] = user
+	}
+	m.events = make(map[int]types.Event, len(entities.Events))
+	for id, event := range entities.Events {
+		m.events[id] = copyEvent(event)
+	}
+	return nil
+}
+
+// Close ne fait rien, aucune ressource n'étant utilisée.
+func (m *Memory) Close() error {
+	return nil
+}
@@ -0,0 +1,65 @@
+// Auteurs: Jonathan Friedli, Lazar Pavicevic
+// Labo 2 SDR
+
+// Package repository propose le stockage des utilisateurs et des manifestations d'un serveur.
+//
+// La logique des commandes du serveur accède aux entités uniquement à travers l'interface Repository, ce qui la rend
+// indépendante de leur stockage. Deux implémentations sont disponibles : Memory, qui conserve les entités dans des maps,
+// et KV, qui les enregistre dans un fichier clé-valeur embarqué et les retrouve après un redémarrage.
+package repository
+
+import (
+	"errors"
+
+	"github.com/Lazzzer/labo1-sdr/internal/utils/types"
+)
+
+// Erreurs retournées par les méthodes d'un Repository.
+var (
+	ErrEventNotFound = errors.New("event not found")
+	ErrEventExists   = errors.New("event already exists")
+	ErrJobNotFound   = errors.New("job not found")
+)
+
+// Repository représente le stockage des utilisateurs et des manifestations d'un serveur. Les manifestations retournées
+// sont des copies qui ne doivent pas être modifiées en place, les modifications passant par CreateEvent, UpdateJob,
+// CloseEvent et SaveEvent.
+//
+// Un Repository n'est pas protégé contre les accès concurrents, il est accédé uniquement par la goroutine principale
+// du serveur.
+type Repository interface {
+	User(id int) (types.User, bool)   // Retourne l'utilisateur ayant l'id donné
+	Users() map[int]types.User        // Retourne tous les utilisateurs
+	Event(id int) (types.Event, bool) // Retourne la manifestation ayant l'id donné
+	Events() map[int]types.Event      // Retourne toutes les manifestations
+	NbEvents() int                    // Retourne le nombre de manifestations
+
+	CreateEvent(id int, event types.Event) error       // Ajoute une nouvelle manifestation
+	UpdateJob(idEvent, idJob int, job types.Job) error // Remplace un job d'une manifestation
+	CloseEvent(idEvent int) error                      // Ferme une manifestation
+	SaveEvent(id int, event types.Event) error         // Remplace une manifestation par une version reçue ou restaurée
+
+	Replace(entities types.Entities) error // Remplace tous les utilisateurs et toutes les manifestations
+	Close() error                          // Libère les ressources du stockage
+}
+
+// FindUser retourne l'id de l'utilisateur ayant le nom et le mot de passe donnés et un booléen indiquant sa présence.
+func FindUser(repo Repository, username, password string) (int, bool) {
+	for id, user := range repo.Users() {
+		if user.Username == username && user.Password == password {
+			return id, true
+		}
+	}
+	return 0, false
+}
+
+// copyEvent retourne une copie profonde d'une manifestation, ses jobs et leurs bénévoles étant copiés.
+func copyEvent(event types.Event) types.Event {
+	jobs := make(map[int]types.Job, len(event.Jobs))
+	for jobId, job := range event.Jobs {
+		job.VolunteerIds = append([]int{}, job.VolunteerIds...)
+		jobs[jobId] = job
+	}
+	event.Jobs = jobs
+	return event
+}
@@ -0,0 +1,277 @@
+// Auteurs: Jonathan Friedli, Lazar Pavicevic
+// Labo 2 SDR
+
+package server
+
+import (
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/Lazzzer/labo1-sdr/internal/utils"
+	"github.com/Lazzzer/labo1-sdr/internal/utils/types"
+)
+
+// Election représente un algorithme d'élection d'un leader parmi les serveurs vivants du réseau. Le leader élu n'est
+// consulté que par les clients avec la commande "leader" : le mode Raft élit son propre leader et les algorithmes
+// d'exclusion mutuelle n'utilisent pas de coordinateur.
+//
+// Comme pour Mutex, les méthodes d'une Election sont toujours appelées par la goroutine principale de traitement des
+// communications serveurs-serveurs. Une élection est lancée au démarrage du serveur et chaque fois que le leader est
+// retiré par le détecteur de défaillances, ferme sa connexion ou quitte le réseau. Une élection qui n'aboutit pas dans
+// le délai de suspicion est relancée.
+type Election interface {
+	Start()                                 // Lance une élection
+	HandleMessage(comm types.Communication) // Traite une communication d'élection (ELE, ANS, COO) reçue d'un autre serveur
+	Tick()                                  // Relance l'élection en cours si elle n'a pas abouti dans le délai imparti
+	RemovePeer(number int)                  // Relance une élection si le serveur retiré était le leader
+	Leader() int                            // Retourne le numéro du leader, 0 si aucun leader n'est élu
+}
+
+// newElection retourne l'implémentation d'Election correspondant à l'algorithme choisi dans la configuration du
+// serveur. L'algorithme Bully est utilisé par défaut.
+func newElection(s *Server) Election {
+	switch s.Config.Election {
+	case types.Bully, "":
+		return &bullyElection{s: s}
+	case types.ChangRoberts:
+		return &changRobertsElection{s: s}
+	default:
+		log.Fatal("Unknown election algorithm: " + string(s.Config.Election))
+		return nil
+	}
+}
+
+// leader retourne le numéro du leader élu et un booléen indiquant si une élection a abouti. La méthode doit être
+// appelée par la goroutine principale.
+func (s *Server) leader() (int, bool) {
+	leader := s.election.Leader()
+	return leader, leader != 0
+}
+
+// sendElection envoie une communication d'élection à un ou plusieurs serveurs. Comme les heartbeats, elle n'est pas
+// comptabilisée dans les messages de l'exclusion mutuelle.
+func (s *Server) sendElection(commType types.CommunicationType, to []int, leader int) {
+	s.sendControl(types.Communication{Type: commType, From: s.Number, To: to, Stamp: s.Stamp, Leader: leader})
+}
+
+// electionTimeout retourne le délai après lequel une élection sans réponse est relancée.
+func (s *Server) electionTimeout() time.Duration {
+	return time.Duration(s.Config.SuspicionTimeout) * time.Millisecond
+}
+
+// logLeader affiche le nouveau leader élu.
+func (s *Server) logLeader(leader int) {
+	s.log(types.INFO, utils.GREEN+"Server #"+strconv.Itoa(leader)+" is the leader"+utils.RESET)
+}
+
+// bullyElection est l'implémentation d'Election utilisant l'algorithme Bully.
+//
+// Un serveur qui lance une élection envoie un ELE à tous les serveurs ayant un numéro plus grand que le sien. Chacun
+// d'eux lui répond par un ANS et lance sa propre élection. Un serveur qui ne reçoit aucune réponse dans le délai imparti
+// devient le leader et l'annonce à tous les autres serveurs avec un COO. Le leader est ainsi toujours le serveur vivant
+// ayant le plus grand numéro.
+type bullyElection struct {
+	s        *Server   // Serveur participant à l'élection
+	leader   int       // Numéro du leader, 0 si aucun leader n'est élu
+	electing bool      // Indique si le serveur a une élection en cours
+	answered bool      // Indique si un serveur plus grand a répondu à l'élection en cours
+	deadline time.Time // Date limite de réception d'un ANS, ou d'un COO si un serveur plus grand a répondu
+}
+
+// Start envoie un ELE aux serveurs plus grands ou annonce directement que le serveur est le leader s'il n'y en a aucun.
+func (b *bullyElection) Start() {
+	b.leader = 0
+
+	var higher []int
+	for _, number := range b.s.peers() {
+		if number > b.s.Number {
+			higher = append(higher, number)
+		}
+	}
+
+	if len(higher) == 0 {
+		b.elect()
+		return
+	}
+
+	b.s.log(types.INFO, "Server #"+strconv.Itoa(b.s.Number)+" starts a bully election")
+	b.electing = true
+	b.answered = false
+	b.deadline = time.Now().Add(b.s.electionTimeout())
+	b.s.sendElection(types.Election, higher, 0)
+}
+
+// HandleMessage traite une communication (ELE, ANS, COO) reçue d'un autre serveur.
+func (b *bullyElection) HandleMessage(comm types.Communication) {
+	switch comm.Type {
+	case types.Election:
+		b.s.sendElection(types.Answer, []int{comm.From}, 0)
+		if !b.electing {
+			b.Start()
+		}
+	case types.Answer:
+		if b.electing {
+			b.answered = true
+			b.deadline = time.Now().Add(b.s.electionTimeout())
+		}
+	case types.Coordinator:
+		// Un serveur plus petit ne peut pas être le leader, le serveur impose alors sa propre élection
+		if comm.From < b.s.Number {
+			if !b.electing {
+				b.Start()
+			}
+			return
+		}
+		b.electing = false
+		b.leader = comm.From
+		b.s.logLeader(b.leader)
+	}
+}
+
+// Tick relance l'élection si un serveur plus grand a répondu mais n'a pas annoncé être le leader dans le délai imparti.
+// Si aucun serveur plus grand n'a répondu, le serveur devient le leader.
+func (b *bullyElection) Tick() {
+	if !b.electing || time.Now().Before(b.deadline) {
+		return
+	}
+
+	b.electing = false
+	if b.answered {
+		b.Start()
+	} else {
+		b.elect()
+	}
+}
+
+// RemovePeer relance une élection si le serveur retiré était le leader. L'élection en cours est aussi relancée si le
+// serveur retiré est plus grand, sa réponse ou son COO ne pouvant plus arriver.
+func (b *bullyElection) RemovePeer(number int) {
+	if b.leader == number {
+		b.s.log(types.INFO, utils.RED+"Leader Server #"+strconv.Itoa(number)+" is gone"+utils.RESET)
+		b.Start()
+	} else if b.electing && number > b.s.Number {
+		b.Start()
+	}
+}
+
+// Leader retourne le numéro du leader, 0 si aucun leader n'est élu.
+func (b *bullyElection) Leader() int {
+	return b.leader
+}
+
+// elect fait du serveur le leader et l'annonce à tous les autres serveurs avec un COO.
+func (b *bullyElection) elect() {
+	b.electing = false
+	b.leader = b.s.Number
+	b.s.logLeader(b.leader)
+	b.s.sendElection(types.Coordinator, b.s.peers(), b.leader)
+}
+
+// changRobertsElection est l'implémentation d'Election utilisant l'algorithme de Chang et Roberts.
+//
+// Les serveurs vivants forment un anneau logique trié par numéro. Un serveur qui lance une élection envoie un ELE
+// contenant son numéro à son successeur. Chaque serveur transmet le plus grand numéro entre celui reçu et le sien s'il
+// ne participe pas encore, et ignore les candidats plus petits sinon. Le serveur qui reçoit son propre numéro est élu et
+// fait circuler un COO dans l'anneau jusqu'à ce qu'il lui revienne.
+type changRobertsElection struct {
+	s           *Server   // Serveur participant à l'élection
+	leader      int       // Numéro du leader, 0 si aucun leader n'est élu
+	participant bool      // Indique si le serveur participe à une élection en cours
+	deadline    time.Time // Date limite de réception du COO de l'élection en cours
+}
+
+// Start marque le serveur comme participant et envoie sa candidature à son successeur dans l'anneau.
+func (cr *changRobertsElection) Start() {
+	cr.leader = 0
+	cr.participate()
+
+	next := cr.next()
+	if next == cr.s.Number {
+		cr.elect()
+		return
+	}
+
+	cr.s.log(types.INFO, "Server #"+strconv.Itoa(cr.s.Number)+" starts a ring election")
+	cr.s.sendElection(types.Election, []int{next}, cr.s.Number)
+}
+
+// HandleMessage traite une communication (ELE, COO) reçue du prédécesseur dans l'anneau.
+func (cr *changRobertsElection) HandleMessage(comm types.Communication) {
+	switch comm.Type {
+	case types.Election:
+		switch {
+		case comm.Leader > cr.s.Number:
+			cr.participate()
+			cr.s.sendElection(types.Election, []int{cr.next()}, comm.Leader)
+		case comm.Leader < cr.s.Number && !cr.participant:
+			cr.participate()
+			cr.s.sendElection(types.Election, []int{cr.next()}, cr.s.Number)
+		case comm.Leader == cr.s.Number:
+			cr.elect()
+		}
+	case types.Coordinator:
+		// Le COO a fait le tour de l'anneau
+		if comm.Leader == cr.s.Number {
+			return
+		}
+
+		cr.participant = false
+		cr.leader = comm.Leader
+		cr.s.logLeader(cr.leader)
+		cr.s.sendElection(types.Coordinator, []int{cr.next()}, cr.leader)
+	}
+}
+
+// Tick relance l'élection si le COO n'a pas été reçu dans le délai imparti, l'anneau ayant pu être interrompu par un
+// serveur défaillant.
+func (cr *changRobertsElection) Tick() {
+	if cr.participant && !time.Now().Before(cr.deadline) {
+		cr.participant = false
+		cr.Start()
+	}
+}
+
+// RemovePeer relance une élection si le serveur retiré était le leader. L'élection en cours est aussi relancée, le
+// serveur retiré ayant pu interrompre l'anneau en recevant l'ELE ou le COO.
+func (cr *changRobertsElection) RemovePeer(number int) {
+	if cr.leader == number {
+		cr.s.log(types.INFO, utils.RED+"Leader Server #"+strconv.Itoa(number)+" is gone"+utils.RESET)
+		cr.Start()
+	} else if cr.participant {
+		cr.Start()
+	}
+}
+
+// Leader retourne le numéro du leader, 0 si aucun leader n'est élu.
+func (cr *changRobertsElection) Leader() int {
+	return cr.leader
+}
+
+// participate marque le serveur comme participant à l'élection en cours et réinitialise son délai.
+func (cr *changRobertsElection) participate() {
+	cr.participant = true
+	cr.deadline = time.Now().Add(cr.s.electionTimeout())
+}
+
+// elect fait du serveur le leader et fait circuler un COO dans l'anneau.
+func (cr *changRobertsElection) elect() {
+	cr.participant = false
+	cr.leader = cr.s.Number
+	cr.s.logLeader(cr.leader)
+
+	if next := cr.next(); next != cr.s.Number {
+		cr.s.sendElection(types.Coordinator, []int{next}, cr.leader)
+	}
+}
+
+// next retourne le successeur du serveur dans l'anneau formé par les serveurs vivants.
+func (cr *changRobertsElection) next() int {
+	members := cr.s.members()
+	for i, number := range members {
+		if number == cr.s.Number {
+			return members[(i+1)%len(members)]
+		}
+	}
+	return cr.s.Number
+}
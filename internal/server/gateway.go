@@ -0,0 +1,133 @@
+// Auteurs: Jonathan Friedli, Lazar Pavicevic
+// Labo 2 SDR
+
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/Lazzzer/labo1-sdr/internal/utils"
+	"github.com/Lazzzer/labo1-sdr/internal/utils/types"
+)
+
+// La passerelle HTTP expose les manifestations à travers une API REST. Chaque requête HTTP est traduite en commande et
+// ajoutée à la file des commandes du serveur, comme celles des clients TCP : les modifications passent donc par la même
+// section critique distribuée et sont répliquées de la même manière. Les réponses sont des types.Response encodées en
+// JSON, comme celles des clients en mode JSON.
+//
+//	GET  /events                               show
+//	GET  /events/<id>                          show <id>
+//	GET  /events/<id>/jobs                     jobs <id>
+//	POST /events                               create (contenu : types.CreateEventRequest)
+//	POST /events/<id>/close                    close <id>
+//	POST /events/<id>/jobs/<job>/volunteers    register <id> <job>
+//
+// Les commandes de modification s'authentifient avec l'authentification HTTP basic, vérifiée par verifyUser lors de
+// l'exécution de la commande. Le paramètre "strong" (?strong=true) fait passer une lecture par la section critique.
+//
+// La route /ws ouvre une connexion WebSocket (voir handleWebSocket).
+
+// errorStatus associe les codes d'erreur des commandes aux statuts HTTP. Les autres erreurs retournent 400.
+var errorStatus = map[types.ErrorCode]int{
+	types.AccessDenied:      http.StatusUnauthorized,
+	types.NotCreator:        http.StatusForbidden,
+	types.CreatorRegister:   http.StatusForbidden,
+	types.EventNotFound:     http.StatusNotFound,
+	types.JobNotFound:       http.StatusNotFound,
+	types.EventClosed:       http.StatusConflict,
+	types.AlreadyClosed:     http.StatusConflict,
+	types.JobFull:           http.StatusConflict,
+	types.AlreadyRegistered: http.StatusConflict,
+	types.CommandTimeout:    http.StatusServiceUnavailable,
+}
+
+// serveHTTP lance la passerelle HTTP sur le port donné. La méthode ne se termine qu'en cas d'erreur du listener.
+func (s *Server) serveHTTP(port string) error {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/events", s.handleEvents)
+	mux.HandleFunc("/events/", s.handleEvents)
+	mux.HandleFunc("/ws", s.handleWebSocket)
+	return http.ListenAndServe(":"+port, mux)
+}
+
+// handleEvents traduit une requête HTTP sur les manifestations en commande et écrit sa réponse.
+func (s *Server) handleEvents(w http.ResponseWriter, r *http.Request) {
+	path := strings.Split(strings.Trim(strings.TrimPrefix(r.URL.Path, "/events"), "/"), "/")
+	if path[0] == "" {
+		path = path[:0]
+	}
+
+	var command string
+	var args []string
+	auth := false
+
+	switch {
+	case r.Method == http.MethodGet && len(path) == 0:
+		command = utils.SHOW.Name
+	case r.Method == http.MethodGet && len(path) == 1:
+		command, args = utils.SHOW.Name, path
+	case r.Method == http.MethodGet && len(path) == 2 && path[1] == "jobs":
+		command, args = utils.JOBS.Name, path[:1]
+	case r.Method == http.MethodPost && len(path) == 0:
+		var body types.CreateEventRequest
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil || body.Name == "" || len(body.Jobs) == 0 {
+			writeHTTPResponse(w, failure(types.InvalidRequest))
+			return
+		}
+		command, args, auth = utils.CREATE.Name, []string{body.Name}, true
+		for _, job := range body.Jobs {
+			args = append(args, job.Name, strconv.Itoa(job.NbVolunteers))
+		}
+	case r.Method == http.MethodPost && len(path) == 2 && path[1] == "close":
+		command, args, auth = utils.CLOSE.Name, path[:1], true
+	case r.Method == http.MethodPost && len(path) == 4 && path[1] == "jobs" && path[3] == "volunteers":
+		command, args, auth = utils.REGISTER.Name, []string{path[0], path[2]}, true
+	default:
+		http.NotFound(w, r)
+		return
+	}
+
+	if auth {
+		username, password, ok := r.BasicAuth()
+		if !ok {
+			writeHTTPResponse(w, failure(types.AccessDenied))
+			return
+		}
+		args = append(args, username, password)
+	} else if strong, _ := strconv.ParseBool(r.URL.Query().Get("strong")); strong {
+		args = append(args, utils.STRONG_READ)
+	}
+
+	req := &request{
+		id:       "H" + strconv.FormatInt(s.nbHTTPRequests.Add(1), 10),
+		input:    r.Method + " " + r.URL.Path,
+		command:  command,
+		args:     args,
+		response: make(chan types.Response, 1),
+	}
+	s.log(types.INFO, utils.YELLOW+r.RemoteAddr+" ("+req.id+") -> "+req.input+utils.RESET)
+	s.dispatch(req)
+
+	writeHTTPResponse(w, <-req.response)
+}
+
+// writeHTTPResponse écrit la réponse d'une commande en JSON avec le statut HTTP correspondant à son résultat.
+func writeHTTPResponse(w http.ResponseWriter, response types.Response) {
+	status := http.StatusOK
+	if !response.Ok {
+		status = http.StatusBadRequest
+		if code, ok := errorStatus[response.Error]; ok {
+			status = code
+		}
+	}
+	if status == http.StatusUnauthorized {
+		w.Header().Set("WWW-Authenticate", `Basic realm="events"`)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_, _ = w.Write([]byte(renderJSON(response)))
+}
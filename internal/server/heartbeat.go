@@ -0,0 +1,143 @@
+// Auteurs: Jonathan Friedli, Lazar Pavicevic
+// Labo 2 SDR
+
+package server
+
+import (
+	"net"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/Lazzzer/labo1-sdr/internal/utils"
+	"github.com/Lazzzer/labo1-sdr/internal/utils/types"
+)
+
+// Valeurs par défaut du détecteur de défaillances lorsque la configuration ne les précise pas.
+const (
+	defaultHeartbeatInterval = 500  // Intervalle en millisecondes entre deux heartbeats
+	defaultSuspicionTimeout  = 2000 // Délai en millisecondes sans communication avant de suspecter un serveur
+)
+
+// Le détecteur de défaillances envoie périodiquement un heartbeat (HBT) à tous les autres serveurs. Toute communication
+// reçue d'un serveur prouve qu'il est en vie. Un serveur qui n'a rien envoyé depuis le délai de suspicion est suspecté
+// et retiré des algorithmes d'exclusion mutuelle afin que les serveurs restants continuent de servir les commandes.
+// Sa connexion est fermée, ce qui confirme sa défaillance et lui fait retirer le serveur à son tour : les deux serveurs
+// se considèrent comme défaillants et les communications encore en transit entre eux sont perdues.
+//
+// Un serveur suspecté à tort est toujours en vie. Le serveur ayant le plus grand numéro recontacte donc régulièrement
+// les serveurs défaillants de plus petit numéro, qui le réintègrent comme un serveur ayant redémarré. Les deux serveurs
+// échangent ensuite leurs manifestations pour récupérer les modifications effectuées pendant leur séparation.
+//
+// Toutes les méthodes du détecteur sont appelées par la goroutine principale des algorithmes d'exclusion mutuelle.
+
+// initFailureDetector marque tous les serveurs connectés comme vivants, les autres comme défaillants, et retourne le
+// ticker des heartbeats.
+func (s *Server) initFailureDetector() *time.Ticker {
+	if s.Config.HeartbeatInterval <= 0 {
+		s.Config.HeartbeatInterval = defaultHeartbeatInterval
+	}
+	if s.Config.SuspicionTimeout <= 0 {
+		s.Config.SuspicionTimeout = defaultSuspicionTimeout
+	}
+
+	s.peerStates = make(map[int]types.PeerState, len(s.conns))
+	s.lastSeen = make(map[int]time.Time, len(s.conns))
+	s.redialing = make(map[int]bool)
+	for number := range s.Config.Servers {
+		if _, ok := s.conns[number]; ok {
+			s.peerStates[number] = types.PeerAlive
+			s.lastSeen[number] = time.Now()
+		} else if number != s.Number {
+			s.peerStates[number] = types.PeerDown
+		}
+	}
+
+	return time.NewTicker(time.Duration(s.Config.HeartbeatInterval) * time.Millisecond)
+}
+
+// heartbeat envoie un heartbeat à tous les serveurs vivants puis suspecte ceux qui n'ont pas communiqué depuis le délai
+// de suspicion. Les heartbeats ne sont pas comptabilisés dans les messages de l'exclusion mutuelle.
+func (s *Server) heartbeat() {
+	s.sendControl(types.Communication{Type: types.Heartbeat, From: s.Number, To: s.peers(), Stamp: s.Stamp})
+
+	timeout := time.Duration(s.Config.SuspicionTimeout) * time.Millisecond
+	for _, number := range s.peers() {
+		if time.Since(s.lastSeen[number]) > timeout {
+			s.log(types.INFO, utils.RED+"Server #"+strconv.Itoa(number)+" is suspected (no communication for "+strconv.Itoa(s.Config.SuspicionTimeout)+"ms)"+utils.RESET)
+			s.peerStates[number] = types.PeerSuspected
+			s.removePeer(number)
+		}
+	}
+}
+
+// receiveFrom enregistre la réception d'une communication d'un serveur et indique si elle doit être traitée, ce qui
+// n'est le cas que si le serveur est toujours considéré comme vivant.
+func (s *Server) receiveFrom(number int) bool {
+	s.lastSeen[number] = time.Now()
+	return s.peerStates[number] == types.PeerAlive
+}
+
+// confirmDown confirme la défaillance d'un serveur dont la connexion a été fermée et le retire des algorithmes
+// d'exclusion mutuelle s'il n'était pas déjà suspecté. La fermeture de l'ancienne connexion d'un serveur ayant
+// redémarré ou de la connexion d'un serveur ayant quitté le réseau est ignorée.
+func (s *Server) confirmDown(number int, conn net.Conn) {
+	if _, ok := s.Config.Servers[number]; !ok {
+		return
+	}
+	if current, ok := s.conns[number]; ok {
+		if current != conn {
+			return
+		}
+		s.removePeer(number)
+	}
+	s.peerStates[number] = types.PeerDown
+	s.log(types.INFO, utils.RED+"Server #"+strconv.Itoa(number)+" is confirmed down (connection closed)"+utils.RESET)
+}
+
+// removePeer ferme la connexion d'un serveur et le retire des algorithmes d'exclusion mutuelle de chaque ressource
+// ainsi que de l'élection. Les opérations que le serveur n'a pu diffuser qu'en partie sont récupérées par une
+// synchronisation complète. La fermeture de la connexion, signalée par la goroutine qui la lit, confirme ensuite sa
+// défaillance.
+func (s *Server) removePeer(number int) {
+	if conn, ok := s.conns[number]; ok {
+		conn.Close()
+	}
+	delete(s.conns, number)
+	for _, mutex := range s.mutexes {
+		mutex.RemovePeer(number)
+	}
+	s.election.RemovePeer(number)
+	s.stopRecording(number)
+	s.requestFullSync(s.peers())
+}
+
+// peers retourne les numéros des autres serveurs vivants dans l'ordre croissant.
+func (s *Server) peers() []int {
+	return utils.MapKeysToArray(s.conns)
+}
+
+// members retourne les numéros des serveurs vivants, le serveur lui-même inclus, dans l'ordre croissant.
+func (s *Server) members() []int {
+	numbers := append(s.peers(), s.Number)
+	sort.Ints(numbers)
+	return numbers
+}
+
+// peersStatus retourne l'état de chaque serveur du réseau et la date de sa dernière communication.
+func (s *Server) peersStatus() []types.PeerInfo {
+	var peers []types.PeerInfo
+
+	for _, number := range utils.MapKeysToArray(s.Config.Servers) {
+		peer := types.PeerInfo{Server: number, Self: number == s.Number}
+		if !peer.Self {
+			peer.State = s.peerStates[number]
+			if lastSeen := s.lastSeen[number]; !lastSeen.IsZero() {
+				peer.LastSeen = &lastSeen
+			}
+		}
+		peers = append(peers, peer)
+	}
+
+	return peers
+}
@@ -0,0 +1,180 @@
+// Auteurs: Jonathan Friedli, Lazar Pavicevic
+// Labo 2 SDR
+
+package server
+
+import (
+	"strconv"
+
+	"github.com/Lazzzer/labo1-sdr/internal/utils"
+	"github.com/Lazzzer/labo1-sdr/internal/utils/types"
+)
+
+// lamportMutex est l'implémentation de Mutex utilisant l'algorithme de Lamport optimisé.
+//
+// Chaque serveur stocke la dernière communication reçue de chaque autre serveur. Un serveur accède à la section critique
+// lorsque sa requête est la plus ancienne de toutes les communications stockées.
+type lamportMutex struct {
+	s         *Server                     // Serveur utilisant l'algorithme
+	resource  int                         // Ressource protégée
+	comms     map[int]types.Communication // Map des dernières communications entre les serveurs
+	hasAccess bool                        // Booléen représentant la possession de la section critique
+}
+
+// newLamportMutex crée un lamportMutex dont la map des communications est initialisée avec des REL0.
+func newLamportMutex(s *Server, resource int) *lamportMutex {
+	l := &lamportMutex{s: s, resource: resource, comms: make(map[int]types.Communication, len(s.Config.Servers))}
+
+	for _, number := range s.members() {
+		l.comms[number] = types.Communication{
+			Type:  types.Release,
+			Stamp: 0,
+		}
+	}
+
+	return l
+}
+
+// Acquire envoie une requête (REQ) à tous les autres serveurs.
+func (l *lamportMutex) Acquire() {
+	l.s.Stamp++
+	l.send(types.Request, utils.MapKeysToArray(l.s.conns), false)
+	l.verifyCriticalSection()
+}
+
+// Release libère la section critique et envoie un REL contenant les opérations effectuées à tous les autres serveurs.
+func (l *lamportMutex) Release() {
+	l.hasAccess = false
+	l.s.Stamp++
+	l.send(types.Release, utils.MapKeysToArray(l.s.conns), true)
+}
+
+// HandleMessage traite une communication (REQ, ACK, REL) reçue d'un autre serveur.
+func (l *lamportMutex) HandleMessage(comm types.Communication) {
+	switch comm.Type {
+	case types.Request:
+		l.handleRequest(comm)
+	case types.Acknowledge:
+		l.handleAcknowledge(comm)
+	case types.Release:
+		l.handleRelease(comm)
+	}
+}
+
+// RemovePeer retire un serveur de la map des communications afin que sa dernière communication ne bloque plus l'accès
+// à la section critique.
+func (l *lamportMutex) RemovePeer(number int) {
+	delete(l.comms, number)
+	l.verifyCriticalSection()
+}
+
+// AddPeer réintègre un serveur avec un REL portant l'estampille actuelle. Le serveur n'ayant pas reçu une éventuelle
+// requête en cours, il ne doit pas la bloquer.
+func (l *lamportMutex) AddPeer(number int) {
+	l.comms[number] = types.Communication{
+		Type:  types.Release,
+		From:  number,
+		Stamp: l.s.Stamp,
+	}
+	l.verifyCriticalSection()
+}
+
+// Comms retourne une copie de la map des dernières communications reçues de chaque serveur.
+func (l *lamportMutex) Comms() map[int]types.Communication {
+	comms := make(map[int]types.Communication, len(l.comms))
+	for number, comm := range l.comms {
+		comms[number] = comm
+	}
+	return comms
+}
+
+// Status affiche la map des communications du serveur en un tableau de string.
+func (l *lamportMutex) Status() string {
+	var str string
+	str += "["
+	for i, number := range l.s.members() {
+		if i != 0 {
+			str += ", "
+		}
+		str += "S" + strconv.Itoa(number) + ": " + string(l.comms[number].Type) + strconv.Itoa(l.comms[number].Stamp)
+	}
+	str += "]"
+
+	return str
+}
+
+// send stocke la communication à l'index du serveur dans la map des communications avant de l'envoyer.
+func (l *lamportMutex) send(commType types.CommunicationType, to []int, withPayload bool) {
+	l.comms[l.s.Number] = types.Communication{
+		Type:  commType,
+		From:  l.s.Number,
+		To:    to,
+		Stamp: l.s.Stamp,
+	}
+	l.s.sendComm(commType, l.resource, to, withPayload)
+}
+
+// verifyCriticalSection vérifie si le serveur peut accéder à la section critique distribuée selon l'algorithme de Lamport.
+func (l *lamportMutex) verifyCriticalSection() {
+	if l.hasAccess {
+		return
+	}
+
+	if l.comms[l.s.Number].Type != types.Request {
+		return
+	}
+
+	hasOldestReq := true
+	for i, comm := range l.comms {
+		if i == l.s.Number {
+			continue
+		}
+		if l.comms[l.s.Number].Stamp > comm.Stamp || (l.comms[l.s.Number].Stamp == comm.Stamp && l.s.Number > comm.From) {
+			hasOldestReq = false
+			break
+		}
+	}
+	if hasOldestReq {
+		l.hasAccess = true
+		l.s.grantAccess(l.resource)
+	}
+}
+
+// handleRequest gère la réception d'une requête (REQ) d'accès à la section critique distribuée. Avec Lamport optimisée,
+// si le serveur actuel contient déjà une requête, il ne fait rien. Sinon, il envoie un ACK au serveur qui a envoyé la REQ.
+// Dans les deux cas, le serveur vérifie si il peut accéder à la section critique.
+func (l *lamportMutex) handleRequest(comm types.Communication) {
+	l.s.Stamp = utils.Max(l.s.Stamp, comm.Stamp) + 1
+	l.comms[comm.From] = comm
+	l.s.logComm(comm)
+
+	if l.comms[l.s.Number].Type != types.Request {
+		l.send(types.Acknowledge, []int{comm.From}, false)
+	}
+
+	l.verifyCriticalSection()
+}
+
+// handleAcknowledge gère la réception d'un ACK d'accès à la section critique distribuée. Si le serveur actuel contient
+// déjà une requête, il s'occupe seulement de vérifier s'il a accès à la section critique.
+func (l *lamportMutex) handleAcknowledge(comm types.Communication) {
+	l.s.Stamp = utils.Max(l.s.Stamp, comm.Stamp) + 1
+	if l.comms[comm.From].Type != types.Request {
+		l.comms[comm.From] = comm
+		l.s.logComm(comm)
+	}
+
+	l.verifyCriticalSection()
+}
+
+// handleRelease gère la réception d'un REL d'accès à la section critique distribuée. Le serveur applique les
+// opérations effectuées pendant la section critique à ses manifestations. Finalement, le serveur vérifie s'il a
+// accès à la section critique.
+func (l *lamportMutex) handleRelease(comm types.Communication) {
+	l.s.Stamp = utils.Max(l.s.Stamp, comm.Stamp) + 1
+	l.comms[comm.From] = comm
+	l.s.applyOps(comm)
+	l.s.logComm(comm)
+
+	l.verifyCriticalSection()
+}
@@ -0,0 +1,358 @@
+// Auteurs: Jonathan Friedli, Lazar Pavicevic
+// Labo 2 SDR
+
+package server
+
+import (
+	"math"
+	"sort"
+	"strconv"
+
+	"github.com/Lazzzer/labo1-sdr/internal/utils"
+	"github.com/Lazzzer/labo1-sdr/internal/utils/types"
+)
+
+// maekawaMutex est l'implémentation de Mutex utilisant l'algorithme de Maekawa avec des quorums en grille.
+//
+// Les serveurs sont disposés dans une grille de côté ceil(sqrt(N)) et le quorum d'un serveur est composé de sa ligne
+// et de sa colonne. Deux quorums ont toujours au moins un serveur en commun. Un serveur accède à la section critique
+// lorsque tous les membres de son quorum lui ont accordé leur vote (ACK). Les messages INQ, YLD et FLD permettent
+// d'éviter les interblocages lorsque des requêtes concurrentes obtiennent des votes dans un ordre différent.
+//
+// Les serveurs ne recevant pas tous le REL, un votant joint à son vote les manifestations protégées par la ressource.
+// Comme le dernier détenteur de la section critique envoie son REL à son quorum, qui a au moins un membre en commun
+// avec celui du prochain détenteur, ce dernier obtient toujours la dernière version avant d'entrer en section critique.
+type maekawaMutex struct {
+	s        *Server // Serveur utilisant l'algorithme
+	resource int     // Ressource protégée
+	quorum   []int   // Membres du quorum du serveur, lui-même inclus
+
+	// État du serveur en tant que demandeur
+	requesting bool         // Indique si le serveur a une requête en cours
+	hasAccess  bool         // Booléen représentant la possession de la section critique
+	reqStamp   int          // Estampille de la requête en cours
+	grants     map[int]bool // Membres du quorum ayant voté pour la requête en cours
+	failed     bool         // Indique si la requête en cours a reçu un FLD ou a cédé un vote
+	inquiries  map[int]bool // Votants ayant envoyé un INQ resté sans réponse
+
+	// État du serveur en tant que votant
+	lock     *types.Communication  // Requête ayant obtenu le vote du serveur
+	inquired bool                  // Indique si un INQ a été envoyé au détenteur du vote
+	waiting  []types.Communication // Requêtes en attente du vote, triées par priorité
+
+	local []types.Communication // Communications du serveur à lui-même en attente de traitement
+}
+
+// newMaekawaMutex crée un maekawaMutex et calcule le quorum du serveur.
+func newMaekawaMutex(s *Server, resource int) *maekawaMutex {
+	return &maekawaMutex{
+		s:         s,
+		resource:  resource,
+		quorum:    liveQuorum(s, gridQuorum(s.Number, utils.MapKeysToArray(s.Config.Servers))),
+		grants:    make(map[int]bool),
+		inquiries: make(map[int]bool),
+	}
+}
+
+// Acquire envoie une requête (REQ) à tous les membres du quorum.
+func (m *maekawaMutex) Acquire() {
+	m.s.Stamp++
+	m.requesting = true
+	m.reqStamp = m.s.Stamp
+	m.grants = make(map[int]bool, len(m.quorum))
+	m.failed = false
+	m.inquiries = make(map[int]bool)
+
+	m.sendTo(m.quorum, types.Communication{Type: types.Request, Stamp: m.reqStamp})
+	m.flushLocal()
+}
+
+// Release libère la section critique et envoie un REL contenant les manifestations protégées à tous les membres
+// du quorum.
+func (m *maekawaMutex) Release() {
+	m.hasAccess = false
+	m.requesting = false
+	m.s.Stamp++
+
+	rel := types.Communication{Type: types.Release, Stamp: m.s.Stamp, Resource: m.resource}
+	m.s.attachPayload(&rel)
+	m.sendTo(m.quorum, rel)
+	m.flushLocal()
+}
+
+// HandleMessage traite une communication (REQ, ACK, REL, INQ, YLD, FLD) reçue d'un autre serveur.
+func (m *maekawaMutex) HandleMessage(comm types.Communication) {
+	m.s.Stamp = utils.Max(m.s.Stamp, comm.Stamp) + 1
+	m.handle(comm)
+	m.flushLocal()
+}
+
+// RemovePeer retire un serveur défaillant du quorum et oublie ses votes et ses requêtes. Si le serveur retiré détenait
+// le vote du serveur, celui-ci est accordé à la prochaine requête en attente.
+//
+// Le quorum réduit peut ne plus avoir de membre en commun avec celui d'un autre serveur, l'exclusion mutuelle n'est
+// alors plus garantie entre ces deux serveurs.
+func (m *maekawaMutex) RemovePeer(number int) {
+	for i, member := range m.quorum {
+		if member == number {
+			m.quorum = append(m.quorum[:i], m.quorum[i+1:]...)
+			break
+		}
+	}
+	delete(m.grants, number)
+	delete(m.inquiries, number)
+
+	for i := 0; i < len(m.waiting); i++ {
+		if m.waiting[i].From == number {
+			m.waiting = append(m.waiting[:i], m.waiting[i+1:]...)
+			i--
+		}
+	}
+
+	if m.lock != nil && m.lock.From == number {
+		m.lock = nil
+		m.grantNext()
+	}
+	m.flushLocal()
+
+	if m.requesting {
+		m.verifyCriticalSection()
+	}
+}
+
+// AddPeer réintègre un serveur dans le quorum s'il en faisait partie. Si une requête est en cours, elle lui est envoyée
+// puisqu'il ne l'a pas reçue.
+func (m *maekawaMutex) AddPeer(number int) {
+	quorum := gridQuorum(m.s.Number, utils.MapKeysToArray(m.s.Config.Servers))
+	for _, member := range quorum {
+		if member == number {
+			m.quorum = liveQuorum(m.s, quorum)
+			if m.requesting && !m.hasAccess {
+				m.sendTo([]int{number}, types.Communication{Type: types.Request, Stamp: m.reqStamp})
+			}
+			return
+		}
+	}
+}
+
+// Status affiche le quorum, les votes obtenus et le détenteur du vote du serveur.
+func (m *maekawaMutex) Status() string {
+	var granted []int
+	for number := range m.grants {
+		granted = append(granted, number)
+	}
+	sort.Ints(granted)
+
+	lock := "NONE"
+	if m.lock != nil {
+		lock = "S" + strconv.Itoa(m.lock.From)
+	}
+
+	return "[QUORUM: " + utils.IntToString(m.quorum) + ", VOTES: " + utils.IntToString(granted) + ", VOTE FOR: " + lock +
+		", WAITING: " + strconv.Itoa(len(m.waiting)) + "]"
+}
+
+// handle aiguille une communication vers le traitement correspondant à son type.
+func (m *maekawaMutex) handle(comm types.Communication) {
+	m.s.logComm(comm)
+
+	switch comm.Type {
+	case types.Request:
+		m.handleRequest(comm)
+	case types.Acknowledge:
+		m.handleAcknowledge(comm)
+	case types.Failed:
+		m.failed = true
+		for number := range m.inquiries {
+			m.yield(number)
+		}
+	case types.Inquire:
+		m.handleInquire(comm)
+	case types.Yield:
+		if m.lock != nil && m.lock.From == comm.From {
+			m.enqueue(*m.lock)
+			m.lock = nil
+			m.grantNext()
+		}
+	case types.Release:
+		m.s.mergePayload(comm)
+		if m.lock != nil && m.lock.From == comm.From {
+			m.lock = nil
+			m.grantNext()
+		}
+	}
+}
+
+// handleRequest gère la réception d'une requête en tant que votant. Si le vote est libre, il est accordé. Sinon, la
+// requête est mise en attente et le demandeur reçoit un FLD si une requête plus prioritaire existe. Dans le cas
+// contraire, un INQ est envoyé au détenteur du vote pour lui demander de le céder.
+func (m *maekawaMutex) handleRequest(comm types.Communication) {
+	if m.lock == nil {
+		m.lockFor(comm)
+		return
+	}
+
+	hadWaiting := len(m.waiting) > 0
+	var previousHead types.Communication
+	if hadWaiting {
+		previousHead = m.waiting[0]
+	}
+	m.enqueue(comm)
+
+	if hasPriority(*m.lock, comm) || m.waiting[0].From != comm.From {
+		m.sendTo([]int{comm.From}, types.Communication{Type: types.Failed, Stamp: m.s.Stamp})
+		return
+	}
+
+	// La requête la plus prioritaire de la file a changé, l'ancienne tête doit pouvoir céder ses votes
+	if hadWaiting {
+		m.sendTo([]int{previousHead.From}, types.Communication{Type: types.Failed, Stamp: m.s.Stamp})
+	}
+
+	if !m.inquired {
+		m.inquired = true
+		m.sendTo([]int{m.lock.From}, types.Communication{Type: types.Inquire, Stamp: m.s.Stamp})
+	}
+}
+
+// handleAcknowledge gère la réception d'un vote. Le serveur fusionne les manifestations jointes avec les siennes et
+// accède à la section critique lorsqu'il a obtenu le vote de tous les membres de son quorum.
+func (m *maekawaMutex) handleAcknowledge(comm types.Communication) {
+	if !m.requesting {
+		return
+	}
+
+	m.s.mergePayload(comm)
+	m.grants[comm.From] = true
+	m.verifyCriticalSection()
+}
+
+// verifyCriticalSection accorde l'accès à la section critique lorsque tous les membres du quorum ont voté pour la
+// requête en cours.
+func (m *maekawaMutex) verifyCriticalSection() {
+	if !m.hasAccess && len(m.grants) == len(m.quorum) {
+		m.hasAccess = true
+		m.inquiries = make(map[int]bool)
+		m.s.grantAccess(m.resource)
+	}
+}
+
+// handleInquire gère la réception d'un INQ. Le serveur cède le vote s'il sait déjà qu'il ne peut pas obtenir tous
+// les votes. Sinon, la réponse est différée jusqu'à la réception d'un FLD ou à la libération de la section critique.
+func (m *maekawaMutex) handleInquire(comm types.Communication) {
+	if !m.requesting || m.hasAccess || !m.grants[comm.From] {
+		return
+	}
+
+	if m.failed {
+		m.yield(comm.From)
+	} else {
+		m.inquiries[comm.From] = true
+	}
+}
+
+// yield cède le vote d'un membre du quorum en lui envoyant un YLD.
+func (m *maekawaMutex) yield(number int) {
+	delete(m.grants, number)
+	delete(m.inquiries, number)
+	m.failed = true
+	m.sendTo([]int{number}, types.Communication{Type: types.Yield, Stamp: m.s.Stamp})
+}
+
+// lockFor accorde le vote du serveur à une requête. Les manifestations protégées par la ressource sont jointes au vote.
+func (m *maekawaMutex) lockFor(req types.Communication) {
+	m.lock = &req
+	m.inquired = false
+
+	ack := types.Communication{Type: types.Acknowledge, Stamp: m.s.Stamp, Resource: m.resource}
+	m.s.attachPayload(&ack)
+	m.sendTo([]int{req.From}, ack)
+}
+
+// grantNext accorde le vote du serveur à la requête la plus prioritaire en attente.
+func (m *maekawaMutex) grantNext() {
+	if len(m.waiting) == 0 {
+		return
+	}
+
+	next := m.waiting[0]
+	m.waiting = m.waiting[1:]
+	m.lockFor(next)
+}
+
+// enqueue ajoute une requête dans la file d'attente en conservant l'ordre de priorité.
+func (m *maekawaMutex) enqueue(req types.Communication) {
+	m.waiting = append(m.waiting, req)
+	sort.Slice(m.waiting, func(i, j int) bool {
+		return hasPriority(m.waiting[i], m.waiting[j])
+	})
+}
+
+// sendTo envoie une communication à plusieurs serveurs. Une communication destinée au serveur lui-même n'est pas
+// envoyée sur le réseau mais placée dans une file traitée à la fin de la méthode appelante.
+func (m *maekawaMutex) sendTo(to []int, comm types.Communication) {
+	comm.From = m.s.Number
+	comm.Resource = m.resource
+
+	var others []int
+	for _, number := range to {
+		if number == m.s.Number {
+			m.local = append(m.local, comm)
+		} else {
+			others = append(others, number)
+		}
+	}
+
+	if len(others) > 0 {
+		comm.To = others
+		m.s.send(comm)
+	}
+}
+
+// flushLocal traite les communications que le serveur s'est envoyées à lui-même.
+func (m *maekawaMutex) flushLocal() {
+	for len(m.local) > 0 {
+		comm := m.local[0]
+		m.local = m.local[1:]
+		m.handle(comm)
+	}
+}
+
+// hasPriority indique si la requête a est plus prioritaire que la requête b (estampille puis numéro de serveur).
+func hasPriority(a, b types.Communication) bool {
+	return a.Stamp < b.Stamp || (a.Stamp == b.Stamp && a.From < b.From)
+}
+
+// liveQuorum retire d'un quorum les serveurs retirés par le détecteur de défaillances.
+func liveQuorum(s *Server, quorum []int) []int {
+	var live []int
+	for _, number := range quorum {
+		if _, ok := s.conns[number]; ok || number == s.Number {
+			live = append(live, number)
+		}
+	}
+	return live
+}
+
+// gridQuorum retourne le quorum d'un serveur en disposant les serveurs, triés par numéro, dans une grille de côté
+// ceil(sqrt(N)). Le quorum contient tous les serveurs de la même ligne et de la même colonne que le serveur.
+func gridQuorum(number int, servers []int) []int {
+	side := int(math.Ceil(math.Sqrt(float64(len(servers)))))
+
+	position := 0
+	for i, other := range servers {
+		if other == number {
+			position = i
+		}
+	}
+	row, col := position/side, position%side
+
+	var quorum []int
+	for i, other := range servers {
+		if i/side == row || i%side == col {
+			quorum = append(quorum, other)
+		}
+	}
+
+	return quorum
+}
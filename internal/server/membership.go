@@ -0,0 +1,338 @@
+// Auteurs: Jonathan Friedli, Lazar Pavicevic
+// Labo 2 SDR
+
+package server
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"net"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/Lazzzer/labo1-sdr/internal/utils"
+	"github.com/Lazzzer/labo1-sdr/internal/utils/types"
+)
+
+// join représente la connexion d'un autre serveur identifié par son numéro.
+type join struct {
+	number   int           // Numéro du serveur
+	address  string        // Adresse du serveur, nécessaire pour ajouter un nouveau membre au réseau
+	conn     net.Conn      // Connexion avec le serveur
+	reader   *bufio.Reader // Lecteur de la connexion, qui peut déjà contenir des communications du serveur
+	instance int64         // Instance du serveur, 0 si elle n'est pas connue
+}
+
+// Un serveur qui redémarre recharge un fichier entities.json obsolète. Pour rejoindre le réseau, il se connecte aux
+// serveurs en ligne qui lui répondent avec leur état (STA) : leurs manifestations avec l'estampille de leur dernière
+// modification, leur estampille et les membres du réseau. Le serveur fusionne les états reçus et adopte la plus grande
+// estampille, ce qui lui garantit de connaître toutes les modifications effectuées avant sa connexion. Les
+// modifications suivantes lui sont transmises par les algorithmes d'exclusion mutuelle puisque les autres serveurs le
+// réintègrent avant de lui répondre.
+//
+// Un nouveau serveur rejoint le réseau de la même manière, les autres serveurs apprenant son adresse lors de sa
+// connexion. Un serveur qui quitte le réseau transmet d'abord les responsabilités de ses algorithmes (les jetons par
+// exemple) puis annonce son départ avec un LVE. Les changements de membres doivent être effectués un à la fois.
+
+// sendState envoie l'état du serveur à un serveur qui vient de se connecter.
+func (s *Server) sendState(conn net.Conn) {
+	state := types.Communication{
+		Type:      types.State,
+		From:      s.Number,
+		Stamp:     s.Stamp,
+		Resource:  CreateResource,
+		Running:   s.running,
+		Instance:  s.instance,
+		Servers:   s.Config.Servers,
+		Resources: utils.MapKeysToArray(s.mutexes),
+	}
+	s.attachPayload(&state)
+	s.attachClock(&state)
+
+	stateJson, err := json.Marshal(state)
+	if err != nil {
+		s.log(types.ERROR, err.Error())
+		return
+	}
+
+	if _, err := conn.Write([]byte(string(stateJson) + "\n")); err != nil {
+		s.log(types.ERROR, err.Error())
+	}
+}
+
+// peerState représente l'état (STA) lu sur la connexion d'un serveur contacté au démarrage.
+type peerState struct {
+	number int                 // Numéro du serveur contacté
+	conn   net.Conn            // Connexion sur laquelle l'état a été lu, nil si le serveur n'a pas pu être contacté
+	reader *bufio.Reader       // Lecteur de la connexion, qui peut déjà contenir des communications du serveur
+	state  types.Communication // État du serveur
+	err    error               // Erreur de connexion ou de lecture, l'état n'est alors pas utilisable
+}
+
+// readState lit l'état envoyé par un serveur contacté et le transmet à la goroutine qui l'intègre au réseau.
+func readState(number int, conn net.Conn, reader *bufio.Reader, states chan<- peerState) {
+	result := peerState{number: number, conn: conn, reader: reader}
+
+	input, err := reader.ReadString('\n')
+	if err == nil {
+		err = json.Unmarshal([]byte(input), &result.state)
+	}
+	if err == nil && result.state.Type != types.State {
+		err = errors.New("unexpected communication " + string(result.state.Type))
+	}
+	result.err = err
+
+	states <- result
+}
+
+// receiveState fusionne l'état d'un serveur contacté au démarrage avec celui du serveur. Si le serveur contacté fait
+// partie d'un réseau en fonctionnement, le serveur est marqué comme ayant rejoint le réseau.
+//
+// Un serveur dont l'état n'a pas pu être lu est ignoré, comme s'il n'avait pas pu être contacté. L'état reçu sur une
+// connexion remplacée par celle que le serveur contacté a ouverte est fusionné, mais la connexion est fermée.
+func (s *Server) receiveState(result peerState, readers map[int]*bufio.Reader) {
+	current := s.conns[result.number] == result.conn
+	if !current {
+		result.conn.Close()
+	}
+	if result.err != nil {
+		if current {
+			s.log(types.ERROR, "Could not receive the state of Server #"+strconv.Itoa(result.number)+": "+result.err.Error())
+			delete(s.conns, result.number)
+			delete(readers, result.number)
+			result.conn.Close()
+		}
+		return
+	}
+
+	state := result.state
+	s.mergeState(state)
+	if current {
+		s.instances[result.number] = state.Instance
+	}
+
+	for _, resource := range state.Resources {
+		s.usedResources[resource] = true
+	}
+
+	for member, address := range state.Servers {
+		if _, ok := s.Config.Servers[member]; !ok && member != s.Number {
+			s.Config.Servers[member] = address
+		}
+	}
+
+	if state.Running && !s.rejoined {
+		s.rejoined = true
+		s.log(types.INFO, utils.GREEN+"Server #"+strconv.Itoa(s.Number)+" is joining a running network"+utils.RESET)
+	}
+}
+
+// mergeState adopte l'estampille et les manifestations de l'état d'un autre serveur lorsqu'elles sont plus récentes.
+// En mode Raft, les manifestations sont reconstruites en appliquant le journal envoyé par le leader.
+func (s *Server) mergeState(state types.Communication) {
+	s.Stamp = utils.Max(s.Stamp, state.Stamp)
+	s.receiveClock(state)
+	if s.Config.Consistency != types.RaftConsistency {
+		s.mergePayload(state)
+	}
+}
+
+// admit intègre un serveur qui se connecte pendant l'initialisation du serveur en lui envoyant son état.
+//
+// Deux serveurs qui se contactent mutuellement ouvrent deux connexions. Ils conservent tous les deux celle ouverte par
+// le serveur ayant le plus petit numéro et ferment l'autre. Si la seconde connexion arrive après l'initialisation du
+// serveur, readmit la reconnaît à l'instance transmise par l'autre serveur.
+func (s *Server) admit(j join, readers map[int]*bufio.Reader, dialed map[int]net.Conn) {
+	if _, ok := s.Config.Servers[j.number]; !ok {
+		s.reject(j, "unknown server")
+		return
+	}
+	if conn, ok := dialed[j.number]; ok && s.conns[j.number] == conn {
+		if s.Number < j.number {
+			s.reject(j, "connection already opened")
+			return
+		}
+		conn.Close()
+	}
+
+	s.log(types.INFO, utils.GREEN+"Server #"+strconv.Itoa(s.Number)+" received a connection from Server #"+strconv.Itoa(j.number)+utils.RESET)
+	s.sendState(j.conn)
+	s.conns[j.number] = j.conn
+	s.instances[j.number] = j.instance
+	readers[j.number] = j.reader
+}
+
+// readmit intègre un serveur qui a redémarré, qui a été suspecté ou qui est ajouté au réseau : le serveur lui envoie son
+// état puis l'ajoute aux algorithmes d'exclusion mutuelle de chaque ressource. Si la défaillance n'a pas encore été
+// détectée, l'ancienne connexion du serveur est d'abord fermée. Une nouvelle connexion de la même instance du serveur
+// est en revanche refusée. Le serveur demande ensuite ses manifestations à un serveur déjà connu, qui a pu les modifier
+// pendant sa séparation du réseau.
+func (s *Server) readmit(j join) {
+	known := true
+	if _, ok := s.Config.Servers[j.number]; !ok {
+		if algorithm, ok := supportsMembership(s.Config); !ok {
+			s.reject(j, "membership changes are not supported by "+algorithm)
+			return
+		}
+		if j.address == "" {
+			s.reject(j, "missing address")
+			return
+		}
+
+		known = false
+		s.Config.Servers[j.number] = j.address
+		s.log(types.INFO, utils.GREEN+"Server #"+strconv.Itoa(j.number)+" joined the network"+utils.RESET)
+	} else {
+		if _, ok := s.conns[j.number]; ok {
+			// Une seconde connexion de la même instance a été ouverte au démarrage en même temps que celle conservée
+			if j.instance != 0 && j.instance == s.instances[j.number] {
+				s.reject(j, "connection already opened")
+				return
+			}
+			s.removePeer(j.number)
+		}
+		s.log(types.INFO, utils.GREEN+"Server #"+strconv.Itoa(j.number)+" rejoined the network"+utils.RESET)
+	}
+
+	s.sendState(j.conn)
+
+	s.conns[j.number] = j.conn
+	s.instances[j.number] = j.instance
+	s.peerStates[j.number] = types.PeerAlive
+	s.lastSeen[j.number] = time.Now()
+	for _, mutex := range s.mutexes {
+		mutex.AddPeer(j.number)
+	}
+
+	go s.handleIncomingComms(j.number, j.reader, j.conn)
+	if known {
+		s.requestFullSync([]int{j.number})
+	}
+}
+
+// redialPeers recontacte les serveurs défaillants de plus petit numéro, qui ont pu être suspectés à tort. Un seul
+// appel est en cours par serveur, la connexion pouvant attendre que le réseau livre à nouveau ses communications. Un
+// serveur qui a quitté le réseau ne recontacte pas les serveurs qui ferment leur connexion avant son arrêt.
+func (s *Server) redialPeers() {
+	if s.left {
+		return
+	}
+	timeout := time.Duration(s.Config.SuspicionTimeout) * time.Millisecond
+	for _, number := range utils.MapKeysToArray(s.Config.Servers) {
+		if _, ok := s.conns[number]; ok || number >= s.Number || s.redialing[number] {
+			continue
+		}
+		s.redialing[number] = true
+		go s.redial(number, s.Config.Servers[number], timeout)
+	}
+}
+
+// redial se connecte à un serveur défaillant comme au démarrage et transmet son état à la goroutine principale. L'état
+// doit être reçu avant le délai donné, lorsque la couche réseau le permet.
+func (s *Server) redial(number int, address string, timeout time.Duration) {
+	conn, err := s.transport().Dial(address)
+	if err != nil {
+		s.stateChan <- peerState{number: number, err: err}
+		return
+	}
+
+	if _, err := conn.Write([]byte(s.handshake())); err != nil {
+		conn.Close()
+		s.stateChan <- peerState{number: number, err: err}
+		return
+	}
+	conn.SetReadDeadline(time.Now().Add(timeout))
+	readState(number, conn, bufio.NewReader(conn), s.stateChan)
+}
+
+// reconnect réintègre un serveur défaillant qui a répondu avec son état. Le serveur adopte ses manifestations plus
+// récentes, l'ajoute aux algorithmes d'exclusion mutuelle de chaque ressource et relance une élection, chacun des deux
+// serveurs ayant pu élire un autre leader pendant leur séparation. La connexion est abandonnée si le serveur s'est
+// entre-temps reconnecté de lui-même ou s'il a quitté le réseau.
+func (s *Server) reconnect(result peerState) {
+	delete(s.redialing, result.number)
+	_, connected := s.conns[result.number]
+	_, member := s.Config.Servers[result.number]
+	if result.err != nil || connected || !member {
+		if result.conn != nil {
+			result.conn.Close()
+		}
+		return
+	}
+
+	result.conn.SetReadDeadline(time.Time{})
+	s.mergeState(result.state)
+	s.log(types.INFO, utils.GREEN+"Server #"+strconv.Itoa(s.Number)+" reconnected to Server #"+strconv.Itoa(result.number)+utils.RESET)
+
+	s.conns[result.number] = result.conn
+	s.instances[result.number] = result.state.Instance
+	s.peerStates[result.number] = types.PeerAlive
+	s.lastSeen[result.number] = time.Now()
+	for _, mutex := range s.mutexes {
+		mutex.AddPeer(result.number)
+	}
+
+	go s.handleIncomingComms(result.number, result.reader, result.conn)
+	s.election.Start()
+}
+
+// reject refuse la connexion d'un serveur.
+func (s *Server) reject(j join, reason string) {
+	s.log(types.ERROR, "Server #"+strconv.Itoa(j.number)+" rejected: "+reason)
+	if err := j.conn.Close(); err != nil {
+		s.log(types.ERROR, err.Error())
+	}
+}
+
+// removeMember retire définitivement un serveur qui a quitté le réseau.
+func (s *Server) removeMember(number int) {
+	s.removePeer(number)
+	delete(s.Config.Servers, number)
+	delete(s.peerStates, number)
+	delete(s.lastSeen, number)
+	s.log(types.INFO, utils.YELLOW+"Server #"+strconv.Itoa(number)+" left the network"+utils.RESET)
+}
+
+// leave fait quitter le réseau au serveur puis arrête le programme. La méthode est appelée par la goroutine lançant les
+// commandes de la file et attend la fin des commandes en cours : le serveur n'est donc ni en section critique ni en
+// attente d'y accéder.
+//
+// Si la configuration ne supporte pas les changements de membres, le serveur s'arrête sans prévenir
+// les autres serveurs, qui détectent alors sa défaillance.
+func (s *Server) leave() {
+	if algorithm, ok := supportsMembership(s.Config); !ok {
+		s.log(types.ERROR, "Membership changes are not supported by "+algorithm+", stopping without leaving the network")
+		s.exit(0)
+		return
+	}
+
+	s.commands.Wait()
+	s.runOnEvents(func() {
+		for _, mutex := range s.mutexes {
+			if l, ok := mutex.(leaver); ok {
+				l.Leave()
+			}
+		}
+		s.sendControl(types.Communication{Type: types.Leave, From: s.Number, To: s.peers(), Stamp: s.Stamp})
+		s.left = true
+	})
+
+	s.log(types.INFO, utils.YELLOW+"Server #"+strconv.Itoa(s.Number)+" left the network"+utils.RESET)
+	s.exit(0)
+}
+
+// Leave fait quitter le réseau au serveur une fois les commandes en cours terminées, comme à la réception d'un signal
+// d'arrêt.
+func (s *Server) Leave() {
+	s.leaveChan <- true
+}
+
+// exit arrête le programme avec le code donné, ou appelle la fonction Exit du serveur si elle est définie.
+func (s *Server) exit(code int) {
+	if s.Exit == nil {
+		os.Exit(code)
+	}
+	s.Exit(code)
+}
@@ -0,0 +1,365 @@
+// Auteurs: Jonathan Friedli, Lazar Pavicevic
+// Labo 2 SDR
+
+package server
+
+import (
+	"errors"
+	"io"
+	"net"
+	"sync"
+	"time"
+)
+
+// MemoryNetwork est un réseau en mémoire permettant à plusieurs serveurs d'un même processus de communiquer sans
+// connexion TCP. Chaque serveur obtient sa couche réseau avec Transport.
+//
+// La livraison des communications est contrôlable : les données envoyées d'un serveur à un autre peuvent être retenues
+// puis livrées dans l'ordre de leur envoi ou perdues, et un serveur peut être isolé du réseau, ce qui ferme toutes ses
+// connexions comme lors d'une défaillance.
+type MemoryNetwork struct {
+	mu          sync.Mutex
+	listeners   map[string]*memoryListener       // Serveurs à l'écoute, par adresse
+	pipes       map[memoryLink][]*memoryPipe     // Flux de données ouverts entre deux adresses
+	held        map[memoryLink]bool              // Liens dont la livraison est suspendue
+	drops       map[memoryLink]func([]byte) bool // Filtre des données perdues de chaque lien
+	generations map[string]int                   // Nombre d'isolements de chaque adresse
+}
+
+// memoryLink représente le sens d'une communication entre deux adresses du réseau en mémoire.
+type memoryLink struct {
+	from string // Adresse de l'émetteur
+	to   string // Adresse du destinataire
+}
+
+// NewMemoryNetwork crée un réseau en mémoire vide.
+func NewMemoryNetwork() *MemoryNetwork {
+	return &MemoryNetwork{
+		listeners:   make(map[string]*memoryListener),
+		pipes:       make(map[memoryLink][]*memoryPipe),
+		held:        make(map[memoryLink]bool),
+		drops:       make(map[memoryLink]func([]byte) bool),
+		generations: make(map[string]int),
+	}
+}
+
+// Transport retourne la couche réseau du serveur ayant l'adresse donnée sur le réseau en mémoire.
+func (n *MemoryNetwork) Transport(address string) Transport {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	return &memoryTransport{network: n, address: address, generation: n.generations[address]}
+}
+
+// Hold suspend la livraison des données envoyées par un serveur à un autre. Les données sont retenues jusqu'à l'appel
+// de Release.
+func (n *MemoryNetwork) Hold(from, to string) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	link := memoryLink{from: from, to: to}
+	n.held[link] = true
+	for _, pipe := range n.openPipes(link) {
+		pipe.setHeld(true)
+	}
+}
+
+// Release reprend la livraison des données envoyées par un serveur à un autre, en commençant par les données retenues.
+func (n *MemoryNetwork) Release(from, to string) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	link := memoryLink{from: from, to: to}
+	delete(n.held, link)
+	for _, pipe := range n.openPipes(link) {
+		pipe.setHeld(false)
+	}
+}
+
+// Drop perd les données envoyées par un serveur à un autre pour lesquelles la fonction donnée retourne true. Chaque
+// écriture est examinée séparément, un serveur écrivant chaque communication en une seule fois. Une fonction nil
+// reprend la livraison de toutes les données.
+func (n *MemoryNetwork) Drop(from, to string, match func([]byte) bool) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	link := memoryLink{from: from, to: to}
+	if match == nil {
+		delete(n.drops, link)
+	} else {
+		n.drops[link] = match
+	}
+	for _, pipe := range n.openPipes(link) {
+		pipe.setDrop(match)
+	}
+}
+
+// Isolate retire un serveur du réseau : il n'accepte plus de connexions et toutes ses connexions sont fermées. Les
+// données retenues vers ou depuis le serveur sont perdues.
+//
+// Comme après un crash, la couche réseau du serveur isolé ne peut plus être utilisée. Une nouvelle instance du serveur
+// peut ensuite écouter sur la même adresse avec une nouvelle couche réseau retournée par Transport.
+func (n *MemoryNetwork) Isolate(address string) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	n.generations[address]++
+
+	if listener, ok := n.listeners[address]; ok {
+		delete(n.listeners, address)
+		listener.close()
+	}
+	for link, pipes := range n.pipes {
+		if link.from == address || link.to == address {
+			for _, pipe := range pipes {
+				pipe.close()
+			}
+			delete(n.pipes, link)
+		}
+	}
+}
+
+// openPipes retourne les flux encore ouverts d'un lien et oublie ceux qui ont été fermés. La méthode doit être appelée
+// avec le verrou du réseau.
+func (n *MemoryNetwork) openPipes(link memoryLink) []*memoryPipe {
+	var open []*memoryPipe
+	for _, pipe := range n.pipes[link] {
+		if !pipe.isClosed() {
+			open = append(open, pipe)
+		}
+	}
+	n.pipes[link] = open
+	return open
+}
+
+// newPipe crée le flux de données d'un lien, retenu si la livraison du lien est suspendue. La méthode doit être
+// appelée avec le verrou du réseau.
+func (n *MemoryNetwork) newPipe(link memoryLink) *memoryPipe {
+	pipe := newMemoryPipe()
+	pipe.held = n.held[link]
+	pipe.drop = n.drops[link]
+	n.pipes[link] = append(n.openPipes(link), pipe)
+	return pipe
+}
+
+// memoryTransport est l'implémentation de Transport d'un serveur sur un réseau en mémoire.
+type memoryTransport struct {
+	network    *MemoryNetwork // Réseau en mémoire
+	address    string         // Adresse du serveur
+	generation int            // Nombre d'isolements de l'adresse à la création de la couche réseau
+}
+
+// isolated indique si le serveur a été isolé depuis la création de sa couche réseau. La méthode doit être appelée
+// avec le verrou du réseau.
+func (t *memoryTransport) isolated() bool {
+	return t.generation != t.network.generations[t.address]
+}
+
+// Listen enregistre le serveur à l'écoute sur son adresse.
+func (t *memoryTransport) Listen(address string) (net.Listener, error) {
+	t.network.mu.Lock()
+	defer t.network.mu.Unlock()
+
+	if t.isolated() {
+		return nil, &net.OpError{Op: "listen", Net: memoryAddr(address).Network(), Addr: memoryAddr(address), Err: errors.New("server isolated")}
+	}
+	if _, ok := t.network.listeners[address]; ok {
+		return nil, &net.OpError{Op: "listen", Net: memoryAddr(address).Network(), Addr: memoryAddr(address), Err: errors.New("address already in use")}
+	}
+
+	listener := &memoryListener{address: memoryAddr(address), accept: make(chan net.Conn), closed: make(chan struct{})}
+	listener.onClose = func() {
+		t.network.mu.Lock()
+		defer t.network.mu.Unlock()
+		if t.network.listeners[address] == listener {
+			delete(t.network.listeners, address)
+		}
+	}
+	t.network.listeners[address] = listener
+	return listener, nil
+}
+
+// Dial crée une connexion avec le serveur à l'écoute sur l'adresse donnée. La connexion est refusée si aucun serveur
+// n'écoute sur cette adresse ou si le serveur qui se connecte a été isolé.
+func (t *memoryTransport) Dial(address string) (net.Conn, error) {
+	t.network.mu.Lock()
+	listener, ok := t.network.listeners[address]
+	if !ok || t.isolated() {
+		t.network.mu.Unlock()
+		return nil, &net.OpError{Op: "dial", Net: memoryAddr(address).Network(), Addr: memoryAddr(address), Err: errors.New("connection refused")}
+	}
+	toServer := t.network.newPipe(memoryLink{from: t.address, to: address})
+	toClient := t.network.newPipe(memoryLink{from: address, to: t.address})
+	t.network.mu.Unlock()
+
+	client := &memoryConn{in: toClient, out: toServer, local: memoryAddr(t.address), remote: memoryAddr(address)}
+	server := &memoryConn{in: toServer, out: toClient, local: memoryAddr(address), remote: memoryAddr(t.address)}
+
+	select {
+	case listener.accept <- server:
+		return client, nil
+	case <-listener.closed:
+		client.Close()
+		return nil, &net.OpError{Op: "dial", Net: memoryAddr(address).Network(), Addr: memoryAddr(address), Err: errors.New("connection refused")}
+	}
+}
+
+// memoryListener est l'implémentation de net.Listener d'un serveur sur un réseau en mémoire.
+type memoryListener struct {
+	address memoryAddr    // Adresse du serveur
+	accept  chan net.Conn // Connexions en attente d'acceptation
+	closed  chan struct{} // Fermé lorsque le serveur n'écoute plus
+	once    sync.Once     // Garantit une seule fermeture
+	onClose func()        // Retire le serveur des serveurs à l'écoute du réseau
+}
+
+// Accept attend et retourne la prochaine connexion d'un autre serveur.
+func (l *memoryListener) Accept() (net.Conn, error) {
+	select {
+	case conn := <-l.accept:
+		return conn, nil
+	case <-l.closed:
+		return nil, net.ErrClosed
+	}
+}
+
+// Close arrête l'écoute des connexions.
+func (l *memoryListener) Close() error {
+	l.close()
+	l.onClose()
+	return nil
+}
+
+// close débloque les appels à Accept en cours et futurs.
+func (l *memoryListener) close() {
+	l.once.Do(func() { close(l.closed) })
+}
+
+// Addr retourne l'adresse du serveur à l'écoute.
+func (l *memoryListener) Addr() net.Addr {
+	return l.address
+}
+
+// memoryConn est l'implémentation de net.Conn d'une extrémité d'une connexion sur un réseau en mémoire. Les délais ne
+// sont pas supportés.
+type memoryConn struct {
+	in     *memoryPipe // Flux des données reçues
+	out    *memoryPipe // Flux des données envoyées
+	local  memoryAddr  // Adresse locale
+	remote memoryAddr  // Adresse distante
+}
+
+// Read lit les données reçues, en attendant qu'elles soient livrées.
+func (c *memoryConn) Read(b []byte) (int, error) {
+	return c.in.read(b)
+}
+
+// Write envoie des données sans attendre leur lecture.
+func (c *memoryConn) Write(b []byte) (int, error) {
+	return c.out.write(b)
+}
+
+// Close ferme les deux sens de la connexion.
+func (c *memoryConn) Close() error {
+	c.in.close()
+	c.out.close()
+	return nil
+}
+
+func (c *memoryConn) LocalAddr() net.Addr                { return c.local }
+func (c *memoryConn) RemoteAddr() net.Addr               { return c.remote }
+func (c *memoryConn) SetDeadline(t time.Time) error      { return nil }
+func (c *memoryConn) SetReadDeadline(t time.Time) error  { return nil }
+func (c *memoryConn) SetWriteDeadline(t time.Time) error { return nil }
+
+// memoryAddr est l'implémentation de net.Addr d'une adresse du réseau en mémoire.
+type memoryAddr string
+
+func (a memoryAddr) Network() string { return "memory" }
+func (a memoryAddr) String() string  { return string(a) }
+
+// memoryPipe est un flux de données dans un sens d'une connexion en mémoire. Comme pour TCP, l'écriture ne bloque pas
+// et les données sont lues dans l'ordre de leur écriture.
+type memoryPipe struct {
+	mu     sync.Mutex
+	cond   *sync.Cond
+	buf    []byte            // Données écrites et pas encore lues
+	held   bool              // Indique si la livraison des données est suspendue
+	closed bool              // Indique si le flux est fermé
+	drop   func([]byte) bool // Filtre des écritures perdues, nil si toutes sont livrées
+}
+
+// newMemoryPipe crée un flux de données vide.
+func newMemoryPipe() *memoryPipe {
+	p := &memoryPipe{}
+	p.cond = sync.NewCond(&p.mu)
+	return p
+}
+
+// read attend que des données soient livrées et les lit. Un flux fermé retourne io.EOF, les données retenues étant
+// perdues.
+func (p *memoryPipe) read(b []byte) (int, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for !p.closed && (p.held || len(p.buf) == 0) {
+		p.cond.Wait()
+	}
+	if p.held || len(p.buf) == 0 {
+		return 0, io.EOF
+	}
+
+	n := copy(b, p.buf)
+	p.buf = p.buf[n:]
+	return n, nil
+}
+
+// write ajoute des données au flux.
+func (p *memoryPipe) write(b []byte) (int, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.closed {
+		return 0, io.ErrClosedPipe
+	}
+	if p.drop != nil && p.drop(b) {
+		return len(b), nil
+	}
+	p.buf = append(p.buf, b...)
+	p.cond.Broadcast()
+	return len(b), nil
+}
+
+// setHeld suspend ou reprend la livraison des données.
+func (p *memoryPipe) setHeld(held bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.held = held
+	p.cond.Broadcast()
+}
+
+// setDrop remplace le filtre des écritures perdues.
+func (p *memoryPipe) setDrop(drop func([]byte) bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.drop = drop
+}
+
+// close ferme le flux. Les données déjà livrées peuvent encore être lues.
+func (p *memoryPipe) close() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.closed = true
+	p.cond.Broadcast()
+}
+
+// isClosed indique si le flux est fermé.
+func (p *memoryPipe) isClosed() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return p.closed
+}
@@ -0,0 +1,78 @@
+// Auteurs: Jonathan Friedli, Lazar Pavicevic
+// Labo 2 SDR
+
+package server
+
+import (
+	"log"
+
+	"github.com/Lazzzer/labo1-sdr/internal/utils/types"
+)
+
+// Mutex représente un algorithme d'exclusion mutuelle distribuée utilisé par le serveur pour protéger l'accès à une
+// ressource de la section critique partagée par tous les serveurs du réseau. Le serveur utilise une instance de Mutex
+// par ressource et chaque instance marque ses communications avec sa ressource.
+//
+// Les méthodes d'un Mutex sont toujours appelées par la goroutine principale de traitement des communications
+// serveurs-serveurs, ce qui permet aux implémentations de ne pas protéger leur état interne. Lorsque l'accès à la
+// section critique est accordé, l'implémentation le signale avec la méthode grantAccess du serveur. Le serveur ne
+// redemande l'accès à une ressource qu'après l'avoir libérée, les commandes qui attendent une même ressource étant
+// mises en file par le serveur.
+//
+// Lorsque le détecteur de défaillances retire un serveur, il n'est plus joignable et ne fait plus partie des serveurs
+// connectés. L'implémentation doit alors oublier son état et réévaluer l'accès à la section critique. Lorsqu'un serveur
+// ayant redémarré est réintégré, il fait à nouveau partie des serveurs connectés mais n'a reçu aucune communication
+// envoyée avant sa réintégration.
+type Mutex interface {
+	Acquire()                               // Demande l'accès à la ressource
+	Release()                               // Libère la ressource et diffuse la version à jour des manifestations protégées
+	HandleMessage(comm types.Communication) // Traite une communication reçue d'un autre serveur
+	RemovePeer(number int)                  // Retire un serveur défaillant de l'algorithme
+	AddPeer(number int)                     // Réintègre un serveur ayant redémarré dans l'algorithme
+	Status() string                         // Retourne une représentation de l'état de l'algorithme pour les logs
+}
+
+// leaver est implémentée par les algorithmes d'exclusion mutuelle qui doivent transmettre une responsabilité (un jeton
+// par exemple) à un autre serveur avant que le serveur quitte le réseau.
+type leaver interface {
+	Leave() // Transmet les responsabilités du serveur avant son départ
+}
+
+// commsHolder est implémentée par les algorithmes d'exclusion mutuelle qui stockent la dernière communication reçue de
+// chaque serveur, enregistrée par les snapshots globaux.
+type commsHolder interface {
+	Comms() map[int]types.Communication // Retourne une copie de la map des dernières communications
+}
+
+// supportsMembership indique si la configuration d'un serveur supporte l'ajout et le retrait de serveurs pendant le
+// fonctionnement du réseau et retourne sinon l'algorithme qui ne les supporte pas. Raymond et Maekawa reposent sur une
+// structure (arbre, grille) fixée au démarrage et Raft sur une majorité calculée à partir de la configuration.
+func supportsMembership(config types.ServerConfig) (string, bool) {
+	if config.Consistency == types.RaftConsistency {
+		return string(types.RaftConsistency), false
+	}
+	if config.Mutex == types.Raymond || config.Mutex == types.Maekawa {
+		return string(config.Mutex), false
+	}
+	return "", true
+}
+
+// newMutex retourne l'implémentation de Mutex d'une ressource correspondant à l'algorithme choisi dans la configuration
+// du serveur. L'algorithme de Lamport optimisé est utilisé par défaut.
+func newMutex(s *Server, resource int) Mutex {
+	switch s.Config.Mutex {
+	case types.Lamport, "":
+		return newLamportMutex(s, resource)
+	case types.RicartAgrawala:
+		return newRicartAgrawalaMutex(s, resource)
+	case types.SuzukiKasami:
+		return newSuzukiKasamiMutex(s, resource)
+	case types.Raymond:
+		return newRaymondMutex(s, resource)
+	case types.Maekawa:
+		return newMaekawaMutex(s, resource)
+	default:
+		log.Fatal("Unknown mutex algorithm: " + string(s.Config.Mutex))
+		return nil
+	}
+}
@@ -0,0 +1,183 @@
+// Auteurs: Jonathan Friedli, Lazar Pavicevic
+// Labo 2 SDR
+
+package server
+
+import (
+	"strconv"
+	"sync"
+
+	"github.com/Lazzzer/labo1-sdr/internal/utils"
+	"github.com/Lazzzer/labo1-sdr/internal/utils/types"
+)
+
+// subscriberSize est le nombre de notifications pouvant attendre d'être envoyées à un abonné. Les notifications
+// suivantes sont perdues pour cet abonné tant qu'il ne les a pas lues.
+const subscriberSize = 64
+
+// Toute modification des manifestations du repository passe par setEvent, qu'elle provienne d'une commande locale,
+// d'une opération ou d'une manifestation reçue d'un autre serveur. setEvent compare la nouvelle version de la
+// manifestation à l'ancienne et notifie les abonnés de la modification, ce qui permet aux clients de suivre les
+// manifestations sans renvoyer de commandes "show". La modification est aussi ajoutée au journal des modifications
+// avant d'être appliquée (voir storage.go).
+//
+// Une modification faite sur un autre serveur n'est notifiée qu'une fois reçue. Avec les algorithmes à jeton et
+// Maekawa, qui ne diffusent pas les modifications à tous les serveurs, elle n'est reçue qu'à la prochaine entrée du
+// serveur en section critique sur la ressource concernée, par exemple lors d'une lecture forte.
+//
+// Un abonné ne reçoit que les notifications des manifestations qu'il suit. Les clients WebSocket suivent toutes les
+// manifestations dès leur connexion, alors que les clients TCP choisissent celles qu'ils suivent avec la commande
+// "watch".
+
+// subscriber représente un client abonné aux modifications des manifestations.
+type subscriber struct {
+	updates chan types.Notification // Notifications en attente d'envoi au client
+	all     bool                    // Indique si le client suit toutes les manifestations
+	events  map[int]bool            // Ids des manifestations suivies par le client
+}
+
+// hub contient les abonnés d'un serveur. Les abonnés sont ajoutés et retirés par les goroutines des sessions alors que
+// les notifications sont envoyées par la goroutine modifiant les manifestations, d'où le verrou.
+type hub struct {
+	mu          sync.Mutex
+	subscribers map[*subscriber]bool
+}
+
+// subscribe abonne un nouveau client aux modifications des manifestations. Si all est faux, le client ne suit encore
+// aucune manifestation.
+func (s *Server) subscribe(all bool) *subscriber {
+	sub := &subscriber{updates: make(chan types.Notification, subscriberSize), all: all, events: make(map[int]bool)}
+
+	s.hub.mu.Lock()
+	defer s.hub.mu.Unlock()
+	if s.hub.subscribers == nil {
+		s.hub.subscribers = make(map[*subscriber]bool)
+	}
+	s.hub.subscribers[sub] = true
+	return sub
+}
+
+// unsubscribe désabonne un client et ferme son channel de notifications.
+func (s *Server) unsubscribe(sub *subscriber) {
+	s.hub.mu.Lock()
+	defer s.hub.mu.Unlock()
+	if s.hub.subscribers[sub] {
+		delete(s.hub.subscribers, sub)
+		close(sub.updates)
+	}
+}
+
+// follow ajoute une manifestation, ou toutes si idEvent vaut 0, aux manifestations suivies par un abonné.
+func (s *Server) follow(sub *subscriber, idEvent int) {
+	s.hub.mu.Lock()
+	defer s.hub.mu.Unlock()
+	if idEvent == 0 {
+		sub.all = true
+	} else {
+		sub.events[idEvent] = true
+	}
+}
+
+// changeOps associe chaque modification notifiée à l'opération enregistrée dans le journal des modifications.
+var changeOps = map[types.NotificationType]types.OperationType{
+	types.NotifyCreated:    types.CreateOperation,
+	types.NotifyClosed:     types.CloseOperation,
+	types.NotifyRegistered: types.RegisterOperation,
+}
+
+// setEvent remplace une manifestation dans le repository, après l'avoir ajoutée au journal des modifications, et
+// notifie les abonnés de sa modification.
+func (s *Server) setEvent(idEvent int, event types.Event) {
+	previous, existed := s.Repository.Event(idEvent)
+
+	var change types.NotificationType
+	switch {
+	case !existed:
+		change = types.NotifyCreated
+	case event.Closed && !previous.Closed:
+		change = types.NotifyClosed
+	case !sameVolunteers(previous, event):
+		change = types.NotifyRegistered
+	default:
+		s.storeEvent(idEvent, previous, existed, event)
+		return
+	}
+
+	s.persist(types.WALEntry{Type: changeOps[change], EventId: idEvent, Event: &event, Stamp: s.eventStamps[idEvent]})
+	s.storeEvent(idEvent, previous, existed, event)
+	s.notify(types.Notification{Type: change, Event: s.eventInfo(idEvent)})
+	s.checkpointIfDue()
+}
+
+// storeEvent enregistre une manifestation dans le repository en y appliquant uniquement ses différences avec sa version
+// précédente : sa création, ses jobs modifiés et sa fermeture. Toute autre différence remplace la manifestation.
+func (s *Server) storeEvent(idEvent int, previous types.Event, existed bool, event types.Event) {
+	var err error
+	switch {
+	case !existed:
+		err = s.Repository.CreateEvent(idEvent, event)
+	case previous.Name != event.Name || previous.CreatorId != event.CreatorId || len(previous.Jobs) != len(event.Jobs) || (previous.Closed && !event.Closed):
+		err = s.Repository.SaveEvent(idEvent, event)
+	default:
+		for idJob, job := range event.Jobs {
+			if err == nil && !sameJob(previous.Jobs[idJob], job) {
+				err = s.Repository.UpdateJob(idEvent, idJob, job)
+			}
+		}
+		if err == nil && event.Closed && !previous.Closed {
+			err = s.Repository.CloseEvent(idEvent)
+		}
+	}
+
+	if err != nil {
+		s.log(types.ERROR, utils.RED+"Could not store event #"+strconv.Itoa(idEvent)+": "+err.Error()+utils.RESET)
+	}
+}
+
+// notify envoie une notification aux abonnés suivant la manifestation modifiée sans attendre qu'ils la lisent.
+func (s *Server) notify(notification types.Notification) {
+	s.hub.mu.Lock()
+	defer s.hub.mu.Unlock()
+	for sub := range s.hub.subscribers {
+		if !sub.all && !sub.events[notification.Event.Id] {
+			continue
+		}
+		select {
+		case sub.updates <- notification:
+		default:
+			s.log(types.ERROR, utils.RED+"Notification dropped for a slow subscriber"+utils.RESET)
+		}
+	}
+}
+
+// sameVolunteers indique si les jobs de deux versions d'une manifestation ont les mêmes bénévoles.
+func sameVolunteers(a, b types.Event) bool {
+	if len(a.Jobs) != len(b.Jobs) {
+		return false
+	}
+	for id, job := range a.Jobs {
+		other, ok := b.Jobs[id]
+		if !ok || !sameIds(job.VolunteerIds, other.VolunteerIds) {
+			return false
+		}
+	}
+	return true
+}
+
+// sameJob indique si deux versions d'un job sont identiques.
+func sameJob(a, b types.Job) bool {
+	return a.Name == b.Name && a.NbVolunteers == b.NbVolunteers && sameIds(a.VolunteerIds, b.VolunteerIds)
+}
+
+// sameIds indique si deux listes d'ids contiennent les mêmes ids dans le même ordre.
+func sameIds(a, b []int) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i, id := range a {
+		if b[i] != id {
+			return false
+		}
+	}
+	return true
+}
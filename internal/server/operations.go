@@ -0,0 +1,164 @@
+// Auteurs: Jonathan Friedli, Lazar Pavicevic
+// Labo 2 SDR
+
+package server
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/Lazzzer/labo1-sdr/internal/utils"
+	"github.com/Lazzzer/labo1-sdr/internal/utils/types"
+)
+
+// pendingOp représente une opération reçue qui ne peut pas encore être appliquée car le serveur ne connaît pas la
+// version de la manifestation sur laquelle elle a été effectuée.
+type pendingOp struct {
+	op    types.Operation // Opération reçue
+	from  int             // Serveur ayant envoyé l'opération
+	since time.Time       // Date de réception de l'opération ou de la dernière demande de synchronisation
+}
+
+// Les REL diffusés à tous les serveurs (Lamport et Ricart-Agrawala) transportent uniquement les opérations effectuées
+// pendant la section critique au lieu des manifestations protégées. Chaque opération indique l'estampille de la
+// manifestation avant et après sa modification. Un serveur n'applique une opération que s'il connaît la version de la
+// manifestation sur laquelle elle a été effectuée, ce qui garantit que tous les serveurs appliquent les mêmes opérations
+// dans le même ordre. Une opération reçue trop tôt, les REL de différents serveurs pouvant arriver dans le désordre,
+// est mise en attente jusqu'à la réception des opérations précédentes.
+//
+// Si une opération reste en attente plus longtemps que le délai de suspicion, le serveur a manqué des opérations. Il
+// demande alors la version complète des manifestations concernées (SYN) à l'émetteur de l'opération, qui lui répond
+// avec un SYR. Un serveur tombé pendant la diffusion de son REL a pu n'envoyer ses opérations qu'à une partie des
+// serveurs, le retrait d'un serveur déclenche donc une synchronisation de toutes les manifestations.
+//
+// Les jetons, les votes de Maekawa et l'état transmis à un serveur qui se connecte transportent toujours les
+// manifestations complètes, leur destinataire devant obtenir la dernière version sans dépendre des opérations reçues.
+
+// recordOp enregistre une opération effectuée par une commande pour l'envoyer lors de la libération de la section
+// critique. Son estampille est attribuée lors de la libération.
+func (s *Server) recordOp(op types.Operation) {
+	op.Prev = s.eventStamps[op.EventId]
+	s.ops = append(s.ops, op)
+}
+
+// attachOps ajoute à une communication les opérations de la section critique en cours de libération.
+func (s *Server) attachOps(comm *types.Communication) {
+	comm.Ops = s.releasedOps
+}
+
+// applyOps applique les opérations reçues dans une communication ainsi que les opérations en attente qu'elles
+// permettent d'appliquer.
+func (s *Server) applyOps(comm types.Communication) {
+	for _, op := range comm.Ops {
+		s.pendingOps = append(s.pendingOps, pendingOp{op: op, from: comm.From, since: time.Now()})
+	}
+	s.flushOps()
+}
+
+// flushOps applique les opérations en attente dont la version précédente est connue et oublie celles qui sont déjà
+// reflétées par la version connue, jusqu'à ce qu'aucune ne puisse plus l'être.
+func (s *Server) flushOps() {
+	for progress := true; progress; {
+		progress = false
+
+		var remaining []pendingOp
+		for _, pending := range s.pendingOps {
+			op := pending.op
+			switch {
+			case s.eventStamps[op.EventId] >= op.Stamp:
+				progress = true
+			case s.canApply(op):
+				s.applyOp(op)
+				progress = true
+			default:
+				remaining = append(remaining, pending)
+			}
+		}
+		s.pendingOps = remaining
+	}
+}
+
+// canApply indique si une opération a été effectuée sur la version de la manifestation connue par le serveur. Une
+// manifestation ne peut être créée qu'après toutes celles qui la précèdent.
+func (s *Server) canApply(op types.Operation) bool {
+	if op.Type == types.CreateOperation {
+		return op.EventId == s.Repository.NbEvents()+1
+	}
+
+	_, ok := s.Repository.Event(op.EventId)
+	return ok && s.eventStamps[op.EventId] == op.Prev
+}
+
+// applyOp applique une opération aux manifestations du repository de manière déterministe.
+func (s *Server) applyOp(op types.Operation) {
+	switch op.Type {
+	case types.CreateOperation:
+		s.setEvent(op.EventId, *op.Event)
+	case types.RegisterOperation:
+		event, _ := s.Repository.Event(op.EventId)
+		s.addUserToJob(&event, op.JobId, op.UserId)
+		s.setEvent(op.EventId, event)
+	case types.CloseOperation:
+		event, _ := s.Repository.Event(op.EventId)
+		event.Closed = true
+		s.setEvent(op.EventId, event)
+	}
+	s.setEventStamp(op.EventId, op.Stamp)
+	s.receiveEventClock(op.EventId, op.Clock, true)
+}
+
+// checkPendingOps demande une synchronisation complète des manifestations dont une opération est en attente depuis
+// plus longtemps que le délai de suspicion. La demande est envoyée à l'émetteur de l'opération ou, s'il n'est plus
+// joignable, à tous les autres serveurs.
+func (s *Server) checkPendingOps() {
+	timeout := time.Duration(s.Config.SuspicionTimeout) * time.Millisecond
+	requested := make(map[int]bool)
+
+	for i, pending := range s.pendingOps {
+		if time.Since(pending.since) < timeout {
+			continue
+		}
+		s.pendingOps[i].since = time.Now()
+
+		resource := pending.op.EventId
+		if pending.op.Type == types.CreateOperation {
+			resource = CreateResource
+		}
+		if requested[resource] {
+			continue
+		}
+		requested[resource] = true
+
+		to := s.peers()
+		if _, ok := s.conns[pending.from]; ok {
+			to = []int{pending.from}
+		}
+		s.log(types.INFO, utils.ORANGE+"Missed operations on "+resourceToString(resource)+", requesting a full sync from "+utils.IntToString(to)+utils.RESET)
+		s.sendControl(types.Communication{Type: types.SyncRequest, From: s.Number, To: to, Stamp: s.Stamp, Resource: resource})
+	}
+}
+
+// requestFullSync demande aux serveurs donnés la version complète de toutes les manifestations. En mode Raft, les
+// manifestations ne dépendent que du journal et ne sont jamais synchronisées.
+func (s *Server) requestFullSync(to []int) {
+	if s.raft != nil || len(to) == 0 {
+		return
+	}
+	s.sendControl(types.Communication{Type: types.SyncRequest, From: s.Number, To: to, Stamp: s.Stamp, Resource: CreateResource})
+}
+
+// handleSync traite une demande de synchronisation (SYN) en répondant avec les manifestations protégées par la ressource
+// demandée, ou une réponse (SYR) en fusionnant les manifestations reçues avant de réessayer d'appliquer les opérations
+// en attente.
+func (s *Server) handleSync(comm types.Communication) {
+	switch comm.Type {
+	case types.SyncRequest:
+		reply := types.Communication{Type: types.SyncReply, From: s.Number, To: []int{comm.From}, Stamp: s.Stamp, Resource: comm.Resource}
+		s.attachPayload(&reply)
+		s.sendControl(reply)
+	case types.SyncReply:
+		s.mergePayload(comm)
+		s.flushOps()
+		s.log(types.INFO, utils.GREEN+"Synced "+resourceToString(comm.Resource)+" from Server #"+strconv.Itoa(comm.From)+utils.RESET)
+	}
+}
@@ -0,0 +1,492 @@
+// Auteurs: Jonathan Friedli, Lazar Pavicevic
+// Labo 2 SDR
+
+package server
+
+import (
+	"math/rand"
+	"strconv"
+	"time"
+
+	"github.com/Lazzzer/labo1-sdr/internal/utils"
+	"github.com/Lazzzer/labo1-sdr/internal/utils/types"
+)
+
+// maxEntriesPerAppend est le nombre maximum d'entrées du journal envoyées dans un seul APP.
+const maxEntriesPerAppend = 64
+
+// submitTimeoutFactor est le nombre de délais d'élection après lesquels une commande soumise sans réponse échoue, ce
+// qui laisse le temps d'élire plusieurs leaders successifs.
+const submitTimeoutFactor = 5
+
+// raftRole représente le rôle d'un serveur dans l'algorithme Raft.
+type raftRole string
+
+const (
+	raftFollower  raftRole = "FOLLOWER"
+	raftCandidate raftRole = "CANDIDATE"
+	raftLeader    raftRole = "LEADER"
+)
+
+// raftNode est l'implémentation du mode de cohérence Raft. Il remplace la section critique distribuée : les commandes
+// des clients deviennent des entrées d'un journal répliqué par le leader et sont appliquées dans le même ordre par
+// tous les serveurs. Une entrée est validée lorsqu'elle est répliquée sur une majorité des serveurs de la
+// configuration, puis appliquée à la machine à états formée par les manifestations.
+//
+// Le leader est élu par l'algorithme Raft lui-même, raftNode implémente donc Election. Un serveur sans nouvelles du
+// leader pendant un délai aléatoire compris entre une et deux fois le délai de suspicion se porte candidat. Le leader
+// envoie ses entrées (APP) à chaque heartbeat, qui sert aussi de battement de cœur de Raft.
+//
+// Une commande reçue par un autre serveur est transmise au leader (FWD) et le serveur répond au client lorsqu'il
+// applique l'entrée correspondante. Chaque entrée est identifiée par son serveur d'origine et un numéro de requête, ce
+// qui permet de la retransmettre après un changement de leader sans l'appliquer deux fois.
+type raftNode struct {
+	s           *Server          // Serveur utilisant l'algorithme
+	role        raftRole         // Rôle du serveur
+	term        int              // Mandat actuel
+	votedFor    int              // Serveur ayant reçu le vote du serveur pour le mandat actuel, 0 si aucun
+	votes       map[int]bool     // Serveurs ayant voté pour le serveur lorsqu'il est candidat
+	leader      int              // Numéro du leader du mandat actuel, 0 s'il n'est pas connu
+	deadline    time.Time        // Date à laquelle le serveur se porte candidat sans nouvelles du leader
+	log         []types.LogEntry // Journal des commandes, l'entrée d'index i se trouvant à la position i-1
+	commitIndex int              // Index de la dernière entrée validée
+	lastApplied int              // Index de la dernière entrée appliquée aux manifestations
+	nextIndex   map[int]int      // Index de la prochaine entrée à envoyer à chaque serveur (leader)
+	matchIndex  map[int]int      // Index de la dernière entrée répliquée sur chaque serveur (leader)
+	storage     *raftStorage     // Fichiers de l'état Raft, nil sans dossier des données
+	persisted   int              // Nombre d'entrées du journal identiques à celles enregistrées
+
+	nextReq     int                         // Numéro de la dernière requête soumise par le serveur
+	submitted   map[int]types.LogEntry      // Entrées soumises par le serveur et pas encore appliquées
+	pending     map[int]chan types.Response // Channel attendant la réponse de chaque entrée soumise par le serveur
+	appliedReqs map[int]int                 // Numéro de la dernière requête appliquée de chaque serveur d'origine
+}
+
+// newRaftNode crée un raftNode suiveur, qui reprend l'état enregistré dans le dossier des données du serveur s'il est
+// configuré. Les numéros de requête commencent à l'heure de démarrage du serveur pour rester croissants après un
+// redémarrage.
+func newRaftNode(s *Server) *raftNode {
+	r := &raftNode{
+		s:           s,
+		role:        raftFollower,
+		nextIndex:   make(map[int]int),
+		matchIndex:  make(map[int]int),
+		nextReq:     int(time.Now().UnixNano()),
+		submitted:   make(map[int]types.LogEntry),
+		pending:     make(map[int]chan types.Response),
+		appliedReqs: make(map[int]int),
+	}
+	if s.DataDir != "" {
+		s.recoverRaftState(r)
+	}
+	return r
+}
+
+// submit fait répliquer une commande par Raft et retourne sa réponse une fois appliquée par le serveur. La méthode est
+// appelée par la goroutine traitant la commande du client. Une commande qui n'est pas appliquée après plusieurs délais
+// d'élection, par exemple parce qu'aucune majorité n'est joignable, échoue et n'est plus retransmise au leader.
+func (s *Server) submit(name string, args []string) types.Response {
+	result := make(chan types.Response, 1)
+	s.execChan <- func() {
+		s.raft.Submit(name, args, result)
+	}
+
+	select {
+	case response := <-result:
+		return response
+	case <-time.After(submitTimeoutFactor * s.electionTimeout()):
+		s.execChan <- func() {
+			s.raft.Cancel(result)
+		}
+		return failure(types.CommandTimeout)
+	}
+}
+
+// Submit crée une entrée pour une commande et la transmet au leader. La réponse est envoyée sur le channel result
+// lorsque l'entrée est appliquée.
+func (r *raftNode) Submit(name string, args []string, result chan types.Response) {
+	r.nextReq++
+	entry := types.LogEntry{Origin: r.s.Number, ReqId: r.nextReq, Command: name, Args: args}
+	r.submitted[entry.ReqId] = entry
+	r.pending[entry.ReqId] = result
+	r.forward(entry)
+}
+
+// Cancel oublie une commande soumise par le serveur dont le client n'attend plus la réponse. Elle peut encore être
+// appliquée si le leader l'a déjà ajoutée à son journal.
+func (r *raftNode) Cancel(result chan types.Response) {
+	for reqId, pending := range r.pending {
+		if pending == result {
+			delete(r.pending, reqId)
+			delete(r.submitted, reqId)
+		}
+	}
+}
+
+// Start démarre le serveur en tant que suiveur. Un serveur seul dans sa configuration devient directement le leader.
+func (r *raftNode) Start() {
+	r.resetDeadline()
+	if r.majority() == 1 {
+		r.startElection()
+	}
+}
+
+// HandleMessage traite une communication (RVT, VTR, APP, APR, FWD) reçue d'un autre serveur. Un mandat plus récent que
+// celui du serveur le fait redevenir suiveur.
+func (r *raftNode) HandleMessage(comm types.Communication) {
+	msg := comm.Raft
+	if msg == nil {
+		return
+	}
+
+	if msg.Term > r.term {
+		r.term = msg.Term
+		r.role = raftFollower
+		r.votedFor = 0
+		r.leader = 0
+		r.persist()
+	}
+
+	switch comm.Type {
+	case types.RequestVote:
+		r.handleRequestVote(comm.From, msg)
+	case types.VoteReply:
+		if r.role == raftCandidate && msg.Term == r.term && msg.Success {
+			r.votes[comm.From] = true
+			if len(r.votes) >= r.majority() {
+				r.becomeLeader()
+			}
+		}
+	case types.AppendEntries:
+		r.handleAppendEntries(comm.From, msg)
+	case types.AppendReply:
+		r.handleAppendReply(comm.From, msg)
+	case types.Forward:
+		if r.role == raftLeader {
+			for _, entry := range msg.Entries {
+				r.appendEntry(entry)
+			}
+		}
+	}
+}
+
+// Tick envoie les entrées du leader à tous les serveurs, ou fait se porter candidat un serveur sans nouvelles du
+// leader. Les entrées soumises par le serveur et pas encore appliquées sont retransmises au leader.
+func (r *raftNode) Tick() {
+	if r.role == raftLeader {
+		r.broadcastAppend()
+	} else if !time.Now().Before(r.deadline) {
+		r.startElection()
+	}
+
+	for _, reqId := range utils.MapKeysToArray(r.submitted) {
+		r.forward(r.submitted[reqId])
+	}
+}
+
+// RemovePeer oublie le leader s'il est retiré. Le leader oublie les entrées répliquées sur le serveur retiré, qui peut
+// avoir perdu son journal s'il redémarre.
+func (r *raftNode) RemovePeer(number int) {
+	if r.leader == number {
+		r.s.log(types.INFO, utils.RED+"Leader Server #"+strconv.Itoa(number)+" is gone"+utils.RESET)
+		r.leader = 0
+	}
+	r.matchIndex[number] = 0
+	r.nextIndex[number] = len(r.log) + 1
+}
+
+// Leader retourne le numéro du leader du mandat actuel, 0 s'il n'est pas connu.
+func (r *raftNode) Leader() int {
+	return r.leader
+}
+
+// forward ajoute une entrée au journal si le serveur est le leader ou la transmet au leader s'il est connu. Sinon,
+// l'entrée est retransmise au prochain heartbeat.
+func (r *raftNode) forward(entry types.LogEntry) {
+	if r.role == raftLeader {
+		r.appendEntry(entry)
+	} else if _, ok := r.s.conns[r.leader]; ok {
+		r.send(types.Forward, []int{r.leader}, types.RaftMessage{Entries: []types.LogEntry{entry}})
+	}
+}
+
+// appendEntry ajoute une entrée au journal du leader et l'envoie à tous les serveurs. Une commande déjà appliquée ou
+// déjà présente dans le journal est ignorée.
+func (r *raftNode) appendEntry(entry types.LogEntry) {
+	if entry.Command != "" && r.isKnown(entry) {
+		return
+	}
+
+	entry.Term = r.term
+	r.log = append(r.log, entry)
+	r.persist()
+	r.broadcastAppend()
+	r.advanceCommit()
+}
+
+// isKnown indique si une commande a déjà été appliquée ou se trouve dans la partie du journal pas encore appliquée.
+func (r *raftNode) isKnown(entry types.LogEntry) bool {
+	if entry.ReqId <= r.appliedReqs[entry.Origin] {
+		return true
+	}
+	for _, existing := range r.log[r.lastApplied:] {
+		if existing.Origin == entry.Origin && existing.ReqId == entry.ReqId {
+			return true
+		}
+	}
+	return false
+}
+
+// startElection fait se porter candidat le serveur pour un nouveau mandat et demande le vote de tous les serveurs.
+func (r *raftNode) startElection() {
+	r.term++
+	r.role = raftCandidate
+	r.votedFor = r.s.Number
+	r.votes = map[int]bool{r.s.Number: true}
+	r.leader = 0
+	r.resetDeadline()
+	r.persist()
+
+	r.s.log(types.INFO, "Server #"+strconv.Itoa(r.s.Number)+" is a candidate for term "+strconv.Itoa(r.term))
+	if len(r.votes) >= r.majority() {
+		r.becomeLeader()
+		return
+	}
+
+	r.send(types.RequestVote, r.s.peers(), types.RaftMessage{PrevLogIndex: len(r.log), PrevLogTerm: r.termAt(len(r.log))})
+}
+
+// handleRequestVote accorde le vote du serveur à un candidat s'il n'a pas déjà voté pour un autre serveur pendant ce
+// mandat et que le journal du candidat est au moins aussi à jour que le sien.
+func (r *raftNode) handleRequestVote(from int, msg *types.RaftMessage) {
+	lastTerm := r.termAt(len(r.log))
+	upToDate := msg.PrevLogTerm > lastTerm || (msg.PrevLogTerm == lastTerm && msg.PrevLogIndex >= len(r.log))
+
+	granted := msg.Term == r.term && (r.votedFor == 0 || r.votedFor == from) && upToDate
+	if granted {
+		r.votedFor = from
+		r.resetDeadline()
+		r.persist()
+	}
+
+	r.send(types.VoteReply, []int{from}, types.RaftMessage{Success: granted})
+}
+
+// becomeLeader fait du serveur le leader du mandat actuel. Une entrée vide est ajoutée au journal pour valider les
+// entrées des mandats précédents.
+func (r *raftNode) becomeLeader() {
+	r.role = raftLeader
+	r.setLeader(r.s.Number)
+
+	for number := range r.s.Config.Servers {
+		r.nextIndex[number] = len(r.log) + 1
+		r.matchIndex[number] = 0
+	}
+
+	r.appendEntry(types.LogEntry{Origin: r.s.Number})
+}
+
+// setLeader enregistre le leader du mandat actuel et lui transmet les entrées soumises par le serveur.
+func (r *raftNode) setLeader(leader int) {
+	if r.leader == leader {
+		return
+	}
+
+	r.leader = leader
+	r.s.log(types.INFO, utils.GREEN+"Server #"+strconv.Itoa(leader)+" is the leader for term "+strconv.Itoa(r.term)+utils.RESET)
+	if leader != r.s.Number {
+		for _, reqId := range utils.MapKeysToArray(r.submitted) {
+			r.forward(r.submitted[reqId])
+		}
+	}
+}
+
+// broadcastAppend envoie à chaque serveur les entrées qu'il n'a pas encore répliquées.
+func (r *raftNode) broadcastAppend() {
+	for _, number := range r.s.peers() {
+		r.sendAppend(number)
+	}
+}
+
+// sendAppend envoie à un serveur les entrées du journal à partir de son prochain index, accompagnées de l'entrée qui
+// les précède pour vérifier la cohérence de son journal.
+func (r *raftNode) sendAppend(number int) {
+	next := utils.Max(r.nextIndex[number], 1)
+	if next > len(r.log)+1 {
+		next = len(r.log) + 1
+	}
+
+	end := len(r.log)
+	if end-next+1 > maxEntriesPerAppend {
+		end = next - 1 + maxEntriesPerAppend
+	}
+
+	r.send(types.AppendEntries, []int{number}, types.RaftMessage{
+		PrevLogIndex: next - 1,
+		PrevLogTerm:  r.termAt(next - 1),
+		Entries:      r.log[next-1 : end],
+		LeaderCommit: r.commitIndex,
+	})
+}
+
+// handleAppendEntries ajoute au journal les entrées reçues du leader si son journal contient l'entrée qui les précède,
+// puis applique les entrées validées par le leader.
+func (r *raftNode) handleAppendEntries(from int, msg *types.RaftMessage) {
+	if msg.Term < r.term {
+		r.send(types.AppendReply, []int{from}, types.RaftMessage{MatchIndex: len(r.log)})
+		return
+	}
+
+	r.role = raftFollower
+	r.resetDeadline()
+	r.setLeader(from)
+
+	// Le journal ne contient pas l'entrée précédente, le leader recommence à partir de la dernière entrée connue
+	if msg.PrevLogIndex > len(r.log) || r.termAt(msg.PrevLogIndex) != msg.PrevLogTerm {
+		hint := utils.Min(len(r.log), msg.PrevLogIndex-1)
+		r.send(types.AppendReply, []int{from}, types.RaftMessage{MatchIndex: hint})
+		return
+	}
+
+	changed := false
+	for i, entry := range msg.Entries {
+		index := msg.PrevLogIndex + 1 + i
+		if index <= len(r.log) {
+			if r.log[index-1].Term == entry.Term {
+				continue
+			}
+			r.log = r.log[:index-1]
+			r.persisted = utils.Min(r.persisted, len(r.log))
+		}
+		r.log = append(r.log, entry)
+		changed = true
+	}
+	if changed {
+		r.persist()
+	}
+
+	match := msg.PrevLogIndex + len(msg.Entries)
+	if msg.LeaderCommit > r.commitIndex {
+		r.commitIndex = utils.Min(msg.LeaderCommit, match)
+		r.apply()
+	}
+
+	r.send(types.AppendReply, []int{from}, types.RaftMessage{Success: true, MatchIndex: match})
+}
+
+// handleAppendReply met à jour la progression de la réplication sur un serveur. En cas d'échec, les entrées sont
+// renvoyées à partir de la dernière entrée qu'il connaît.
+func (r *raftNode) handleAppendReply(from int, msg *types.RaftMessage) {
+	if r.role != raftLeader || msg.Term != r.term {
+		return
+	}
+
+	if msg.Success {
+		r.matchIndex[from] = utils.Max(r.matchIndex[from], msg.MatchIndex)
+		r.nextIndex[from] = r.matchIndex[from] + 1
+		r.advanceCommit()
+		return
+	}
+
+	r.nextIndex[from] = utils.Max(1, utils.Min(r.nextIndex[from]-1, msg.MatchIndex+1))
+	r.sendAppend(from)
+}
+
+// advanceCommit valide la dernière entrée du mandat actuel répliquée sur une majorité des serveurs ainsi que toutes les
+// entrées qui la précèdent. Les serveurs sont immédiatement informés des nouvelles entrées validées.
+func (r *raftNode) advanceCommit() {
+	for index := len(r.log); index > r.commitIndex && r.log[index-1].Term == r.term; index-- {
+		replicas := 1
+		for _, number := range r.s.peers() {
+			if r.matchIndex[number] >= index {
+				replicas++
+			}
+		}
+
+		if replicas >= r.majority() {
+			r.commitIndex = index
+			r.apply()
+			r.broadcastAppend()
+			return
+		}
+	}
+}
+
+// apply applique aux manifestations les entrées validées qui ne l'ont pas encore été et répond aux clients des
+// commandes soumises par le serveur. Les commandes de lecture ne sont exécutées que par leur serveur d'origine.
+func (r *raftNode) apply() {
+	for r.lastApplied < r.commitIndex {
+		r.lastApplied++
+		entry := r.log[r.lastApplied-1]
+		if entry.Command == "" || entry.ReqId <= r.appliedReqs[entry.Origin] {
+			continue
+		}
+		r.appliedReqs[entry.Origin] = entry.ReqId
+
+		command, _ := utils.GetCommand(entry.Command)
+		if command.ReadOnly && entry.Origin != r.s.Number {
+			continue
+		}
+
+		response := r.s.execute(entry.Command, entry.Args)
+		r.s.ops = nil
+		r.s.log(types.LAMPORT, "APPLIED ENTRY #"+strconv.Itoa(r.lastApplied)+" FROM S"+strconv.Itoa(entry.Origin)+": "+entry.Command)
+
+		if entry.Origin == r.s.Number {
+			if result, ok := r.pending[entry.ReqId]; ok {
+				result <- response
+				delete(r.pending, entry.ReqId)
+				delete(r.submitted, entry.ReqId)
+			}
+		}
+	}
+}
+
+// send envoie une communication Raft à un ou plusieurs serveurs. Comme les heartbeats, elle n'est pas comptabilisée
+// dans les messages de l'exclusion mutuelle.
+func (r *raftNode) send(commType types.CommunicationType, to []int, msg types.RaftMessage) {
+	msg.Term = r.term
+	r.s.sendControl(types.Communication{Type: commType, From: r.s.Number, To: to, Stamp: r.s.Stamp, Raft: &msg})
+}
+
+// persist enregistre le mandat, le vote et les nouvelles entrées du journal du serveur si son dossier des données est
+// configuré. La méthode est appelée après chaque modification, avant que le serveur ne réponde ou n'envoie ses entrées
+// aux autres serveurs.
+func (r *raftNode) persist() {
+	if r.storage == nil {
+		return
+	}
+
+	err := r.storage.saveState(types.RaftState{Term: r.term, VotedFor: r.votedFor})
+	if err == nil && r.persisted < len(r.storage.ends) {
+		err = r.storage.truncateLog(r.persisted)
+	}
+	if err == nil {
+		err = r.storage.appendLog(r.log[r.persisted:])
+	}
+	if err != nil {
+		r.s.log(types.ERROR, utils.RED+"Could not write the Raft state: "+err.Error()+utils.RESET)
+		return
+	}
+	r.persisted = len(r.log)
+}
+
+// termAt retourne le mandat de l'entrée d'un index du journal, 0 pour l'index 0 ou une entrée absente.
+func (r *raftNode) termAt(index int) int {
+	if index <= 0 || index > len(r.log) {
+		return 0
+	}
+	return r.log[index-1].Term
+}
+
+// majority retourne le nombre de serveurs de la configuration formant une majorité.
+func (r *raftNode) majority() int {
+	return len(r.s.Config.Servers)/2 + 1
+}
+
+// resetDeadline repousse la candidature du serveur d'un délai aléatoire compris entre une et deux fois le délai de
+// suspicion, ce qui évite que plusieurs serveurs se portent candidats en même temps.
+func (r *raftNode) resetDeadline() {
+	timeout := r.s.electionTimeout()
+	r.deadline = time.Now().Add(timeout + time.Duration(rand.Int63n(int64(timeout))))
+}
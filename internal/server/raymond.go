@@ -0,0 +1,145 @@
+// Auteurs: Jonathan Friedli, Lazar Pavicevic
+// Labo 2 SDR
+
+package server
+
+import (
+	"strconv"
+
+	"github.com/Lazzzer/labo1-sdr/internal/utils"
+	"github.com/Lazzzer/labo1-sdr/internal/utils/types"
+)
+
+// raymondMutex est l'implémentation de Mutex utilisant l'algorithme à jeton de Raymond.
+//
+// Les serveurs sont organisés dans un arbre logique déclaré dans la configuration. Chaque serveur connaît uniquement le
+// voisin en direction du jeton (holder) et ne communique qu'avec ses voisins dans l'arbre. Comme pour Suzuki-Kasami,
+// le jeton transporte les manifestations protégées par sa ressource. Au démarrage, le jeton est détenu par la racine
+// de l'arbre.
+type raymondMutex struct {
+	s         *Server // Serveur utilisant l'algorithme
+	resource  int     // Ressource protégée
+	holder    int     // Voisin en direction du jeton, numéro du serveur lui-même s'il détient le jeton
+	queue     []int   // File des voisins (ou du serveur lui-même) ayant demandé le jeton
+	asked     bool    // Indique si une requête a déjà été envoyée au holder
+	hasAccess bool    // Booléen représentant la possession de la section critique
+}
+
+// newRaymondMutex crée un raymondMutex dont le holder est le parent du serveur dans l'arbre logique, vérifié au
+// démarrage du serveur.
+func newRaymondMutex(s *Server, resource int) *raymondMutex {
+	r := &raymondMutex{s: s, resource: resource, holder: s.tree[s.Number]}
+	if r.holder == 0 {
+		r.holder = s.Number
+
+		// Une racine ayant rejoint un réseau en fonctionnement ne détient plus le jeton d'une ressource déjà utilisée
+		if s.usedResources[resource] {
+			for _, number := range s.peers() {
+				if s.tree[number] == s.Number {
+					r.holder = number
+					break
+				}
+			}
+		}
+	}
+
+	return r
+}
+
+// Acquire ajoute le serveur à sa propre file et demande le jeton s'il ne le possède pas.
+func (r *raymondMutex) Acquire() {
+	r.queue = append(r.queue, r.s.Number)
+	r.assignPrivilege()
+	r.makeRequest()
+}
+
+// Release libère la section critique et transmet le jeton au prochain voisin de la file.
+func (r *raymondMutex) Release() {
+	r.hasAccess = false
+	r.assignPrivilege()
+	r.makeRequest()
+}
+
+// HandleMessage traite une communication (REQ, TOK) reçue d'un voisin.
+func (r *raymondMutex) HandleMessage(comm types.Communication) {
+	r.s.Stamp = utils.Max(r.s.Stamp, comm.Stamp) + 1
+
+	switch comm.Type {
+	case types.Request:
+		r.queue = append(r.queue, comm.From)
+	case types.TokenTransfer:
+		r.holder = r.s.Number
+		r.s.mergePayload(comm)
+	}
+	r.s.logComm(comm)
+
+	r.assignPrivilege()
+	r.makeRequest()
+}
+
+// RemovePeer retire un voisin défaillant de la file d'attente.
+//
+// Si le voisin retiré est le holder, le jeton se trouve dans la partie de l'arbre qui n'est plus joignable et la
+// ressource n'est plus accessible, l'arbre étant statique.
+func (r *raymondMutex) RemovePeer(number int) {
+	for i := 0; i < len(r.queue); i++ {
+		if r.queue[i] == number {
+			r.queue = append(r.queue[:i], r.queue[i+1:]...)
+			i--
+		}
+	}
+
+	if r.holder == number {
+		r.s.log(types.ERROR, "Token of "+resourceToString(r.resource)+" lost with Server #"+strconv.Itoa(number))
+		return
+	}
+
+	r.assignPrivilege()
+	r.makeRequest()
+}
+
+// AddPeer réintègre un voisin ayant redémarré. Une requête envoyée à son ancienne instance est perdue, elle est donc
+// renvoyée si le voisin est le holder.
+func (r *raymondMutex) AddPeer(number int) {
+	if r.holder == number && r.asked {
+		r.asked = false
+		r.makeRequest()
+	}
+}
+
+// Status affiche le holder et la file d'attente du serveur.
+func (r *raymondMutex) Status() string {
+	return "[HOLDER: S" + strconv.Itoa(r.holder) + ", QUEUE: " + utils.IntToString(r.queue) + ", ASKED: " + strconv.FormatBool(r.asked) + "]"
+}
+
+// assignPrivilege transmet le jeton au premier élément de la file lorsque le serveur le détient sans l'utiliser.
+// Si le premier élément est le serveur lui-même, l'accès à la section critique est accordé.
+func (r *raymondMutex) assignPrivilege() {
+	if r.holder != r.s.Number || r.hasAccess || len(r.queue) == 0 {
+		return
+	}
+
+	r.holder = r.queue[0]
+	r.queue = r.queue[1:]
+	r.asked = false
+
+	if r.holder == r.s.Number {
+		r.hasAccess = true
+		r.s.grantAccess(r.resource)
+		return
+	}
+
+	r.s.Stamp++
+	r.s.sendComm(types.TokenTransfer, r.resource, []int{r.holder}, true)
+}
+
+// makeRequest envoie une requête (REQ) au holder si la file n'est pas vide et qu'aucune requête n'a déjà été envoyée.
+func (r *raymondMutex) makeRequest() {
+	if r.holder == r.s.Number || len(r.queue) == 0 || r.asked {
+		return
+	}
+
+	r.asked = true
+	r.s.Stamp++
+	r.s.sendComm(types.Request, r.resource, []int{r.holder}, false)
+}
@@ -0,0 +1,235 @@
+// Auteurs: Jonathan Friedli, Lazar Pavicevic
+// Labo 2 SDR
+
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/Lazzzer/labo1-sdr/internal/utils"
+	"github.com/Lazzzer/labo1-sdr/internal/utils/types"
+)
+
+// Les commandes retournent une réponse typée (types.Response). Les clients en mode JSON la reçoivent telle quelle et
+// en font leur propre affichage, alors que les autres clients reçoivent le texte mis en forme par renderText.
+
+// renderJSON encode une réponse en JSON sur une seule ligne.
+func renderJSON(response types.Response) string {
+	content, err := json.Marshal(response)
+	if err != nil {
+		return `{"ok":false}` + "\n"
+	}
+	return string(content) + "\n"
+}
+
+// renderText met en forme une réponse pour l'affichage dans un terminal.
+func renderText(response types.Response) string {
+	if !response.Ok {
+		// Une commande vide n'a jamais été mise en forme comme une erreur
+		if response.Error == types.EmptyCommand {
+			return utils.ErrorText(response.Error)
+		}
+		return utils.MESSAGE.WrapErrorCode(response.Error)
+	}
+
+	switch response.Command {
+	case utils.HELP.Name:
+		return utils.MESSAGE.Help
+	case utils.SHOW.Name:
+		if response.Event != nil {
+			return renderEvent(*response.Event)
+		}
+		return renderEvents(response.Events)
+	case utils.JOBS.Name:
+		return renderJobs(*response.Event)
+	case utils.PEERS.Name:
+		return renderPeers(response.Peers)
+	case utils.LEADER.Name:
+		return renderLeader(*response.Leader)
+	case utils.STATUS.Name:
+		return renderStatus(*response.Status)
+	default:
+		return utils.MESSAGE.WrapSuccess(response.Message + "\n")
+	}
+}
+
+// renderNotification affiche la modification d'une manifestation suivie sur une seule ligne, qui s'intercale entre les
+// réponses du client.
+func renderNotification(notification types.Notification) string {
+	event := notification.Event
+	response := "🔔 #" + strconv.Itoa(event.Id) + " " + utils.BOLD + utils.CYAN + event.Name + utils.RESET + " "
+
+	switch notification.Type {
+	case types.NotifyCreated:
+		response += utils.GREEN + "created" + utils.RESET + " by " + event.Creator
+	case types.NotifyClosed:
+		response += utils.RED + "closed" + utils.RESET
+	default:
+		var jobs []string
+		for _, job := range event.Jobs {
+			jobs = append(jobs, "Job #"+strconv.Itoa(job.Id)+" "+job.Name+" ("+strconv.Itoa(len(job.Volunteers))+"/"+strconv.Itoa(job.NbVolunteers)+")")
+		}
+		response += utils.YELLOW + "volunteers" + utils.RESET + ": " + strings.Join(jobs, ", ")
+	}
+
+	return response + "\n"
+}
+
+// renderEvents affiche toutes les manifestations.
+func renderEvents(events []types.EventInfo) string {
+	var response string
+
+	for i, event := range events {
+		if event.Closed {
+			response += utils.RED + "Closed" + utils.RESET
+		} else {
+			response += utils.GREEN + "Open" + utils.RESET
+		}
+		response += "\t#" + strconv.Itoa(event.Id) + " " + utils.BOLD + utils.CYAN + event.Name + utils.RESET + " / Creator: " + event.Creator + "\n"
+		if i != len(events)-1 {
+			response += "\n"
+		}
+	}
+
+	return utils.MESSAGE.WrapEvent(response)
+}
+
+// renderEvent affiche une manifestation avec ses jobs.
+func renderEvent(event types.EventInfo) string {
+	response := "#" + strconv.Itoa(event.Id) + " " + utils.BOLD + utils.CYAN + event.Name + utils.RESET + "\n\n"
+	response += "Creator: " + event.Creator + "\n\n"
+	response += "🦺" + utils.BOLD + " Jobs" + utils.RESET + "\n\n"
+
+	for _, job := range event.Jobs {
+		var color string
+		if len(job.Volunteers) == job.NbVolunteers {
+			color = utils.RED
+		} else {
+			color = utils.GREEN
+		}
+
+		response += color + "(" + strconv.Itoa(len(job.Volunteers)) + "/" + strconv.Itoa(job.NbVolunteers) + ")" + utils.RESET + "\tJob #" + strconv.Itoa(job.Id) + ": " + job.Name + "\n"
+	}
+
+	return utils.MESSAGE.WrapEvent(response)
+}
+
+// renderJobs affiche la répartition des bénévoles dans les jobs d'une manifestation.
+func renderJobs(event types.EventInfo) string {
+	eventTitle := "#" + strconv.Itoa(event.Id) + " " + utils.BOLD + utils.CYAN + event.Name + utils.RESET + "\n\n"
+	firstLine := utils.BOLD + "Volunteers" + utils.RESET + "\t"
+	numberOfUsers := 0
+	for _, job := range event.Jobs {
+		firstLine += "#" + strconv.Itoa(job.Id) + " " + job.Name + " (" + strconv.Itoa(len(job.Volunteers)) + "/" + strconv.Itoa(job.NbVolunteers) + ")\t"
+		numberOfUsers += len(job.Volunteers)
+	}
+
+	var builder strings.Builder
+	aligner := "\t"
+	var endColumn string
+	for i := 0; i < len(event.Jobs); i++ {
+		endColumn += "\t"
+	}
+
+	w := tabwriter.NewWriter(&builder, 0, 0, 3, ' ', 0)
+	fmt.Fprintln(w, firstLine)
+
+	if numberOfUsers == 0 {
+		w.Flush()
+		return utils.MESSAGE.WrapEvent(eventTitle + builder.String() + "\nThere is currently no volunteers for this event.\n")
+	}
+
+	for _, job := range event.Jobs {
+		for _, volunteer := range job.Volunteers {
+			fmt.Fprintln(w, volunteer+aligner+"✅"+endColumn)
+		}
+		aligner += "\t"
+		endColumn = strings.TrimSuffix(endColumn, "\t")
+	}
+	w.Flush()
+
+	return utils.MESSAGE.WrapEvent(eventTitle + builder.String())
+}
+
+// renderPeers affiche l'état de chaque serveur du réseau et le temps écoulé depuis sa dernière communication.
+func renderPeers(peers []types.PeerInfo) string {
+	var response string
+
+	for _, peer := range peers {
+		response += "S" + strconv.Itoa(peer.Server) + "\t"
+		if peer.Self {
+			response += utils.BOLD + "SELF" + utils.RESET + "\n"
+			continue
+		}
+
+		switch peer.State {
+		case types.PeerAlive:
+			response += utils.GREEN
+		case types.PeerSuspected:
+			response += utils.ORANGE
+		default:
+			response += utils.RED
+		}
+		response += string(peer.State) + utils.RESET + "\t"
+		if peer.LastSeen == nil {
+			response += "never seen\n"
+		} else {
+			response += "last seen " + time.Since(*peer.LastSeen).Round(time.Millisecond).String() + " ago\n"
+		}
+	}
+
+	return utils.MESSAGE.WrapPeers(response)
+}
+
+// renderLeader affiche le leader élu ainsi que l'algorithme d'élection utilisé.
+func renderLeader(leader types.LeaderInfo) string {
+	response := "Leader: "
+	if leader.Leader == 0 {
+		response += "election in progress\n"
+	} else if leader.Self {
+		response += "S" + strconv.Itoa(leader.Leader) + " " + utils.BOLD + "(SELF)" + utils.RESET + "\n"
+	} else {
+		response += "S" + strconv.Itoa(leader.Leader) + "\n"
+	}
+	response += "Election: " + leader.Election + "\n"
+
+	return utils.MESSAGE.WrapLeader(response)
+}
+
+// renderStatus affiche l'état interne d'un serveur.
+func renderStatus(status types.StatusInfo) string {
+	response := "Server: S" + strconv.Itoa(status.Server) + "\n"
+	response += "Lamport stamp: " + strconv.Itoa(status.Stamp) + "\n"
+	if status.VectorClock != nil {
+		response += "Vector clock: " + clockToString(status.VectorClock) + "\n"
+		response += "Conflicts detected: " + strconv.Itoa(status.Conflicts) + "\n"
+	} else {
+		response += "Vector clock: disabled\n"
+	}
+	response += "Messages sent: " + strconv.Itoa(status.Messages) + " for " + strconv.Itoa(status.Accesses) + " access(es)\n"
+
+	if status.Raft != nil {
+		response += "\nRaft: " + status.Raft.Role + ", term " + strconv.Itoa(status.Raft.Term) + ", commit " + strconv.Itoa(status.Raft.CommitIndex) + "/" + strconv.Itoa(status.Raft.LogLength) + "\n"
+	} else {
+		response += "\nMutexes (" + string(status.Mutex) + "):\n"
+		for _, mutex := range status.Mutexes {
+			response += resourceToString(mutex.Resource) + "\t" + mutex.Status + "\n"
+		}
+	}
+
+	response += "\nEvents:\n"
+	for _, event := range status.Events {
+		response += resourceToString(event.Id) + "\tstamp " + strconv.Itoa(event.Stamp)
+		if event.Clock != nil {
+			response += "\t" + clockToString(event.Clock)
+		}
+		response += "\n"
+	}
+
+	return utils.MESSAGE.WrapStatus(response)
+}
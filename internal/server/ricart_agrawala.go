@@ -0,0 +1,139 @@
+// Auteurs: Jonathan Friedli, Lazar Pavicevic
+// Labo 2 SDR
+
+package server
+
+import (
+	"strconv"
+
+	"github.com/Lazzzer/labo1-sdr/internal/utils"
+	"github.com/Lazzzer/labo1-sdr/internal/utils/types"
+)
+
+// ricartAgrawalaMutex est l'implémentation de Mutex utilisant l'algorithme de Ricart-Agrawala.
+//
+// Un serveur accède à la section critique lorsqu'il a reçu une permission (ACK) de tous les autres serveurs. Un serveur
+// qui reçoit une requête moins prioritaire que la sienne diffère sa permission jusqu'à la libération de la section critique.
+// L'algorithme n'a pas de REL propre, le REL est uniquement utilisé pour diffuser les opérations effectuées avant
+// d'envoyer les permissions différées.
+type ricartAgrawalaMutex struct {
+	s          *Server      // Serveur utilisant l'algorithme
+	resource   int          // Ressource protégée
+	requesting bool         // Indique si le serveur a une requête en cours
+	hasAccess  bool         // Booléen représentant la possession de la section critique
+	reqStamp   int          // Estampille de la requête en cours
+	replies    map[int]bool // Serveurs ayant donné leur permission pour la requête en cours
+	deferred   []int        // Serveurs dont la permission est différée
+}
+
+// newRicartAgrawalaMutex crée un ricartAgrawalaMutex sans requête en cours.
+func newRicartAgrawalaMutex(s *Server, resource int) *ricartAgrawalaMutex {
+	return &ricartAgrawalaMutex{s: s, resource: resource, replies: make(map[int]bool)}
+}
+
+// Acquire envoie une requête (REQ) à tous les autres serveurs.
+func (r *ricartAgrawalaMutex) Acquire() {
+	r.s.Stamp++
+	r.requesting = true
+	r.reqStamp = r.s.Stamp
+	r.replies = make(map[int]bool, len(r.s.conns))
+	r.s.sendComm(types.Request, r.resource, utils.MapKeysToArray(r.s.conns), false)
+	r.verifyCriticalSection()
+}
+
+// Release libère la section critique, diffuse les opérations effectuées à tous les autres serveurs avec un REL puis
+// envoie les permissions différées. Les connexions étant FIFO, un serveur reçoit toujours les opérations avant la
+// permission.
+func (r *ricartAgrawalaMutex) Release() {
+	r.hasAccess = false
+	r.requesting = false
+	r.s.Stamp++
+	r.s.sendComm(types.Release, r.resource, utils.MapKeysToArray(r.s.conns), true)
+
+	if len(r.deferred) > 0 {
+		r.s.sendComm(types.Acknowledge, r.resource, r.deferred, false)
+		r.deferred = nil
+	}
+}
+
+// HandleMessage traite une communication (REQ, ACK, REL) reçue d'un autre serveur.
+func (r *ricartAgrawalaMutex) HandleMessage(comm types.Communication) {
+	r.s.Stamp = utils.Max(r.s.Stamp, comm.Stamp) + 1
+
+	switch comm.Type {
+	case types.Request:
+		r.handleRequest(comm)
+	case types.Acknowledge:
+		r.replies[comm.From] = true
+		r.s.logComm(comm)
+		r.verifyCriticalSection()
+	case types.Release:
+		r.s.applyOps(comm)
+		r.s.logComm(comm)
+	}
+}
+
+// RemovePeer oublie la permission et la requête différée d'un serveur. Les permissions attendues ne concernant que les
+// serveurs connectés, l'accès à la section critique peut alors être accordé.
+func (r *ricartAgrawalaMutex) RemovePeer(number int) {
+	delete(r.replies, number)
+	for i, deferred := range r.deferred {
+		if deferred == number {
+			r.deferred = append(r.deferred[:i], r.deferred[i+1:]...)
+			break
+		}
+	}
+	r.verifyCriticalSection()
+}
+
+// AddPeer réintègre un serveur. N'ayant pas reçu une éventuelle requête en cours et n'ayant lui-même aucune requête
+// en cours, sa permission est considérée comme accordée.
+func (r *ricartAgrawalaMutex) AddPeer(number int) {
+	if r.requesting {
+		r.replies[number] = true
+	}
+	r.verifyCriticalSection()
+}
+
+// Status affiche l'état de la requête en cours avec les permissions reçues et différées.
+func (r *ricartAgrawalaMutex) Status() string {
+	var replied []int
+	for number := range r.replies {
+		replied = append(replied, number)
+	}
+
+	state := "IDLE"
+	if r.hasAccess {
+		state = "CS" + strconv.Itoa(r.reqStamp)
+	} else if r.requesting {
+		state = string(types.Request) + strconv.Itoa(r.reqStamp)
+	}
+
+	return "[" + state + ", ACK FROM: " + utils.IntToString(replied) + ", DEFERRED: " + utils.IntToString(r.deferred) + "]"
+}
+
+// handleRequest gère la réception d'une requête (REQ). La permission est différée si le serveur est dans la section
+// critique ou si sa propre requête est plus ancienne. Sinon, le serveur envoie immédiatement un ACK.
+func (r *ricartAgrawalaMutex) handleRequest(comm types.Communication) {
+	r.s.logComm(comm)
+
+	hasPriority := r.requesting && (r.reqStamp < comm.Stamp || (r.reqStamp == comm.Stamp && r.s.Number < comm.From))
+	if r.hasAccess || hasPriority {
+		r.deferred = append(r.deferred, comm.From)
+		return
+	}
+
+	r.s.sendComm(types.Acknowledge, r.resource, []int{comm.From}, false)
+}
+
+// verifyCriticalSection accorde l'accès à la section critique lorsque tous les autres serveurs ont donné leur permission.
+func (r *ricartAgrawalaMutex) verifyCriticalSection() {
+	if !r.requesting || r.hasAccess {
+		return
+	}
+
+	if len(r.replies) == len(r.s.conns) {
+		r.hasAccess = true
+		r.s.grantAccess(r.resource)
+	}
+}
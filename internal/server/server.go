@@ -5,53 +5,160 @@
 //
 // Le serveur est capable de gérer plusieurs clients en même temps.
 // Le serveur est capable de se connecter à d'autres serveurs pour former un réseau et gère les accès à une section critique
-// en utilisant l'algorithme de Lamport optimisé.
+// en utilisant un algorithme d'exclusion mutuelle distribuée choisi dans sa configuration (Lamport optimisé par défaut,
+// Ricart-Agrawala, Suzuki-Kasami, Raymond ou Maekawa). Chaque manifestation est protégée par son propre verrou distribué
+// et un verrou séparé protège la création de manifestations. Un détecteur de défaillances basé sur des heartbeats retire
+// des algorithmes les serveurs qui ne répondent plus, et un serveur qui redémarre récupère l'état du réseau pour le
+// rejoindre. Les serveurs élisent un leader (Bully ou Chang-Roberts) qui est réélu lorsqu'il disparaît.
+// Dans le mode de cohérence Raft, les commandes sont ajoutées à un journal répliqué par le leader Raft au lieu de
+// passer par la section critique distribuée. Un snapshot global du réseau peut être enregistré avec l'algorithme de
+// Chandy-Lamport.
 // Au démarrage, le serveur charge une configuration depuis un fichier config.json.
-// Il charge ensuite les utilisateurs et les événements depuis un fichier entities.json.
+// Il charge ensuite les utilisateurs et les événements depuis un fichier entities.json, ou depuis son dossier des
+// données s'il les a enregistrés avant de redémarrer. Les commandes y accèdent à travers un repository, en mémoire ou
+// dans un fichier clé-valeur selon la configuration.
 package server
 
 import (
 	"bufio"
 	_ "embed"
 	"encoding/json"
-	"fmt"
+	"errors"
 	"log"
 	"net"
+	"os"
+	"os/signal"
 	"strconv"
 	"strings"
-	"text/tabwriter"
+	"sync"
+	"sync/atomic"
+	"syscall"
 	"time"
 
+	"github.com/Lazzzer/labo1-sdr/internal/repository"
 	"github.com/Lazzzer/labo1-sdr/internal/utils"
 	"github.com/Lazzzer/labo1-sdr/internal/utils/types"
 )
 
 //go:embed entities.json
-var entities string                             // variable qui permet de charger le fichier des entités dans les binaries finales de l'application
-var users, events = utils.GetEntities(entities) // charge les utilisateurs et les événements depuis le fichier entities.json
+var entities string // variable qui permet de charger le fichier des entités dans les binaries finales de l'application
 
-// Channels utilisés pour la réception des inputs et actions liées aux clients
-var inputChan = make(chan string, 1) // channel récupérant l'entrée d'un client connecté
-var resChan = make(chan string, 1)   // channel stockant la réponse du serveur à un input d'un client
-var quitChan = make(chan bool, 1)    // channel permettant de terminer une session d'un client
+// CreateResource est la ressource de la section critique distribuée protégeant la création de manifestations. Les autres
+// ressources correspondent à l'id de la manifestation qu'elles protègent.
+const CreateResource = 0
 
-// Channels utilisés pour traiter les communications pour l'algorithme de Lamport dans la goroutine principale
-var reqChan = make(chan bool, 1)                 // Envoi d'un REQ aux autres serveurs
-var accessChan = make(chan bool, 1)              // Accès à la section critique de l'algorithme de Lamport
-var relChan = make(chan bool, 1)                 // Envoi d'un REL aux autres serveurs
-var commChan = make(chan types.Communication, 1) // Réception des communications des autres serveurs (REQ, REL, ACK)
+// access représente la demande d'accès d'une commande à une ressource de la section critique distribuée.
+type access struct {
+	resource int       // Ressource demandée
+	granted  chan bool // Accès accordé à la commande
+}
 
-var hasAccess = false // Booléen représentant la possession de la section critique de l'algorithme de Lamport
+// release représente la libération d'une ressource de la section critique distribuée.
+type release struct {
+	resource int               // Ressource libérée
+	ops      []types.Operation // Opérations effectuées pendant la section critique
+}
 
-// Server est une struct représentant un serveur TCP.
+// Server est une struct représentant un serveur TCP. Un serveur doit être créé avec NewServer, chaque serveur possédant
+// ses propres manifestations et channels, ce qui permet de lancer plusieurs serveurs dans un même processus.
 type Server struct {
-	Number     int                         // numéro du serveur
-	Port       string                      // port sur lequel le serveur écoute
-	ClientPort string                      // port sur lequel le serveur écoute les connexions des clients
-	Config     types.ServerConfig          // Configuration du serveur
-	Stamp      int                         // Estampille actuelle du serveur
-	conns      map[int]net.Conn            // Map de connexions des serveurs
-	comms      map[int]types.Communication // Map des dernières communications entre les serveurs
+	Number      int                   // numéro du serveur
+	Port        string                // port sur lequel le serveur écoute
+	ClientPort  string                // port sur lequel le serveur écoute les connexions des clients
+	Config      types.ServerConfig    // Configuration du serveur
+	Transport   Transport             // Couche réseau utilisée pour communiquer avec les autres serveurs, TCP si nil
+	Exit        func(int)             // Arrête le programme après que le serveur a quitté le réseau, os.Exit si nil
+	Repository  repository.Repository // Utilisateurs et manifestations, accédés uniquement par la goroutine principale
+	Stamp       int                   // Estampille actuelle du serveur
+	conns       map[int]net.Conn      // Map de connexions des serveurs
+	mutexes     map[int]Mutex         // Algorithme d'exclusion mutuelle distribuée de chaque ressource
+	tree        map[int]int           // Parent de chaque serveur dans l'arbre logique de Raymond
+	waiting     map[int][]chan bool   // Commandes attendant l'accès à chaque ressource, la première l'ayant demandé à son algorithme
+	eventStamps map[int]int           // Estampille de la dernière modification de chaque manifestation
+	nbMessages  int                   // Nombre de messages envoyés aux autres serveurs
+	nbAccesses  int                   // Nombre d'accès à la section critique distribuée
+
+	peerStates map[int]types.PeerState // État de chaque autre serveur selon le détecteur de défaillances
+	lastSeen   map[int]time.Time       // Date de la dernière communication reçue de chaque autre serveur
+	redialing  map[int]bool            // Serveurs défaillants en train d'être recontactés
+	instance   int64                   // Instance du serveur, qui change à chaque redémarrage
+	instances  map[int]int64           // Instance de chaque serveur connecté
+	running    bool                    // Indique si le serveur a terminé son initialisation
+	rejoined   bool                    // Indique si le serveur a rejoint un réseau déjà en fonctionnement
+
+	usedResources map[int]bool // Ressources déjà utilisées par le réseau lorsque le serveur l'a rejoint
+	left          bool         // Indique si le serveur a quitté le réseau et ne doit plus recontacter les autres serveurs
+	election      Election     // Algorithme d'élection du leader
+	raft          *raftNode    // Journal répliqué du mode de cohérence Raft, nil dans le mode par défaut
+
+	ops         []types.Operation // Opérations effectuées par la commande en cours d'exécution
+	releasedOps []types.Operation // Opérations de la section critique en cours de libération
+	pendingOps  []pendingOp       // Opérations reçues qui ne peuvent pas encore être appliquées
+
+	clock       types.VectorClock         // Horloge vectorielle du serveur, si activée dans la configuration
+	eventClocks map[int]types.VectorClock // Horloge vectorielle de la dernière modification de chaque manifestation
+	nbConflicts int                       // Nombre de modifications concurrentes détectées
+
+	snapshots map[string]*snapshot // Snapshots globaux en cours d'enregistrement, par identifiant
+
+	queue      chan *request  // File des commandes des clients en attente de traitement
+	commands   sync.WaitGroup // Commandes de la file en cours de traitement
+	nbSessions atomic.Int64   // Nombre de sessions de clients ouvertes depuis le démarrage du serveur
+	hub        hub            // Clients abonnés aux modifications des manifestations
+
+	HTTPPort       string       // Port sur lequel la passerelle HTTP écoute, désactivée si vide
+	nbHTTPRequests atomic.Int64 // Nombre de requêtes reçues par la passerelle HTTP
+
+	DataDir string   // Dossier des données persistantes du serveur, aucune persistance si vide
+	storage *storage // Journal des modifications et checkpoints, nil sans persistance
+
+	// Channels utilisés pour traiter les communications de l'exclusion mutuelle distribuée dans la goroutine principale.
+	// Les demandes et libérations ne sont pas bufferisées afin d'être traitées dans l'ordre de leur émission. Les
+	// communications reçues ne le sont pas non plus, la fermeture d'une connexion n'étant ainsi traitée qu'après la
+	// dernière communication reçue sur celle-ci (par exemple le LEV d'un serveur qui quitte le réseau).
+	reqChan   chan access              // Demande d'accès à une ressource de la section critique distribuée
+	relChan   chan release             // Libération d'une ressource de la section critique distribuée
+	commChan  chan types.Communication // Réception des communications des autres serveurs (REQ, REL, ACK)
+	execChan  chan func()              // Exécution d'une fonction accédant aux manifestations
+	downChan  chan join                // Serveurs dont la connexion a été fermée
+	joinChan  chan join                // Serveurs qui se sont connectés au serveur
+	stateChan chan peerState           // États des serveurs recontactés après leur défaillance
+	leaveChan chan bool                // Demandes de quitter le réseau
+}
+
+// NewServer crée un serveur à partir de son numéro, de ses ports, de sa configuration et des entités qu'il gère. Les
+// entités sont conservées dans un repository en mémoire, qui peut être remplacé avant le lancement du serveur.
+//
+// Les maps de la configuration modifiées par le serveur lorsque des serveurs rejoignent ou quittent le réseau sont
+// copiées, une même configuration pouvant être partagée par plusieurs serveurs d'un même processus.
+func NewServer(number int, port, clientPort string, config types.ServerConfig, entities types.Entities) *Server {
+	config.Servers = utils.CopyMap(config.Servers)
+	config.ClientPorts = utils.CopyMap(config.ClientPorts)
+
+	return &Server{
+		Number:     number,
+		Port:       port,
+		ClientPort: clientPort,
+		Config:     config,
+		Repository: repository.NewMemory(entities),
+		instance:   time.Now().UnixNano(),
+		queue:      make(chan *request, queueSize),
+		reqChan:    make(chan access),
+		relChan:    make(chan release),
+		commChan:   make(chan types.Communication),
+		execChan:   make(chan func()),
+		downChan:   make(chan join),
+		joinChan:   make(chan join),
+		stateChan:  make(chan peerState),
+		leaveChan:  make(chan bool),
+	}
+}
+
+// DefaultEntities retourne une nouvelle copie des utilisateurs et des manifestations du fichier entities.json intégré
+// au programme.
+func DefaultEntities() types.Entities {
+	users, events := utils.GetEntities(entities)
+	return types.Entities{Users: users, Events: events}
 }
 
 // Run lance le serveur et attend les connexions des clients.
@@ -63,7 +170,7 @@ func (s *Server) Run() {
 	var srvListener net.Listener
 	var clientListener net.Listener
 
-	srvListener, err = net.Listen("tcp", ":"+s.Port)
+	srvListener, err = s.transport().Listen(s.address())
 	if err != nil {
 		log.Fatal(err)
 	}
@@ -77,32 +184,43 @@ func (s *Server) Run() {
 		log.Fatal(err)
 	}
 
-	// Traite les inputs des différents clients un par un
+	// La passerelle HTTP ajoute ses commandes à la même file que les clients TCP
+	if s.HTTPPort != "" {
+		s.log(types.INFO, "Listening for HTTP requests on port "+s.HTTPPort)
+		go func() {
+			log.Fatal(s.serveHTTP(s.HTTPPort))
+		}()
+	}
+
+	// Traite chaque commande de la file dans sa propre goroutine, les commandes portant sur des ressources différentes
+	// accédant ainsi en même temps à la section critique distribuée. Un signal d'arrêt ou un appel à Leave fait quitter
+	// le réseau au serveur une fois les commandes en cours terminées.
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
 	go func() {
 		for {
-			input := <-inputChan
-			s.processCommand(input)
+			select {
+			case req := <-s.queue:
+				s.commands.Add(1)
+				go func() {
+					defer s.commands.Done()
+					s.processCommand(req)
+				}()
+			case <-signals:
+				s.leave()
+			case <-s.leaveChan:
+				s.leave()
+			}
 		}
 	}()
 
-	// Boucle acceptant les connexions des clients
+	// Boucle acceptant les connexions des clients, chacune gérée par sa propre session
 	for {
 		conn, err := clientListener.Accept()
 		if err != nil {
 			s.log(types.ERROR, err.Error())
 		} else {
-			reader := bufio.NewReader(conn)
-
-			// Récupère le nom du client
-			nameStr, err := reader.ReadString('\n')
-			if err != nil {
-				s.log(types.ERROR, err.Error())
-			}
-
-			name := strings.TrimSuffix(nameStr, "\n")
-			s.log(types.INFO, utils.GREEN+name+" connected"+utils.RESET)
-
-			go s.handleClientConns(conn, name)
+			go s.handleSession(&session{id: s.nbSessions.Add(1), conn: conn})
 		}
 	}
 }
@@ -111,117 +229,245 @@ func (s *Server) Run() {
 // La méthode s'assure que le serveur ait une connexion (en tant que client ou serveur) avec tous les autres serveurs
 // présents dans sa configuration. Pour cela, s'il n'arrive pas à se connecter à un serveur, il passe en mode "server" et
 // attend que les autres serveurs se connectent à lui.
+//
+// Chaque serveur contacté répond avec son état (STA), qui contient notamment les serveurs membres du réseau qu'il
+// connaît. Les membres absents de la configuration sont contactés à leur tour. Si l'un des serveurs fait déjà partie
+// d'un réseau en fonctionnement, le serveur a redémarré ou est ajouté au réseau : il adopte les manifestations et
+// l'estampille du réseau et n'attend pas les serveurs manquants, qui sont considérés comme défaillants.
 // Finalement, il lance une goroutine qui va traiter les communications entre les serveurs.
 func (s *Server) initServersConns(listener net.Listener) {
 	s.conns = make(map[int]net.Conn, len(s.Config.Servers)-1)
-	nbSuccessConn := 0
+	s.instances = make(map[int]int64, len(s.Config.Servers)-1)
+	readers := make(map[int]*bufio.Reader, len(s.Config.Servers)-1)
+
+	// Initialise l'estampille et les algorithmes d'exclusion mutuelle, créés à la première utilisation d'une ressource
+	s.Stamp = 0
+	s.mutexes = make(map[int]Mutex)
+	s.waiting = make(map[int][]chan bool)
+	s.eventStamps = make(map[int]int)
+	s.usedResources = make(map[int]bool)
+	s.clock = make(types.VectorClock)
+	s.eventClocks = make(map[int]types.VectorClock)
+	s.snapshots = make(map[string]*snapshot)
+
+	// L'arbre logique de Raymond est vérifié une seule fois, avant de contacter les autres serveurs, la configuration
+	// pouvant avoir été créée sans passer par utils.LoadConfig
+	if s.Config.Mutex == types.Raymond {
+		s.tree = utils.RaymondTree(s.Config)
+		if err := utils.ValidateTree(s.tree, s.Config.Servers); err != nil {
+			log.Fatal(err)
+		}
+	}
 
-	// Se connecte à chaque serveur déjà en ligne
-	for number := 1; number <= len(s.Config.Servers); number++ {
-		if number != s.Number {
-			conn, err := net.Dial("tcp", s.Config.Servers[number])
+	// Restaure les manifestations enregistrées avant de contacter les autres serveurs, qui transmettent ensuite les
+	// modifications plus récentes
+	s.recoverStorage()
+
+	// Les connexions des autres serveurs sont acceptées pendant toute la durée de vie du serveur
+	go s.acceptServersConns(listener)
+
+	// Se connecte à chaque serveur déjà en ligne, y compris aux membres découverts dans l'état des serveurs contactés.
+	// Les serveurs qui se connectent pendant l'attente des états sont intégrés en même temps, deux serveurs pouvant se
+	// contacter mutuellement.
+	dialed := map[int]net.Conn{}
+	tried := map[int]bool{s.Number: true}
+	for {
+		var pending []int
+		for _, number := range utils.MapKeysToArray(s.Config.Servers) {
+			if _, connected := s.conns[number]; !tried[number] && !connected {
+				pending = append(pending, number)
+			}
+		}
+		if len(pending) == 0 {
+			break
+		}
+
+		states := make(chan peerState, len(pending))
+		nbStates := 0
+		for _, number := range pending {
+			tried[number] = true
+			conn, err := s.transport().Dial(s.Config.Servers[number])
 			if err != nil {
 				s.log(types.INFO, utils.RED+"Server #"+strconv.Itoa(s.Number)+" could not connect to Server #"+strconv.Itoa(number)+utils.RESET)
 				continue
-			} else {
-				// Ajout de la connexion établie dans la map
-				s.log(types.INFO, utils.GREEN+"Server #"+strconv.Itoa(s.Number)+" connected to Server #"+strconv.Itoa(number)+utils.RESET)
-				s.conns[number] = conn
-				nbSuccessConn++
-				// Envoi de son numéro au serveur qui écoute sa connexion
-				_, err = conn.Write([]byte(strconv.Itoa(s.Number) + "\n"))
-				if err != nil {
-					s.log(types.ERROR, err.Error())
-				}
+			}
+
+			s.log(types.INFO, utils.GREEN+"Server #"+strconv.Itoa(s.Number)+" connected to Server #"+strconv.Itoa(number)+utils.RESET)
+			s.conns[number] = conn
+			readers[number] = bufio.NewReader(conn)
+			dialed[number] = conn
+			// Envoi de son numéro, de son adresse et de son instance au serveur qui écoute sa connexion
+			if _, err = conn.Write([]byte(s.handshake())); err != nil {
+				s.log(types.ERROR, err.Error())
+			}
+			nbStates++
+			go readState(number, conn, readers[number], states)
+		}
+
+		// Récupère l'état des serveurs contactés
+		for nbStates > 0 {
+			select {
+			case state := <-states:
+				nbStates--
+				s.receiveState(state, readers)
+			case j := <-s.joinChan:
+				s.admit(j, readers, dialed)
 			}
 		}
 	}
 
 	// Se met en mode attente de connexion des autres serveurs s'il n'arrive plus à se connecter à un serveur
-	if nbSuccessConn < len(s.Config.Servers)-1 {
+	if !s.rejoined && len(s.conns) < len(s.Config.Servers)-1 {
 		s.log(types.INFO, "Listening for missing servers connections")
-		for nbSuccessConn < len(s.Config.Servers)-1 {
-			conn, err := listener.Accept()
-			if err != nil {
-				log.Fatal(err)
-			} else {
-				s.handleHandshake(conn)
-				nbSuccessConn++
-			}
+		for len(s.conns) < len(s.Config.Servers)-1 {
+			s.admit(<-s.joinChan, readers, dialed)
 		}
 	}
 
-	// Initialise l'estampille et la map des communications avec des REL0
-	s.Stamp = 0
-	s.comms = make(map[int]types.Communication, len(s.Config.Servers))
+	ticker := s.initFailureDetector()
+	if s.Config.Consistency == types.RaftConsistency {
+		s.raft = newRaftNode(s)
+		s.election = s.raft
+	} else {
+		s.election = newElection(s)
+	}
+	s.running = true
 
-	for number := 1; number <= len(s.Config.Servers); number++ {
-		s.comms[number] = types.Communication{
-			Type:  types.Release,
-			Stamp: 0,
-		}
+	// Lance une goroutine pour chaque serveur connecté qui gère les communications entrantes de synchronisation. Elles
+	// sont lancées avant la boucle principale, qui peut retirer des connexions de la map dès son premier heartbeat.
+	for number, conn := range s.conns {
+		go s.handleIncomingComms(number, readers[number], conn)
 	}
 
-	// Lance la goroutine exécutant la boucle principale de l'algorithme de Lamport
+	// Lance la goroutine exécutant la boucle principale des algorithmes d'exclusion mutuelle et d'élection. Elle est la
+	// seule à accéder au repository des manifestations.
 	go func() {
+		s.election.Start()
 		for {
 			select {
-			case <-reqChan: // Demande d'accès à la section critique
-				if len(s.Config.Servers) == 1 {
-					accessChan <- true
-					continue
+			case req := <-s.reqChan: // Demande d'accès à la section critique, transmise à l'algorithme si aucune autre commande ne l'attend
+				s.waiting[req.resource] = append(s.waiting[req.resource], req.granted)
+				if len(s.waiting[req.resource]) == 1 {
+					s.getMutex(req.resource).Acquire()
 				}
+			case rel := <-s.relChan: // Libération de la section critique
 				s.Stamp++
-				s.sendComm(types.Request, utils.MapKeysToArray(s.conns), nil)
-			case <-accessChan: // Permission de l'accès à la section critique
-				hasAccess = true
-			case <-relChan: // Libération de la section critique
-				hasAccess = false
-				s.Stamp++
-				s.sendComm(types.Release, utils.MapKeysToArray(s.conns), &events)
-			case comm := <-commChan: // Traitement d'une communication reçue
+				s.tickClock()
+				for i := range rel.ops {
+					rel.ops[i].Stamp = s.Stamp
+					rel.ops[i].Clock = s.stampEventClock(rel.ops[i].EventId)
+					s.setEventStamp(rel.ops[i].EventId, s.Stamp)
+				}
+				s.releasedOps = rel.ops
+				s.getMutex(rel.resource).Release()
+				s.releasedOps = nil
+				s.nbAccesses++
+				s.log(types.LAMPORT, "MESSAGES SENT: "+strconv.Itoa(s.nbMessages)+" FOR "+strconv.Itoa(s.nbAccesses)+" ACCESS(ES)")
+
+				// La commande suivante attendant la ressource la demande à son tour
+				s.waiting[rel.resource] = s.waiting[rel.resource][1:]
+				if len(s.waiting[rel.resource]) > 0 {
+					s.getMutex(rel.resource).Acquire()
+				} else {
+					delete(s.waiting, rel.resource)
+				}
+			case comm := <-s.commChan: // Traitement d'une communication reçue
+				if !s.receiveFrom(comm.From) {
+					break
+				}
+				s.receiveClock(comm)
+				s.recordInFlight(comm)
 				switch comm.Type {
-				case types.Request:
-					s.handleRequest(comm)
-				case types.Acknowledge:
-					s.handleAcknowledge(comm)
-				case types.Release:
-					s.handleRelease(comm)
+				case types.Heartbeat:
+				case types.Marker:
+					s.handleMarker(comm)
+				case types.Leave:
+					s.removeMember(comm.From)
+				case types.Election, types.Answer, types.Coordinator,
+					types.RequestVote, types.VoteReply, types.AppendEntries, types.AppendReply, types.Forward:
+					s.election.HandleMessage(comm)
+				case types.SyncRequest, types.SyncReply:
+					s.handleSync(comm)
+				default:
+					s.getMutex(comm.Resource).HandleMessage(comm)
 				}
+			case <-ticker.C: // Envoi des heartbeats, détection des serveurs défaillants, relance des élections sans réponse et des opérations manquées
+				s.heartbeat()
+				s.redialPeers()
+				s.election.Tick()
+				s.checkPendingOps()
+			case down := <-s.downChan: // Fermeture de la connexion d'un serveur
+				s.confirmDown(down.number, down.conn)
+			case j := <-s.joinChan: // Connexion d'un serveur ayant redémarré ou ayant été suspecté
+				s.readmit(j)
+			case state := <-s.stateChan: // État d'un serveur défaillant recontacté
+				s.reconnect(state)
+			case exec := <-s.execChan: // Accès aux manifestations par une commande
+				exec()
 			}
 		}
 	}()
+}
 
-	// Lance une goroutine pour chaque serveur connecté qui gère les communications entrantes de synchronisation de l'algorithme de Lamport
-	for _, conn := range s.conns {
-		go s.handleIncomingComms(conn)
+// acceptServersConns accepte les connexions des autres serveurs et transmet chaque serveur identifié à la goroutine
+// qui l'intègre au réseau. L'écoute s'arrête lorsque la couche réseau ferme le listener.
+func (s *Server) acceptServersConns(listener net.Listener) {
+	for {
+		conn, err := listener.Accept()
+		if errors.Is(err, net.ErrClosed) {
+			return
+		}
+		if err != nil {
+			log.Fatal(err)
+		}
+		go s.handleHandshake(conn)
 	}
 }
 
 // handleHandshake gère la première communication d'un serveur qui reçoit la connexion d'un autre serveur. Cette méthode sert surtout
-// à récupérer le numéro du serveur "client" pour pouvoir l'ajouter à la liste des connexions du serveur.
+// à récupérer le numéro et l'adresse du serveur "client" pour pouvoir l'ajouter à la liste des connexions du serveur.
 func (s *Server) handleHandshake(conn net.Conn) {
 	reader := bufio.NewReader(conn)
 
-	// Récupère le numéro du serveur
-	numberStr, err := reader.ReadString('\n')
+	// Récupère le numéro et l'adresse du serveur
+	handshake, err := reader.ReadString('\n')
 	if err != nil {
-		log.Fatal(err)
+		s.log(types.ERROR, err.Error())
+		return
 	}
 
-	number, err := strconv.Atoi(strings.TrimSuffix(numberStr, "\n"))
-	if err != nil {
-		log.Fatal(err)
+	fields := strings.Fields(handshake)
+	number := 0
+	if len(fields) > 0 {
+		number, err = strconv.Atoi(fields[0])
+	}
+	if err != nil || number <= 0 || number == s.Number {
+		s.log(types.ERROR, "Invalid handshake from "+conn.RemoteAddr().String())
+		if err := conn.Close(); err != nil {
+			s.log(types.ERROR, err.Error())
+		}
+		return
 	}
 
-	s.log(types.INFO, utils.GREEN+"Server #"+strconv.Itoa(s.Number)+" received a connection from Server #"+strings.TrimSuffix(numberStr, "\n")+utils.RESET)
-	s.conns[number] = conn
+	j := join{number: number, conn: conn, reader: reader}
+	if len(fields) > 1 {
+		j.address = fields[1]
+	}
+	if len(fields) > 2 {
+		j.instance, _ = strconv.ParseInt(fields[2], 10, 64)
+	}
+	s.joinChan <- j
 }
 
-// handleIncomingComms gère les communications entrantes des autres serveurs.
-func (s *Server) handleIncomingComms(conn net.Conn) {
-	reader := bufio.NewReader(conn)
+// handshake retourne la première communication envoyée sur une connexion à un autre serveur, contenant le numéro,
+// l'adresse et l'instance du serveur.
+func (s *Server) handshake() string {
+	return strconv.Itoa(s.Number) + " " + s.Config.Address + " " + strconv.FormatInt(s.instance, 10) + "\n"
+}
 
+// handleIncomingComms gère les communications entrantes d'un autre serveur. La fermeture de la connexion est signalée
+// au détecteur de défaillances.
+func (s *Server) handleIncomingComms(number int, reader *bufio.Reader, conn net.Conn) {
 	for {
 		input, err := reader.ReadString('\n')
 		if err != nil {
@@ -236,216 +482,356 @@ func (s *Server) handleIncomingComms(conn net.Conn) {
 			break
 		}
 
-		commChan <- comm
+		s.commChan <- comm
 	}
 
 	if err := conn.Close(); err != nil {
 		s.log(types.ERROR, err.Error())
 	}
+	s.downChan <- join{number: number, conn: conn}
 }
 
-// ---------- Méthodes concernant les communications serveurs-serveurs & Lamport ----------
+// ---------- Méthodes concernant les communications serveurs-serveurs ----------
 
-// verifyCriticalSection vérifie si le serveur peut accéder à la section critique distribuée selon l'algorithme de Lamport.
-func (s *Server) verifyCriticalSection() {
-	if hasAccess {
-		return
-	}
-
-	if s.comms[s.Number].Type != types.Request {
-		return
+// getMutex retourne l'algorithme d'exclusion mutuelle d'une ressource et le crée s'il n'existe pas encore.
+func (s *Server) getMutex(resource int) Mutex {
+	mutex, ok := s.mutexes[resource]
+	if !ok {
+		mutex = newMutex(s, resource)
+		s.mutexes[resource] = mutex
 	}
+	return mutex
+}
 
-	hasOldestReq := true
-	for i := 1; i <= len(s.Config.Servers); i++ {
-		if i == s.Number {
-			continue
-		}
-		if s.comms[s.Number].Stamp > s.comms[i].Stamp || (s.comms[s.Number].Stamp == s.comms[i].Stamp && s.Number > s.comms[i].From) {
-			hasOldestReq = false
-			break
-		}
-	}
-	if hasOldestReq {
-		accessChan <- true
+// grantAccess signale l'accès à une ressource à la commande qui l'a demandé à son algorithme d'exclusion mutuelle. La
+// méthode est appelée par les algorithmes lorsqu'ils accordent l'accès à la section critique.
+func (s *Server) grantAccess(resource int) {
+	if waiting := s.waiting[resource]; len(waiting) > 0 {
+		waiting[0] <- true
+	} else {
+		s.log(types.ERROR, "Access granted on "+resourceToString(resource)+" without a waiting command")
 	}
 }
 
-// sendComm prépare et envoie une communication à un ou plusieurs serveurs. Cette communication est envoyée en JSON et
-// est stockée dans la map des communications du serveur à son propre index. La méthode peut prendre la map des
-// manifestations (dans le cas d'un REL par exemple) pour communiquer aux autres serveurs la version à jour de l'entité.
-func (s *Server) sendComm(commType types.CommunicationType, to []int, payload *map[int]types.Event) {
+// sendComm prépare et envoie une communication concernant une ressource à un ou plusieurs serveurs. La communication
+// peut contenir la mise à jour des manifestations protégées par la ressource pour communiquer aux autres serveurs leur
+// version à jour : les opérations effectuées pendant la section critique pour un REL, les manifestations complètes sinon.
+func (s *Server) sendComm(commType types.CommunicationType, resource int, to []int, withPayload bool) {
 	communication := types.Communication{
-		Type:  commType,
-		From:  s.Number,
-		To:    to,
-		Stamp: s.Stamp,
+		Type:     commType,
+		From:     s.Number,
+		To:       to,
+		Stamp:    s.Stamp,
+		Resource: resource,
 	}
-	if payload == nil {
-		communication.Payload = nil
-	} else {
-		communication.Payload = *payload
+	if withPayload && commType == types.Release {
+		s.attachOps(&communication)
+	} else if withPayload {
+		s.attachPayload(&communication)
 	}
 
-	s.comms[s.Number] = communication
-	s.log(types.LAMPORT, "STATUS: "+s.commsToString()+" OUT "+string(communication.Type)+strconv.Itoa(communication.Stamp)+" TO "+utils.IntToString(communication.To))
+	s.send(communication)
+}
+
+// send envoie une communication déjà préparée en JSON à chacun de ses destinataires et comptabilise les messages envoyés.
+func (s *Server) send(communication types.Communication) {
+	s.tickClock()
+	s.attachClock(&communication)
+	s.log(types.LAMPORT, "STATUS: "+s.getMutex(communication.Resource).Status()+" OUT "+string(communication.Type)+strconv.Itoa(communication.Stamp)+" TO "+utils.IntToString(communication.To)+" ON "+resourceToString(communication.Resource)+s.logClock(communication.Clock))
 
 	communicationJson, err := json.Marshal(communication)
 	if err != nil {
 		s.log(types.ERROR, err.Error())
 	}
 
-	for _, number := range to {
+	for _, number := range communication.To {
 		_, err := s.conns[number].Write([]byte(string(communicationJson) + "\n"))
 		if err != nil {
 			s.log(types.ERROR, err.Error())
 		}
+		s.nbMessages++
 	}
 }
 
-// handleRequest gère la réception d'une requête (REQ) d'accès à la section critique distribuée. Avec Lamport optimisée,
-// si le serveur actuel contient déjà une requête, il ne fait rien. Sinon, il envoie un ACK au serveur qui a envoyé la REQ.
-// Dans les deux cas, le serveur vérifie si il peut accéder à la section critique.
-func (s *Server) handleRequest(comm types.Communication) {
-
-	s.Stamp = utils.Max(s.Stamp, comm.Stamp) + 1
-	s.comms[comm.From] = comm
-	s.log(types.LAMPORT, "STATUS: "+s.commsToString()+" IN  "+string(comm.Type)+strconv.Itoa(comm.Stamp)+" FROM S"+strconv.Itoa(comm.From))
-
-	if s.comms[s.Number].Type != types.Request {
-		s.sendComm(types.Acknowledge, []int{comm.From}, nil)
+// sendControl envoie une communication ne concernant aucune ressource (HBT, LVE) à chacun de ses destinataires, sans
+// l'afficher ni la comptabiliser dans les messages de l'exclusion mutuelle.
+func (s *Server) sendControl(communication types.Communication) {
+	s.attachClock(&communication)
+	communicationJson, err := json.Marshal(communication)
+	if err != nil {
+		s.log(types.ERROR, err.Error())
+		return
 	}
 
-	s.verifyCriticalSection()
+	for _, number := range communication.To {
+		if _, err := s.conns[number].Write([]byte(string(communicationJson) + "\n")); err != nil {
+			s.log(types.ERROR, err.Error())
+		}
+	}
 }
 
-// handleAcknowledge gère la réception d'un ACK d'accès à la section critique distribuée. Si le serveur actuel contient
-// déjà une requête, il s'occupe seulement de vérifier s'il a accès à la section critique.
-func (s *Server) handleAcknowledge(comm types.Communication) {
-	s.Stamp = utils.Max(s.Stamp, comm.Stamp) + 1
-	if s.comms[comm.From].Type != types.Request {
-		s.comms[comm.From] = comm
-		s.log(types.LAMPORT, "STATUS: "+s.commsToString()+" IN  "+string(comm.Type)+strconv.Itoa(comm.Stamp)+" FROM S"+strconv.Itoa(comm.From))
+// attachPayload ajoute à une communication les manifestations protégées par sa ressource ainsi que l'estampille de
+// leur dernière modification. La ressource de création transporte toutes les manifestations pour que son détenteur
+// connaisse tous les ids déjà attribués.
+func (s *Server) attachPayload(comm *types.Communication) {
+	comm.Payload = make(map[int]types.Event)
+	comm.Stamps = make(map[int]int)
+
+	if comm.Resource == CreateResource {
+		for id, event := range s.Repository.Events() {
+			comm.Payload[id] = event
+			comm.Stamps[id] = s.eventStamps[id]
+		}
+	} else if event, ok := s.Repository.Event(comm.Resource); ok {
+		comm.Payload[comm.Resource] = event
+		comm.Stamps[comm.Resource] = s.eventStamps[comm.Resource]
 	}
 
-	s.verifyCriticalSection()
+	if s.Config.VectorClock {
+		comm.Clocks = make(map[int]types.VectorClock)
+		for id := range comm.Payload {
+			if clock, ok := s.eventClocks[id]; ok {
+				comm.Clocks[id] = clock
+			}
+		}
+	}
 }
 
-// handleRelease gère la réception d'un REL d'accès à la section critique distribuée. Si le REL contient un payload,
-// le serveur met à jour sa map des manifestations. Finalement, le serveur vérifie s'il a accès à la section critique.
-func (s *Server) handleRelease(comm types.Communication) {
-	s.Stamp = utils.Max(s.Stamp, comm.Stamp) + 1
-	s.comms[comm.From] = comm
-	events = comm.Payload
-	s.log(types.LAMPORT, "STATUS: "+s.commsToString()+" IN  "+string(comm.Type)+strconv.Itoa(comm.Stamp)+" FROM S"+strconv.Itoa(comm.From))
+// mergePayload fusionne les manifestations reçues dans une communication avec celles du repository. Les
+// communications de différents serveurs pouvant arriver dans le désordre, une manifestation n'est remplacée que si
+// sa modification est plus récente que celle déjà connue.
+func (s *Server) mergePayload(comm types.Communication) {
+	for id, event := range comm.Payload {
+		replaced := comm.Stamps[id] >= s.eventStamps[id]
+		s.receiveEventClock(id, comm.Clocks[id], replaced)
+		if replaced {
+			s.setEvent(id, event)
+			s.setEventStamp(id, comm.Stamps[id])
+		}
+	}
+}
 
-	s.verifyCriticalSection()
+// logComm affiche l'état de l'algorithme d'exclusion mutuelle à la réception d'une communication.
+func (s *Server) logComm(comm types.Communication) {
+	s.log(types.LAMPORT, "STATUS: "+s.getMutex(comm.Resource).Status()+" IN  "+string(comm.Type)+strconv.Itoa(comm.Stamp)+" FROM S"+strconv.Itoa(comm.From)+" ON "+resourceToString(comm.Resource)+s.logClock(s.clock))
 }
 
 // ---------- Méthodes pour la gestion des clients et leurs commandes ----------
 
-// handleClientConns gère l'I/O avec un client connecté au serveur
-func (s *Server) handleClientConns(conn net.Conn, name string) {
-	reader := bufio.NewReader(conn)
-	for {
-		input, err := reader.ReadString('\n')
-		if err != nil {
-			s.log(types.ERROR, err.Error())
-			break
+// processCommand permet de traiter la commande d'un client et de lancer la méthode correspondante. La réponse est
+// envoyée dans le channel de la requête, qui est fermé sans réponse lorsque la commande "quit" est saisie. Une requête
+// n'est traitée qu'une fois la requête précédente du même client traitée.
+//
+// Les commandes de lecture sont servies depuis la copie locale des manifestations sans passer par la section critique
+// distribuée, sauf si l'option "--strong" est passée en argument. Les autres commandes obtiennent uniquement les
+// ressources de la section critique distribuée qu'elles utilisent, ou sont ajoutées au journal répliqué en mode Raft.
+func (s *Server) processCommand(req *request) {
+	if req.after != nil {
+		<-req.after
+	}
+	if req.done != nil {
+		defer close(req.done)
+	}
+
+	if !needsAccess(req.command, req.args) {
+		s.answer(req)
+		return
+	}
+
+	name := req.command
+	args := req.args
+	command, _ := utils.GetCommand(name)
+	if command.ReadOnly {
+		args, _ = strongRead(args)
+	}
+
+	s.debugTrace(true)
+
+	// En mode Raft, la commande est ajoutée au journal répliqué au lieu de passer par la section critique distribuée
+	if s.raft != nil {
+		req.response <- s.submit(name, args)
+		s.debugTrace(false)
+		return
+	}
+
+	// Les ressources sont toujours obtenues dans l'ordre croissant, ce qui évite les interblocages
+	resources := s.resources(command, args)
+	for i := 0; i < len(resources); i++ {
+		resource := resources[i]
+		granted := make(chan bool, 1)
+		s.reqChan <- access{resource: resource, granted: granted}
+		<-granted
+		s.log(types.LAMPORT, utils.GREEN+"ACCESSING DISTRIBUTED CRITICAL SECTION ON "+resourceToString(resource)+utils.RESET)
+
+		// La liste des manifestations n'est connue qu'après l'obtention de la ressource de création
+		if resource == CreateResource && command.ReadOnly {
+			resources = append(resources[:i+1], s.runOnEventIds()...)
 		}
+	}
 
-		s.log(types.INFO, utils.YELLOW+name+" -> "+strings.TrimSuffix(input, "\n")+utils.RESET)
-		inputChan <- input
+	// Commandes avec accès à la section critique
+	var ops []types.Operation
+	var response types.Response
+	s.runOnEvents(func() {
+		response = s.execute(name, args)
+		ops = s.ops
+		s.ops = nil
+	})
+
+	req.response <- response
+	for _, resource := range resources {
+		s.log(types.LAMPORT, utils.RED+"RELEASING DISTRIBUTED CRITICAL SECTION ON "+resourceToString(resource)+utils.RESET)
+		s.relChan <- release{resource: resource, ops: ops}
+		ops = nil
+	}
+	s.debugTrace(false)
+}
 
-		select {
-		case response := <-resChan:
-			_, err := conn.Write([]byte(response))
-			if err != nil {
-				s.log(types.ERROR, err.Error())
-			}
-		case <-quitChan:
-			s.log(types.INFO, utils.RED+name+" disconnected"+utils.RESET)
-			err := conn.Close()
-			if err != nil {
-				s.log(types.ERROR, err.Error())
-			}
+// answer répond à une commande qui n'accède pas à la section critique distribuée : les commandes d'administration, les
+// lectures sans l'option "--strong" et les commandes invalides.
+func (s *Server) answer(req *request) {
+	switch req.command {
+	case "":
+		req.response <- failure(types.EmptyCommand)
+	case utils.QUIT.Name:
+		close(req.response)
+	case utils.HELP.Name:
+		req.response <- s.help(req.args)
+	case utils.WATCH.Name:
+		req.response <- s.watch(req.sub, req.args)
+	default:
+		if _, ok := utils.GetCommand(req.command); !ok {
+			req.response <- failure(types.InvalidCommand)
 			return
 		}
+		req.response <- s.runCommand(req.command, req.args)
 	}
 }
 
-// processCommand permet de traiter l'entrée utilisateur et de lancer la méthode correspondante à la commande saisie.
-// La méthode notifie au serveur l'arrêt de sa boucle de traitement des commandes lorsque la commande "quit" est saisie.
-func (s *Server) processCommand(input string) {
-	args := strings.Fields(input)
+// needsAccess indique si une commande accède à la section critique distribuée, ou au journal répliqué en mode Raft :
+// les commandes d'écriture et les lectures avec l'option "--strong".
+func needsAccess(name string, args []string) bool {
+	switch name {
+	case utils.CREATE.Name, utils.CLOSE.Name, utils.REGISTER.Name:
+		return true
+	case utils.SHOW.Name, utils.JOBS.Name:
+		_, strong := strongRead(args)
+		return strong
+	}
+	return false
+}
 
-	if len(args) == 0 {
-		resChan <- "Empty command"
-		return
+// strongRead retire l'option "--strong" des arguments d'une commande de lecture et indique si elle était présente.
+// Seules les commandes de lecture acceptent cette option, un argument d'une commande d'écriture (nom de job, mot de
+// passe) pouvant avoir la même valeur.
+func strongRead(args []string) ([]string, bool) {
+	for i, arg := range args {
+		if arg == utils.STRONG_READ {
+			return append(append([]string{}, args[:i]...), args[i+1:]...), true
+		}
 	}
+	return args, false
+}
 
-	name := args[0]
-	args = args[1:]
+// resources retourne les ressources de la section critique distribuée nécessaires à une commande, triées dans l'ordre
+// croissant. Une commande dont l'id de manifestation est invalide n'a besoin d'aucune ressource car elle retourne
+// directement un message d'erreur.
+func (s *Server) resources(command types.Command, args []string) []int {
+	switch command.Name {
+	case utils.CREATE.Name:
+		return []int{CreateResource}
+	case utils.SHOW.Name:
+		if len(args) == 0 {
+			return []int{CreateResource}
+		}
+	}
 
-	// Commandes n'ayant pas besoin d'accès à la section critique
+	if len(args) == 0 {
+		return nil
+	}
+	if idEvent, err := strconv.Atoi(args[0]); err == nil && idEvent > 0 {
+		return []int{idEvent}
+	}
+	return nil
+}
 
-	switch name {
-	case utils.QUIT.Name:
-		quitChan <- true
-		return
-	case utils.HELP.Name:
-		resChan <- s.help(args)
-		return
+// runOnEvents fait exécuter une fonction par la goroutine principale, seule à accéder au repository des manifestations,
+// et attend la fin de son exécution.
+func (s *Server) runOnEvents(f func()) {
+	done := make(chan bool, 1)
+	s.execChan <- func() {
+		f()
+		done <- true
 	}
+	<-done
+}
 
-	s.debugTrace(true)
-	reqChan <- true
-	<-accessChan
-	s.log(types.LAMPORT, utils.GREEN+"ACCESSING DISTRIBUTED CRITICAL SECTION"+utils.RESET)
+// runCommand exécute une commande dans la goroutine principale sans passer par la section critique distribuée et
+// retourne sa réponse.
+func (s *Server) runCommand(name string, args []string) types.Response {
+	var response types.Response
+	s.runOnEvents(func() {
+		response = s.execute(name, args)
+	})
+	return response
+}
 
-	var response string
+// runOnEventIds retourne les ids de toutes les manifestations connues dans l'ordre croissant.
+func (s *Server) runOnEventIds() []int {
+	var ids []int
+	s.runOnEvents(func() {
+		for id := 1; id <= s.Repository.NbEvents(); id++ {
+			ids = append(ids, id)
+		}
+	})
+	return ids
+}
 
-	// Commandes avec accès à la section critique
+// execute lance la méthode correspondant au nom de la commande et retourne sa réponse.
+func (s *Server) execute(name string, args []string) types.Response {
 	switch name {
 	case utils.HELP.Name:
-		response = s.help(args)
+		return s.help(args)
 	case utils.CREATE.Name:
-		response = s.createEvent(args)
+		return s.createEvent(args)
 	case utils.CLOSE.Name:
-		response = s.close(args)
+		return s.close(args)
 	case utils.REGISTER.Name:
-		response = s.register(args)
+		return s.register(args)
 	case utils.SHOW.Name:
-		response = s.show(args)
+		return s.show(args)
 	case utils.JOBS.Name:
-		response = s.jobs(args)
+		return s.jobs(args)
+	case utils.PEERS.Name:
+		return s.showPeers(args)
+	case utils.LEADER.Name:
+		return s.showLeader(args)
+	case utils.STATUS.Name:
+		return s.showStatus(args)
+	case utils.SNAPSHOT.Name:
+		return s.takeSnapshot(args)
 	default:
-		response = utils.MESSAGE.Error.InvalidCommand
+		return failure(types.InvalidCommand)
 	}
-
-	s.log(types.LAMPORT, utils.RED+"RELEASING DISTRIBUTED CRITICAL SECTION"+utils.RESET)
-	resChan <- response
-	relChan <- true
-	s.debugTrace(false)
 }
 
 // ---------- Méthode pour chaque commande ----------
 
-// help est la méthode appelée par la commande "help" et affiche un message d'aide listant chaque commande et ses arguments.
-func (s *Server) help(args []string) string {
-	if msg, ok := s.checkNbArgs(args, &utils.HELP, false); !ok {
-		return msg
+// help est la méthode appelée par la commande "help" et retourne la liste des commandes et de leurs arguments.
+func (s *Server) help(args []string) types.Response {
+	if !s.checkNbArgs(args, &utils.HELP, false) {
+		return failure(types.InvalidNbArgs)
 	}
 
-	return utils.MESSAGE.Help
+	return types.Response{Command: utils.HELP.Name, Ok: true, Commands: utils.COMMANDS[:]}
 }
 
 // createEvent est la méthode appelée par la commande "create" et  permet de créer une manifestation et retourne un message de confirmation.
-// En cas d'échec de création, la méthode retourne un message d'erreur spécifique.
-func (s *Server) createEvent(args []string) string {
+// En cas d'échec de création, la méthode retourne une erreur spécifique.
+func (s *Server) createEvent(args []string) types.Response {
 
-	if msg, ok := s.checkNbArgs(args, &utils.CREATE, true); !ok {
-		return msg
+	if !s.checkNbArgs(args, &utils.CREATE, true) {
+		return failure(types.InvalidNbArgs)
 	}
 
 	username := args[len(args)-2]
@@ -454,7 +840,7 @@ func (s *Server) createEvent(args []string) string {
 	userId, okUser := s.verifyUser(username, password)
 
 	if !okUser {
-		return utils.MESSAGE.Error.AccessDenied
+		return failure(types.AccessDenied)
 	}
 
 	var nbVolunteersPerJob []int
@@ -463,7 +849,7 @@ func (s *Server) createEvent(args []string) string {
 	for i := 1; i < len(args)-utils.CREATE.MinOptArgs; i++ {
 		if i%utils.CREATE.MinOptArgs == 0 {
 			if nbVolunteer, err := strconv.Atoi(args[i]); err != nil || nbVolunteer < 0 {
-				return utils.MESSAGE.Error.NbVolunteersInteger
+				return failure(types.NbVolunteersInteger)
 			} else {
 				nbVolunteersPerJob = append(nbVolunteersPerJob, nbVolunteer)
 			}
@@ -472,7 +858,7 @@ func (s *Server) createEvent(args []string) string {
 		}
 	}
 
-	eventId := len(events) + 1
+	eventId := s.Repository.NbEvents() + 1
 	currentJobId := 1
 
 	newJobs := map[int]types.Job{}
@@ -487,17 +873,18 @@ func (s *Server) createEvent(args []string) string {
 	}
 
 	newEvent := types.Event{Name: args[0], CreatorId: userId, Jobs: newJobs}
-	events[eventId] = newEvent
+	s.setEvent(eventId, newEvent)
+	s.recordOp(types.Operation{Type: types.CreateOperation, EventId: eventId, Event: &newEvent})
 
-	return utils.MESSAGE.WrapSuccess("Event #" + strconv.Itoa(eventId) + " " + newEvent.Name + " and " + strconv.Itoa(len(newJobs)) + " job(s)" + " created\n")
+	return s.success(utils.CREATE.Name, eventId, "Event #"+strconv.Itoa(eventId)+" "+newEvent.Name+" and "+strconv.Itoa(len(newJobs))+" job(s)"+" created")
 }
 
 // closeEvent est la méthode appelée par la commande "close" et permet de fermer une manifestation et retourne un message de confirmation.
-// En cas d'échec de fermeture, la méthode retourne un message d'erreur spécifique.
-func (s *Server) close(args []string) string {
+// En cas d'échec de fermeture, la méthode retourne une erreur spécifique.
+func (s *Server) close(args []string) types.Response {
 
-	if msg, ok := s.checkNbArgs(args, &utils.CLOSE, false); !ok {
-		return msg
+	if !s.checkNbArgs(args, &utils.CLOSE, false) {
+		return failure(types.InvalidNbArgs)
 	}
 
 	idEvent, errEvent := strconv.Atoi(args[0])
@@ -505,29 +892,30 @@ func (s *Server) close(args []string) string {
 	password := args[2]
 
 	if errEvent != nil {
-		return utils.MESSAGE.Error.MustBeInteger
+		return failure(types.MustBeInteger)
 	}
 
 	userId, okUser := s.verifyUser(username, password)
 	if !okUser {
-		return utils.MESSAGE.Error.AccessDenied
+		return failure(types.AccessDenied)
 	}
 
-	errMsg, ok := s.closeEvent(idEvent, userId)
+	code, ok := s.closeEvent(idEvent, userId)
 
 	if !ok {
-		return errMsg
+		return failure(code)
 	}
+	s.recordOp(types.Operation{Type: types.CloseOperation, EventId: idEvent})
 
-	return utils.MESSAGE.WrapSuccess("Event #" + strconv.Itoa(idEvent) + " is closed.\n")
+	return s.success(utils.CLOSE.Name, idEvent, "Event #"+strconv.Itoa(idEvent)+" is closed.")
 }
 
 // register est la méthode appelée par la commande "register" et permet d'inscrire un utilisateur à un job d'une manifestation et retourne un message de confirmation.
-// En cas d'échec d'inscription, la méthode retourne un message d'erreur spécifique.
-func (s *Server) register(args []string) string {
+// En cas d'échec d'inscription, la méthode retourne une erreur spécifique.
+func (s *Server) register(args []string) types.Response {
 
-	if msg, ok := s.checkNbArgs(args, &utils.REGISTER, false); !ok {
-		return msg
+	if !s.checkNbArgs(args, &utils.REGISTER, false) {
+		return failure(types.InvalidNbArgs)
 	}
 
 	idEvent, errEvent := strconv.Atoi(args[0])
@@ -536,134 +924,207 @@ func (s *Server) register(args []string) string {
 	password := args[3]
 
 	if errEvent != nil || errJob != nil {
-		return utils.MESSAGE.Error.MustBeInteger
+		return failure(types.MustBeInteger)
 	}
 
 	userId, okUser := s.verifyUser(username, password)
 	if !okUser {
-		return utils.MESSAGE.Error.AccessDenied
+		return failure(types.AccessDenied)
 	}
 
-	event, okEvent := events[idEvent]
+	event, okEvent := s.Repository.Event(idEvent)
 
 	if !okEvent {
-		return utils.MESSAGE.Error.EventNotFound
+		return failure(types.EventNotFound)
 	} else if event.Closed {
-		return utils.MESSAGE.Error.EventClosed
+		return failure(types.EventClosed)
 	} else {
 		if event.CreatorId == userId {
-			return utils.MESSAGE.Error.CreatorRegister
+			return failure(types.CreatorRegister)
 		}
 	}
 
-	msg, okJob := s.addUserToJob(&event, idJob, userId)
+	code, okJob := s.addUserToJob(&event, idJob, userId)
 
 	if !okJob {
-		return msg
+		return failure(code)
 	}
-	return utils.MESSAGE.WrapSuccess("User registered in job #" + strconv.Itoa(idJob) + " for Event #" + strconv.Itoa(idEvent) + " " + event.Name + ".\n")
+	s.setEvent(idEvent, event)
+	s.recordOp(types.Operation{Type: types.RegisterOperation, EventId: idEvent, JobId: idJob, UserId: userId})
+	return s.success(utils.REGISTER.Name, idEvent, "User registered in job #"+strconv.Itoa(idJob)+" for Event #"+strconv.Itoa(idEvent)+" "+event.Name+".")
 }
 
-// show est la méthode appelée par la commande "show" et permet d'afficher les manifestations et leurs informations.
-// En passant un identifiant de manifestation en argument dans la commande, la méthode affiche les informations de la manifestation avec ses jobs.
-func (s *Server) show(args []string) string {
+// show est la méthode appelée par la commande "show" et permet de récupérer les manifestations et leurs informations.
+// En passant un identifiant de manifestation en argument dans la commande, la méthode retourne la manifestation avec ses jobs.
+func (s *Server) show(args []string) types.Response {
 	if len(args) == utils.SHOW.MinOptArgs {
 		idEvent, err := strconv.Atoi(args[0])
 		if err != nil {
-			return utils.MESSAGE.Error.MustBeInteger
+			return failure(types.MustBeInteger)
 		}
-		msg, _ := s.showEvent(idEvent)
-		return msg
+		if _, ok := s.Repository.Event(idEvent); !ok {
+			return failure(types.EventNotFound)
+		}
+		event := s.eventInfo(idEvent)
+		return types.Response{Command: utils.SHOW.Name, Ok: true, Event: &event}
 	} else if len(args) == 0 {
-		return s.showAllEvents()
+		nbEvents := s.Repository.NbEvents()
+		events := make([]types.EventInfo, 0, nbEvents)
+		for i := 1; i <= nbEvents; i++ {
+			events = append(events, s.eventInfo(i))
+		}
+		return types.Response{Command: utils.SHOW.Name, Ok: true, Events: events}
 	} else {
-		return utils.MESSAGE.Error.InvalidCommand
+		return failure(types.InvalidCommand)
 	}
 }
 
-// jobs est la méthode appelée par la commande "jobs" et permet d'afficher la répartition des bénévoles et des jobs d'une manifestation.
-func (s *Server) jobs(args []string) string {
-	if msg, ok := s.checkNbArgs(args, &utils.JOBS, false); !ok {
-		return msg
+// jobs est la méthode appelée par la commande "jobs" et permet de récupérer la répartition des bénévoles et des jobs d'une manifestation.
+func (s *Server) jobs(args []string) types.Response {
+	if !s.checkNbArgs(args, &utils.JOBS, false) {
+		return failure(types.InvalidNbArgs)
 	}
 
 	idEvent, errEvent := strconv.Atoi(args[0])
 	if errEvent != nil {
-		return utils.MESSAGE.Error.MustBeInteger
+		return failure(types.MustBeInteger)
 	}
 
-	event, ok := events[idEvent]
-	if !ok {
-		return utils.MESSAGE.Error.EventNotFound
+	if _, ok := s.Repository.Event(idEvent); !ok {
+		return failure(types.EventNotFound)
 	}
 
-	eventTitle := "#" + strconv.Itoa(idEvent) + " " + utils.BOLD + utils.CYAN + event.Name + utils.RESET + "\n\n"
-	firstLine := utils.BOLD + "Volunteers" + utils.RESET + "\t"
-	numberOfUsers := 0
-	allUsersWorking := make([][]string, len(event.Jobs))
-	for i := 1; i <= len(event.Jobs); i++ {
-		job := event.Jobs[i]
-		firstLine += "#" + strconv.Itoa(i) + " " + job.Name + " (" + strconv.Itoa(len(job.VolunteerIds)) + "/" + strconv.Itoa(job.NbVolunteers) + ")\t"
-		for _, userId := range job.VolunteerIds {
-			allUsersWorking[i-1] = append(allUsersWorking[i-1], users[userId].Username)
-			numberOfUsers++
+	event := s.eventInfo(idEvent)
+	return types.Response{Command: utils.JOBS.Name, Ok: true, Event: &event}
+}
+
+// watch est la méthode appelée par la commande "watch" et abonne le client aux modifications d'une manifestation, ou de
+// toutes les manifestations avec l'argument "all". Une manifestation pas encore créée peut être suivie, sa création
+// étant alors notifiée.
+func (s *Server) watch(sub *subscriber, args []string) types.Response {
+	if sub == nil {
+		return failure(types.InvalidCommand)
+	}
+	if !s.checkNbArgs(args, &utils.WATCH, false) {
+		return failure(types.InvalidNbArgs)
+	}
+
+	if args[0] == utils.WATCH_ALL {
+		s.follow(sub, 0)
+		return types.Response{Command: utils.WATCH.Name, Ok: true, Message: "Watching all events."}
+	}
+
+	idEvent, errEvent := strconv.Atoi(args[0])
+	if errEvent != nil || idEvent <= 0 {
+		return failure(types.MustBeInteger)
+	}
+
+	s.follow(sub, idEvent)
+	message := "Watching event #" + strconv.Itoa(idEvent) + "."
+	var response types.Response
+	s.runOnEvents(func() {
+		if _, ok := s.Repository.Event(idEvent); ok {
+			response = s.success(utils.WATCH.Name, idEvent, message)
+		} else {
+			response = types.Response{Command: utils.WATCH.Name, Ok: true, Message: message}
 		}
+	})
+	return response
+}
+
+// showPeers est la méthode appelée par la commande "peers" et retourne l'état des autres serveurs selon le détecteur de
+// défaillances.
+func (s *Server) showPeers(args []string) types.Response {
+	if !s.checkNbArgs(args, &utils.PEERS, false) {
+		return failure(types.InvalidNbArgs)
 	}
 
-	var builder strings.Builder
-	aligner := "\t"
-	var endColumn string
-	for i := 0; i < len(allUsersWorking); i++ {
-		endColumn += "\t"
+	return types.Response{Command: utils.PEERS.Name, Ok: true, Peers: s.peersStatus()}
+}
+
+// showLeader est la méthode appelée par la commande "leader" et retourne le leader élu ainsi que l'algorithme d'élection
+// utilisé.
+func (s *Server) showLeader(args []string) types.Response {
+	if !s.checkNbArgs(args, &utils.LEADER, false) {
+		return failure(types.InvalidNbArgs)
 	}
 
-	w := tabwriter.NewWriter(&builder, 0, 0, 3, ' ', 0)
-	_, err := fmt.Fprintln(w, firstLine)
-	if err != nil {
-		s.log(types.ERROR, err.Error())
+	election := string(s.Config.Election)
+	if s.raft != nil {
+		election = string(types.RaftConsistency)
+	} else if election == "" {
+		election = string(types.Bully)
 	}
 
-	if numberOfUsers == 0 {
-		err := w.Flush()
-		if err != nil {
-			s.log(types.ERROR, err.Error())
+	leader, _ := s.leader()
+	info := types.LeaderInfo{Leader: leader, Self: leader == s.Number, Election: election}
+
+	return types.Response{Command: utils.LEADER.Name, Ok: true, Leader: &info}
+}
+
+// showStatus est la méthode appelée par la commande "status" et retourne l'état interne du serveur : ses horloges, les
+// messages envoyés, l'état de l'algorithme de chaque ressource et la dernière modification de chaque manifestation.
+func (s *Server) showStatus(args []string) types.Response {
+	if !s.checkNbArgs(args, &utils.STATUS, false) {
+		return failure(types.InvalidNbArgs)
+	}
+
+	status := types.StatusInfo{
+		Server:    s.Number,
+		Stamp:     s.Stamp,
+		Conflicts: s.nbConflicts,
+		Messages:  s.nbMessages,
+		Accesses:  s.nbAccesses,
+		Events:    []types.EventStampInfo{},
+	}
+	if s.Config.VectorClock {
+		status.VectorClock = copyClock(s.clock)
+	}
+
+	if s.raft != nil {
+		status.Raft = &types.RaftInfo{Role: string(s.raft.role), Term: s.raft.term, CommitIndex: s.raft.commitIndex, LogLength: len(s.raft.log)}
+	} else {
+		status.Mutex = s.Config.Mutex
+		if status.Mutex == "" {
+			status.Mutex = types.Lamport
+		}
+		for _, resource := range utils.MapKeysToArray(s.mutexes) {
+			status.Mutexes = append(status.Mutexes, types.MutexInfo{Resource: resource, Status: s.mutexes[resource].Status()})
 		}
-		return utils.MESSAGE.WrapEvent(eventTitle + builder.String() + "\nThere is currently no volunteers for this event.\n")
 	}
 
-	for i := 0; i < len(allUsersWorking); i++ {
-		for j := 0; j < len(allUsersWorking[i]); j++ {
-			_, err = fmt.Fprintln(w, allUsersWorking[i][j]+aligner+"✅"+endColumn)
-			if err != nil {
-				s.log(types.ERROR, err.Error())
-			}
+	for _, id := range utils.MapKeysToArray(s.Repository.Events()) {
+		event := types.EventStampInfo{Id: id, Stamp: s.eventStamps[id]}
+		if clock, ok := s.eventClocks[id]; ok {
+			event.Clock = copyClock(clock)
 		}
-		aligner += "\t"
-		endColumn = strings.TrimSuffix(endColumn, "\t")
+		status.Events = append(status.Events, event)
 	}
-	err = w.Flush()
-	if err != nil {
-		s.log(types.ERROR, err.Error())
+
+	return types.Response{Command: utils.STATUS.Name, Ok: true, Status: &status}
+}
+
+// takeSnapshot est la méthode appelée par la commande "snapshot" et lance un snapshot global du réseau. Chaque serveur
+// écrit son état dans le dossier des snapshots une fois les communications en transit enregistrées.
+func (s *Server) takeSnapshot(args []string) types.Response {
+	if !s.checkNbArgs(args, &utils.SNAPSHOT, false) {
+		return failure(types.InvalidNbArgs)
 	}
 
-	return utils.MESSAGE.WrapEvent(eventTitle + builder.String())
+	id := s.startSnapshot()
+	snapshot := types.SnapshotInfo{Id: id, Path: s.snapshotPath(id, "<number>")}
+
+	return types.Response{Command: utils.SNAPSHOT.Name, Ok: true, Message: "Snapshot " + id + " started, each server writes its state to " + snapshot.Path, Snapshot: &snapshot}
 }
 
 // ---------- Méthodes helpers ----------
 
-// commsToString affiche la map des communications du serveur en un tableau de string.
-func (s *Server) commsToString() string {
-	var str string
-	str += "["
-	for i := 1; i <= len(s.Config.Servers); i++ {
-		str += "S" + strconv.Itoa(i) + ": " + string(s.comms[i].Type) + strconv.Itoa(s.comms[i].Stamp)
-		if i != len(s.Config.Servers) {
-			str += ", "
-		}
+// resourceToString affiche une ressource de la section critique distribuée.
+func resourceToString(resource int) string {
+	if resource == CreateResource {
+		return "CREATE"
 	}
-	str += "]"
-
-	return str
+	return "EVENT #" + strconv.Itoa(resource)
 }
 
 // debugTrace permet d'afficher des informations de debugTrace si le mode debugTrace est activé.
@@ -696,17 +1157,10 @@ func (s *Server) log(logType types.LogType, message string) {
 	}
 }
 
-// verifyUser permet de vérifier si un utilisateur existe dans la map des utilisateurs et retourne sa clé dans la map et un booléen
-// indiquant sa présence.
+// verifyUser permet de vérifier si un utilisateur existe dans le repository et retourne son id et un booléen indiquant
+// sa présence.
 func (s *Server) verifyUser(username, password string) (int, bool) {
-
-	for key, user := range users {
-		if user.Username == username && user.Password == password {
-			return key, true
-		}
-	}
-
-	return 0, false
+	return repository.FindUser(s.Repository, username, password)
 }
 
 // removeUserInJob permet de supprimer l'id d'un utilisateur du tableau des utilisateurs qui ont postulé à un job et retourne si l'opération
@@ -722,28 +1176,38 @@ func (s *Server) removeUserInJob(idUser int, job *types.Job) bool {
 	return false
 }
 
-// addUserToJob permet d'ajouter un utilisateur à un job et retourne un message vide et true si l'opération a réussi.
-// En cas d'échec d'ajout, la méthode retourne un message d'erreur spécifique et false.
+// addUserToJob permet d'ajouter un utilisateur à un job et retourne un code vide et true si l'opération a réussi.
+// En cas d'échec d'ajout, la méthode retourne un code d'erreur spécifique et false.
 //
 // Si un utilisateur est déjà dans un job de la même manifestation, sa postulation est supprimée et il est ajouté dans le nouveau job.
-func (s *Server) addUserToJob(event *types.Event, idJob, idUser int) (string, bool) {
+func (s *Server) addUserToJob(event *types.Event, idJob, idUser int) (types.ErrorCode, bool) {
 
 	job, ok := event.Jobs[idJob]
 
 	if ok {
-		// Différentes vérifications selon le cahier des charges avec les messages d'erreur correspondants
+		// Différentes vérifications selon le cahier des charges avec les codes d'erreur correspondants
 		if event.CreatorId == idUser {
-			return utils.MESSAGE.Error.CreatorRegister, false
+			return types.CreatorRegister, false
 		} else if len(job.VolunteerIds) == job.NbVolunteers {
-			return utils.MESSAGE.Error.JobFull, false
+			return types.JobFull, false
 		} else {
 			for _, id := range job.VolunteerIds {
 				if id == idUser {
-					return utils.MESSAGE.Error.AlreadyRegistered, false
+					return types.AlreadyRegistered, false
 				}
 			}
 		}
 
+		// Les jobs sont copiés afin de ne pas modifier la version précédente de la manifestation, toujours présente dans la
+		// repository
+		jobs := make(map[int]types.Job, len(event.Jobs))
+		for jobId, exploredJob := range event.Jobs {
+			exploredJob.VolunteerIds = append([]int{}, exploredJob.VolunteerIds...)
+			jobs[jobId] = exploredJob
+		}
+		event.Jobs = jobs
+		job = jobs[idJob]
+
 		// Suppression de l'utilisateur dans un job de la manifestation
 		for exploredJobId, exploredJob := range event.Jobs {
 			if s.removeUserInJob(idUser, &exploredJob) {
@@ -754,96 +1218,74 @@ func (s *Server) addUserToJob(event *types.Event, idJob, idUser int) (string, bo
 		job.VolunteerIds = append(job.VolunteerIds, idUser)
 		event.Jobs[idJob] = job
 	} else {
-		return utils.MESSAGE.Error.JobNotFound, false
+		return types.JobNotFound, false
 	}
 
 	return "", true
 }
 
-// closeEvent permet de fermer une manifestation et retourne un message vide et true si l'opération a réussi.
-// En cas d'échec de fermeture, la méthode retourne un message d'erreur spécifique et false.
-func (s *Server) closeEvent(idEvent, idUser int) (string, bool) {
-	event, okEvent := events[idEvent]
+// closeEvent permet de fermer une manifestation et retourne un code vide et true si l'opération a réussi.
+// En cas d'échec de fermeture, la méthode retourne un code d'erreur spécifique et false.
+func (s *Server) closeEvent(idEvent, idUser int) (types.ErrorCode, bool) {
+	event, okEvent := s.Repository.Event(idEvent)
 
 	if !okEvent {
-		return utils.MESSAGE.Error.EventNotFound, false
+		return types.EventNotFound, false
 	} else if event.CreatorId != idUser {
-		return utils.MESSAGE.Error.NotCreator, false
+		return types.NotCreator, false
 	} else if event.Closed {
-		return utils.MESSAGE.Error.AlreadyClosed, false
+		return types.AlreadyClosed, false
 	} else {
 		event.Closed = true
-		events[idEvent] = event
+		s.setEvent(idEvent, event)
 	}
 
 	return "", true
 }
 
-// checkNbArgs permet de vérifier le nombre d'arguments d'une commande et retourne un message vide et true si le nombre d'arguments est correct.
-// En cas d'échec de vérification, la méthode retourne un message d'erreur spécifique et false.
-func (s *Server) checkNbArgs(args []string, command *types.Command, optional bool) (string, bool) {
-	msg := utils.MESSAGE.Error.InvalidNbArgs
+// checkNbArgs permet de vérifier le nombre d'arguments d'une commande et retourne true si le nombre d'arguments est correct.
+func (s *Server) checkNbArgs(args []string, command *types.Command, optional bool) bool {
 	if optional {
 		if len(args) < command.MinArgs || len(args)%command.MinOptArgs != 1 {
-			return msg, false
+			return false
 		}
 	} else {
 		if len(args) != command.MinArgs && len(args)%command.MinOptArgs != 1 {
-			return msg, false
+			return false
 		}
 	}
 
-	return "", true
+	return true
 }
 
-// showAllEvents permet d'afficher toutes les manifestations.
-func (s *Server) showAllEvents() string {
-	var response string
+// eventInfo retourne la manifestation correspondant à l'identifiant passé en paramètre avec le nom de son organisateur
+// et de ses bénévoles.
+func (s *Server) eventInfo(idEvent int) types.EventInfo {
+	event, _ := s.Repository.Event(idEvent)
+	creator, _ := s.Repository.User(event.CreatorId)
+	info := types.EventInfo{Id: idEvent, Name: event.Name, Creator: creator.Username, Closed: event.Closed, Jobs: []types.JobInfo{}}
 
-	for i := 1; i <= len(events); i++ {
-		event := events[i]
-		creator := users[event.CreatorId]
-		if event.Closed {
-			response += utils.RED + "Closed" + utils.RESET
-		} else {
-			response += utils.GREEN + "Open" + utils.RESET
-		}
-		response += "\t#" + strconv.Itoa(i) + " " + utils.BOLD + utils.CYAN + event.Name + utils.RESET + " / Creator: " + creator.Username + "\n"
-		if i != len(events) {
-			response += "\n"
+	for i := 1; i <= len(event.Jobs); i++ {
+		job := event.Jobs[i]
+		volunteers := make([]string, 0, len(job.VolunteerIds))
+		for _, userId := range job.VolunteerIds {
+			volunteer, _ := s.Repository.User(userId)
+			volunteers = append(volunteers, volunteer.Username)
 		}
+		info.Jobs = append(info.Jobs, types.JobInfo{Id: i, Name: job.Name, NbVolunteers: job.NbVolunteers, Volunteers: volunteers})
 	}
 
-	return utils.MESSAGE.WrapEvent(response)
+	return info
 }
 
-// showEvent permet d'afficher la manifestation correspondant à l'identifiant passé en paramètre et retourne un message vide et true
-// si l'opération a réussi. En cas d'échec d'affichage, la méthode retourne un message d'erreur spécifique et false.
-func (s *Server) showEvent(idEvent int) (string, bool) {
-	event, ok := events[idEvent]
-
-	if ok {
-		creator := users[event.CreatorId]
-
-		response := "#" + strconv.Itoa(idEvent) + " " + utils.BOLD + utils.CYAN + event.Name + utils.RESET + "\n\n"
-		response += "Creator: " + creator.Username + "\n\n"
-		response += "🦺" + utils.BOLD + " Jobs" + utils.RESET + "\n\n"
-
-		for i := 1; i <= len(event.Jobs); i++ {
-			job := event.Jobs[i]
-
-			var color string
-			if len(job.VolunteerIds) == job.NbVolunteers {
-				color = utils.RED
-			} else {
-				color = utils.GREEN
-			}
-
-			response += color + "(" + strconv.Itoa(len(job.VolunteerIds)) + "/" + strconv.Itoa(job.NbVolunteers) + ")" + utils.RESET + "\tJob #" + strconv.Itoa(i) + ": " + job.Name + "\n"
-		}
-
-		return utils.MESSAGE.WrapEvent(response), true
-	}
+// success retourne la réponse d'une commande ayant modifié une manifestation, avec son message de confirmation et la
+// nouvelle version de la manifestation.
+func (s *Server) success(command string, idEvent int, message string) types.Response {
+	event := s.eventInfo(idEvent)
+	return types.Response{Command: command, Ok: true, Message: message, Event: &event}
+}
 
-	return utils.MESSAGE.Error.EventNotFound, false
+// failure retourne la réponse d'une commande ayant échoué avec le code d'erreur donné.
+func failure(code types.ErrorCode) types.Response {
+	return types.Response{Error: code, Message: utils.ErrorText(code)}
 }
@@ -0,0 +1,308 @@
+// Auteurs: Jonathan Friedli, Lazar Pavicevic
+// Labo 2 SDR
+
+package server
+
+import (
+	"bufio"
+	"encoding/json"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/Lazzzer/labo1-sdr/internal/utils"
+	"github.com/Lazzzer/labo1-sdr/internal/utils/types"
+)
+
+// pipelineSize est le nombre de requêtes d'un client du protocole framed pouvant attendre leur réponse avant que la
+// lecture de ses requêtes soit suspendue.
+const pipelineSize = 64
+
+// queueSize est le nombre de commandes pouvant attendre dans la file des commandes avant que les sessions soient
+// bloquées.
+const queueSize = 256
+
+// Chaque client connecté possède sa propre session. Une session lit les commandes de son client et les transmet au
+// serveur. Chaque requête possède son propre channel de réponse, ce qui garantit que la réponse d'une
+// commande est écrite sur la connexion du client qui l'a envoyée, quel que soit le nombre de clients connectés.
+//
+// Avec le protocole texte, une session attend la réponse d'une commande avant de lire la suivante.
+//
+// Avec le protocole framed (voir utils.WriteFrame), une session lit les requêtes de son client sans attendre leurs
+// réponses. Les réponses sont écrites dans l'ordre des requêtes par une goroutine séparée et reprennent l'identifiant de
+// la requête à laquelle elles répondent, ce qui permet au client d'envoyer plusieurs commandes à la suite.
+//
+// Seules les commandes accédant à la section critique distribuée (écritures et lectures fortes) sont ajoutées à la file
+// des commandes. Chaque commande de la file est traitée par sa propre goroutine et n'obtient que les ressources de la
+// section critique distribuée qu'elle utilise : les commandes portant sur des manifestations différentes sont traitées
+// en même temps, celles portant sur une même ressource l'une après l'autre. Les autres commandes, dont les lectures
+// locales, sont traitées directement par la goroutine de leur session et n'attendent jamais une commande de la file, à
+// l'exception des requêtes précédentes du même client.
+//
+// Chaque session est abonnée aux modifications des manifestations que son client suit avec la commande "watch". Les
+// notifications sont écrites par une goroutine séparée entre deux réponses, sous forme d'une ligne de texte, d'une
+// types.Notification en mode JSON ou d'une frame de statut types.StatusNotification avec le protocole framed.
+
+// session représente la connexion d'un client au serveur.
+type session struct {
+	id         int64     // Numéro de la session, unique sur le serveur
+	name       string    // Nom du client
+	conn       net.Conn  // Connexion du client
+	json       bool      // Indique si le client envoie ses requêtes et reçoit ses réponses en JSON
+	nbRequests int       // Nombre de requêtes envoyées par le client
+	last       chan bool // Fermé lorsque la dernière requête valide du client a été traitée
+
+	sub *subscriber // Abonnement du client aux modifications des manifestations qu'il suit
+	mu  sync.Mutex  // Protège l'écriture sur la connexion, partagée par les réponses et les notifications
+}
+
+// request représente une commande d'un client en attente de traitement.
+type request struct {
+	id       string              // Identifiant de la requête, formé du numéro de la session et du numéro de la requête
+	input    string              // Entrée du client
+	command  string              // Nom de la commande
+	args     []string            // Arguments de la commande
+	response chan types.Response // Réponse à la commande, fermé sans réponse lorsque la commande termine la session
+	frame    uint32              // Identifiant de la requête choisi par un client du protocole framed
+	tag      string              // Identifiant de la requête choisi par un client en mode JSON
+	sub      *subscriber         // Abonnement de la session du client, nil pour les requêtes de la passerelle HTTP
+	after    <-chan bool         // Fermé lorsque la requête précédente du client a été traitée, nil pour sa première requête
+	done     chan bool           // Fermé lorsque la requête a été traitée, nil pour les requêtes de la passerelle HTTP
+}
+
+// newRequest crée la prochaine requête de la session pour une entrée du client et retourne false si l'entrée n'est
+// pas une requête JSON valide, la réponse de la requête étant alors déjà disponible. Une requête valide n'est traitée
+// qu'après la précédente, ce qui conserve l'ordre des requêtes d'un client du protocole framed.
+func (se *session) newRequest(input string) (*request, bool) {
+	se.nbRequests++
+	req := &request{
+		id:       "C" + strconv.FormatInt(se.id, 10) + "-" + strconv.Itoa(se.nbRequests),
+		input:    strings.TrimSuffix(input, "\n"),
+		response: make(chan types.Response, 1),
+		sub:      se.sub,
+	}
+
+	if !se.json {
+		if args := strings.Fields(input); len(args) > 0 {
+			req.command = args[0]
+			req.args = args[1:]
+		}
+		return se.chain(req), true
+	}
+
+	var jsonReq types.JSONRequest
+	if err := json.Unmarshal([]byte(input), &jsonReq); err != nil {
+		req.response <- failure(types.InvalidRequest)
+		return req, false
+	}
+	req.command = jsonReq.Command
+	req.args = append([]string{}, jsonReq.Args...)
+	req.tag = jsonReq.Id
+	if jsonReq.Strong {
+		req.args = append(req.args, utils.STRONG_READ)
+	}
+	if jsonReq.Username != "" || jsonReq.Password != "" {
+		req.args = append(req.args, jsonReq.Username, jsonReq.Password)
+	}
+	return se.chain(req), true
+}
+
+// chain fait attendre à une requête valide la fin du traitement de la requête valide précédente de la session.
+func (se *session) chain(req *request) *request {
+	req.after = se.last
+	req.done = make(chan bool)
+	se.last = req.done
+	return req
+}
+
+// render met en forme la réponse à une requête selon le mode de la session. En mode JSON, la fermeture de la session
+// par la commande "quit" est aussi confirmée par une réponse.
+func (se *session) render(req *request, response types.Response, closed bool) string {
+	if closed {
+		if !se.json {
+			return ""
+		}
+		response = types.Response{Command: utils.QUIT.Name, Ok: true}
+	}
+	response.Id = req.tag
+
+	if se.json {
+		return renderJSON(response)
+	}
+	return renderText(response)
+}
+
+// renderNotification met en forme la modification d'une manifestation suivie selon le mode de la session.
+func (se *session) renderNotification(notification types.Notification) string {
+	if se.json {
+		content, err := json.Marshal(notification)
+		if err != nil {
+			return ""
+		}
+		return string(content) + "\n"
+	}
+	return renderNotification(notification)
+}
+
+// Write écrit sur la connexion du client. Les réponses et les notifications étant écrites par des goroutines
+// différentes, une écriture commencée se termine avant la suivante.
+func (se *session) Write(content []byte) (int, error) {
+	se.mu.Lock()
+	defer se.mu.Unlock()
+	return se.conn.Write(content)
+}
+
+// handleSession gère l'I/O avec un client connecté au serveur, de la réception de son nom jusqu'à la fermeture de sa
+// connexion.
+func (s *Server) handleSession(se *session) {
+	defer func() {
+		if err := se.conn.Close(); err != nil {
+			s.log(types.ERROR, err.Error())
+		}
+	}()
+
+	reader := bufio.NewReader(se.conn)
+
+	// Récupère le nom du client et le protocole qu'il utilise
+	hello, err := reader.ReadString('\n')
+	if err != nil {
+		s.log(types.ERROR, err.Error())
+		return
+	}
+	name, framed, jsonMode := utils.ParseHello(hello)
+	se.name = name
+	se.json = jsonMode
+	s.log(types.INFO, utils.GREEN+se.name+" connected"+utils.RESET)
+
+	// Les notifications sont envoyées jusqu'au désabonnement de la session, qui ferme leur channel
+	se.sub = s.subscribe(false)
+	defer s.unsubscribe(se.sub)
+	go s.writeNotifications(se, framed)
+
+	if framed {
+		s.serveFramed(se, reader)
+	} else {
+		s.serveText(se, reader)
+	}
+}
+
+// dispatch traite une requête d'un client. Les commandes accédant à la section critique distribuée sont ajoutées à la
+// file des commandes, les autres sont traitées directement par la goroutine appelante.
+func (s *Server) dispatch(req *request) {
+	if needsAccess(req.command, req.args) {
+		s.queue <- req
+	} else {
+		s.processCommand(req)
+	}
+}
+
+// serveText traite les commandes d'un client du protocole texte, chaque commande étant terminée par un retour à la ligne.
+func (s *Server) serveText(se *session, reader *bufio.Reader) {
+	for {
+		input, err := reader.ReadString('\n')
+		if err != nil {
+			s.log(types.ERROR, err.Error())
+			return
+		}
+
+		req, valid := se.newRequest(input)
+		s.log(types.INFO, utils.YELLOW+se.name+" ("+req.id+") -> "+req.input+utils.RESET)
+		if valid {
+			s.dispatch(req)
+		}
+
+		response, ok := <-req.response
+		if !ok {
+			s.log(types.INFO, utils.RED+se.name+" disconnected"+utils.RESET)
+		}
+		if _, err := se.Write([]byte(se.render(req, response, !ok))); err != nil {
+			s.log(types.ERROR, err.Error())
+			return
+		}
+		if !ok {
+			return
+		}
+	}
+}
+
+// serveFramed confirme l'utilisation du protocole framed puis lit les requêtes du client jusqu'à la commande "quit" ou
+// la fermeture de sa connexion. La méthode se termine lorsque toutes les réponses ont été écrites.
+func (s *Server) serveFramed(se *session, reader *bufio.Reader) {
+	if err := utils.WriteFrame(se, types.Frame{Status: types.StatusOK, Body: utils.FRAMED_PROTOCOL}); err != nil {
+		s.log(types.ERROR, err.Error())
+		return
+	}
+
+	pending := make(chan *request, pipelineSize)
+	done := make(chan bool)
+	go func() {
+		s.writeResponses(se, pending)
+		done <- true
+	}()
+
+	for {
+		frame, err := utils.ReadFrame(reader)
+		if err != nil {
+			s.log(types.ERROR, err.Error())
+			break
+		}
+
+		req, valid := se.newRequest(frame.Body)
+		req.frame = frame.Id
+		s.log(types.INFO, utils.YELLOW+se.name+" ("+req.id+", #"+strconv.FormatUint(uint64(frame.Id), 10)+") -> "+req.input+utils.RESET)
+		pending <- req
+		if valid {
+			s.dispatch(req)
+		}
+
+		// Les requêtes suivant la commande "quit" ne sont pas lues
+		if req.command == utils.QUIT.Name {
+			break
+		}
+	}
+
+	close(pending)
+	<-done
+}
+
+// writeResponses écrit les réponses aux requêtes d'un client du protocole framed dans l'ordre des requêtes. Après une
+// erreur d'écriture, les réponses suivantes sont attendues mais plus écrites.
+func (s *Server) writeResponses(se *session, pending <-chan *request) {
+	failed := false
+	for req := range pending {
+		response, ok := <-req.response
+		frame := types.Frame{Id: req.frame, Status: types.StatusOK, Body: se.render(req, response, !ok)}
+		if !ok {
+			frame.Status = types.StatusClosed
+			s.log(types.INFO, utils.RED+se.name+" disconnected"+utils.RESET)
+		} else if !response.Ok {
+			frame.Status = types.StatusError
+		}
+
+		if failed {
+			continue
+		}
+		if err := utils.WriteFrame(se, frame); err != nil {
+			s.log(types.ERROR, err.Error())
+			failed = true
+		}
+	}
+}
+
+// writeNotifications écrit les notifications des manifestations suivies par le client jusqu'à la fin de la session.
+// Avec le protocole framed, chaque notification est une frame d'id 0 que le client distingue des réponses par son statut.
+func (s *Server) writeNotifications(se *session, framed bool) {
+	for notification := range se.sub.updates {
+		content := se.renderNotification(notification)
+		var err error
+		if framed {
+			err = utils.WriteFrame(se, types.Frame{Status: types.StatusNotification, Body: content})
+		} else {
+			_, err = se.Write([]byte(content))
+		}
+		if err != nil {
+			s.log(types.ERROR, err.Error())
+		}
+	}
+}
@@ -0,0 +1,177 @@
+// Auteurs: Jonathan Friedli, Lazar Pavicevic
+// Labo 2 SDR
+
+package server
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/Lazzzer/labo1-sdr/internal/utils"
+	"github.com/Lazzzer/labo1-sdr/internal/utils/types"
+)
+
+// defaultSnapshotDir est le dossier dans lequel les snapshots sont écrits si la configuration n'en précise aucun.
+const defaultSnapshotDir = "snapshots"
+
+// Un snapshot global enregistre une coupe cohérente du réseau avec l'algorithme de Chandy-Lamport, qui repose sur les
+// connexions TCP entre les serveurs, dont les communications sont reçues dans l'ordre de leur envoi.
+//
+// Le serveur qui lance le snapshot enregistre son état local et envoie un marqueur (MRK) à tous les autres serveurs. Un
+// serveur qui reçoit le premier marqueur d'un snapshot enregistre son état local, considère le canal de l'émetteur comme
+// vide et envoie à son tour un marqueur à tous les autres serveurs. Les communications reçues ensuite d'un serveur sont
+// enregistrées comme étant en transit jusqu'à la réception de son marqueur. Les heartbeats ne sont pas enregistrés.
+//
+// Lorsque le marqueur de chaque serveur a été reçu, ou que le serveur a été retiré du réseau, le snapshot du serveur
+// est écrit dans un fichier JSON du dossier des snapshots, qui peut être chargé avec utils.GetSnapshot.
+
+// snapshot représente un snapshot global en cours d'enregistrement sur le serveur.
+type snapshot struct {
+	state     types.Snapshot // État enregistré du serveur
+	recording map[int]bool   // Serveurs dont le canal est enregistré jusqu'à la réception de leur marqueur
+}
+
+// startSnapshot lance un nouveau snapshot global et retourne son identifiant.
+func (s *Server) startSnapshot() string {
+	id := "S" + strconv.Itoa(s.Number) + "-" + strconv.FormatInt(time.Now().UnixMilli(), 10)
+	s.log(types.INFO, utils.CYAN+"Starting snapshot "+id+utils.RESET)
+	s.recordSnapshot(id, 0)
+	return id
+}
+
+// recordSnapshot enregistre l'état local du serveur pour un snapshot et envoie un marqueur à tous les autres serveurs.
+// Le canal du serveur dont le marqueur a déclenché l'enregistrement est vide.
+func (s *Server) recordSnapshot(id string, from int) {
+	snap := &snapshot{
+		state: types.Snapshot{
+			Id:          id,
+			Server:      s.Number,
+			Time:        time.Now(),
+			Stamp:       s.Stamp,
+			Events:      s.copyEvents(),
+			EventStamps: make(map[int]int, len(s.eventStamps)),
+			Mutexes:     make(map[int]types.MutexSnapshot, len(s.mutexes)),
+			Channels:    make(map[int][]types.Communication),
+		},
+		recording: make(map[int]bool),
+	}
+	if s.Config.VectorClock {
+		snap.state.Clock = copyClock(s.clock)
+	}
+	for eventId, stamp := range s.eventStamps {
+		snap.state.EventStamps[eventId] = stamp
+	}
+	for resource, mutex := range s.mutexes {
+		mutexSnapshot := types.MutexSnapshot{Status: mutex.Status()}
+		if holder, ok := mutex.(commsHolder); ok {
+			mutexSnapshot.Comms = holder.Comms()
+		}
+		snap.state.Mutexes[resource] = mutexSnapshot
+	}
+
+	for _, number := range s.peers() {
+		snap.state.Channels[number] = []types.Communication{}
+		if number != from {
+			snap.recording[number] = true
+		}
+	}
+	s.snapshots[id] = snap
+
+	s.sendControl(types.Communication{Type: types.Marker, From: s.Number, To: s.peers(), Stamp: s.Stamp, Snapshot: id})
+	s.completeSnapshot(snap)
+}
+
+// handleMarker traite un marqueur reçu d'un autre serveur en enregistrant l'état local s'il s'agit du premier marqueur
+// du snapshot, ou en terminant l'enregistrement du canal de l'émetteur sinon.
+func (s *Server) handleMarker(comm types.Communication) {
+	snap, ok := s.snapshots[comm.Snapshot]
+	if !ok {
+		s.log(types.INFO, utils.CYAN+"Recording snapshot "+comm.Snapshot+" started by Server #"+strconv.Itoa(comm.From)+utils.RESET)
+		s.recordSnapshot(comm.Snapshot, comm.From)
+		return
+	}
+
+	delete(snap.recording, comm.From)
+	s.completeSnapshot(snap)
+}
+
+// recordInFlight enregistre une communication reçue dans chaque snapshot dont le canal de l'émetteur est en cours
+// d'enregistrement.
+func (s *Server) recordInFlight(comm types.Communication) {
+	if comm.Type == types.Heartbeat || comm.Type == types.Marker {
+		return
+	}
+
+	for _, snap := range s.snapshots {
+		if snap.recording[comm.From] {
+			snap.state.Channels[comm.From] = append(snap.state.Channels[comm.From], comm)
+		}
+	}
+}
+
+// stopRecording termine l'enregistrement du canal d'un serveur retiré du réseau dans chaque snapshot en cours, son
+// marqueur ne pouvant plus être reçu.
+func (s *Server) stopRecording(number int) {
+	for _, snap := range s.snapshots {
+		if snap.recording[number] {
+			delete(snap.recording, number)
+			s.log(types.INFO, utils.ORANGE+"Channel of Server #"+strconv.Itoa(number)+" in snapshot "+snap.state.Id+" is incomplete"+utils.RESET)
+			s.completeSnapshot(snap)
+		}
+	}
+}
+
+// completeSnapshot écrit le snapshot du serveur dans un fichier JSON si les canaux de tous les serveurs ont été
+// enregistrés.
+func (s *Server) completeSnapshot(snap *snapshot) {
+	if len(snap.recording) > 0 {
+		return
+	}
+	delete(s.snapshots, snap.state.Id)
+	path := s.snapshotPath(snap.state.Id, strconv.Itoa(s.Number))
+
+	content, err := json.MarshalIndent(snap.state, "", "  ")
+	if err != nil {
+		s.log(types.ERROR, err.Error())
+		return
+	}
+	if err = os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		s.log(types.ERROR, err.Error())
+		return
+	}
+	if err = os.WriteFile(path, content, 0o644); err != nil {
+		s.log(types.ERROR, err.Error())
+		return
+	}
+
+	s.log(types.INFO, utils.GREEN+"Snapshot "+snap.state.Id+" written to "+path+utils.RESET)
+}
+
+// snapshotPath retourne le chemin du fichier dans lequel un serveur écrit son état pour un snapshot.
+func (s *Server) snapshotPath(id string, number string) string {
+	dir := s.Config.SnapshotDir
+	if dir == "" {
+		dir = defaultSnapshotDir
+	}
+	return filepath.Join(dir, "snapshot-"+id+"-S"+number+".json")
+}
+
+// copyEvents retourne une copie profonde des manifestations du repository, les jobs et leurs bénévoles étant modifiés en
+// place par les commandes.
+func (s *Server) copyEvents() map[int]types.Event {
+	events := s.Repository.Events()
+	copied := make(map[int]types.Event, len(events))
+	for id, event := range events {
+		jobs := make(map[int]types.Job, len(event.Jobs))
+		for jobId, job := range event.Jobs {
+			job.VolunteerIds = append([]int{}, job.VolunteerIds...)
+			jobs[jobId] = job
+		}
+		event.Jobs = jobs
+		copied[id] = event
+	}
+	return copied
+}
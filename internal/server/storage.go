@@ -0,0 +1,396 @@
+// Auteurs: Jonathan Friedli, Lazar Pavicevic
+// Labo 2 SDR
+
+package server
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/Lazzzer/labo1-sdr/internal/repository"
+	"github.com/Lazzzer/labo1-sdr/internal/utils"
+	"github.com/Lazzzer/labo1-sdr/internal/utils/types"
+)
+
+// defaultCheckpointInterval est le nombre d'entrées du journal après lesquelles l'état est enregistré si la
+// configuration n'en précise aucun.
+const defaultCheckpointInterval = 100
+
+const (
+	walFile        = "wal.log"         // Fichier du journal des modifications dans le dossier des données
+	checkpointFile = "checkpoint.json" // Fichier de l'état enregistré dans le dossier des données
+	kvFile         = "entities.db"     // Fichier clé-valeur des entités dans le dossier des données
+	raftFile       = "raft.json"       // Fichier du mandat et du vote Raft dans le dossier des données
+	raftLogFile    = "raft.log"        // Fichier du journal répliqué par Raft dans le dossier des données
+)
+
+// Un serveur dont le dossier des données est configuré conserve ses manifestations après un redémarrage. Chaque
+// modification d'une manifestation est ajoutée au journal des modifications (WAL) avant d'être appliquée, ainsi que
+// l'estampille de chaque modification lorsqu'elle est attribuée. Le journal contient la nouvelle version complète de
+// la manifestation modifiée, ce qui permet de le rejouer plusieurs fois sans changer le résultat.
+//
+// Toutes les CheckpointInterval entrées (100 par défaut), les manifestations, les utilisateurs et les estampilles sont
+// enregistrés dans un fichier séparé (checkpoint) puis le journal est vidé. Au démarrage, le serveur charge le dernier
+// checkpoint et rejoue le journal avant de contacter les autres serveurs, qui lui transmettent ensuite les
+// modifications plus récentes comme à un serveur qui redémarre. Une entrée incomplète à la fin du journal, écrite
+// pendant un crash, est ignorée.
+//
+// Avec le repository "kv", les utilisateurs et les manifestations sont aussi conservés dans un fichier clé-valeur du
+// dossier des données à la place des maps en mémoire.
+//
+// En mode Raft, les manifestations sont reconstruites en rejouant le journal répliqué, elles ne sont donc pas
+// enregistrées. Le mandat et le vote sont à la place enregistrés dans un petit fichier réécrit lorsqu'ils changent, et
+// chaque nouvelle entrée du journal répliqué est ajoutée à la fin d'un second fichier, avant que le serveur ne réponde
+// aux autres serveurs. Les entrées remplacées par un nouveau leader sont retirées en tronquant ce fichier. Un serveur
+// qui redémarre retrouve ainsi son journal et ses votes, ce qui permet à tout le réseau de redémarrer sans perdre les
+// entrées validées.
+
+// storage représente les fichiers des données persistantes d'un serveur.
+type storage struct {
+	dir       string   // Dossier des données
+	wal       *os.File // Journal des modifications, ouvert en ajout
+	nbEntries int      // Nombre d'entrées ajoutées au journal depuis le dernier checkpoint
+}
+
+// recoverStorage restaure l'état enregistré dans le dossier des données du serveur puis ouvre son journal des
+// modifications. Le serveur se termine si les données ne peuvent pas être lues.
+func (s *Server) recoverStorage() {
+	if s.Config.Repository != "" && s.Config.Repository != types.MemoryRepository && s.Config.Repository != types.KVRepository {
+		log.Fatal("Unknown repository: " + string(s.Config.Repository))
+	}
+	if s.DataDir == "" {
+		if s.Config.Repository == types.KVRepository {
+			log.Fatal("The kv repository requires a data directory")
+		}
+		return
+	}
+
+	if err := os.MkdirAll(s.DataDir, 0o755); err != nil {
+		log.Fatal(err)
+	}
+	if s.Config.Consistency == types.RaftConsistency {
+		return
+	}
+	if s.Config.Repository == types.KVRepository {
+		s.openKV()
+	}
+	if err := s.loadCheckpoint(); err != nil {
+		log.Fatal(err)
+	}
+	nbEntries, err := s.replayWAL()
+	if err != nil {
+		log.Fatal(err)
+	}
+	s.log(types.INFO, utils.GREEN+"Recovered "+strconv.Itoa(s.Repository.NbEvents())+" event(s) and "+strconv.Itoa(nbEntries)+" log entry(ies) from "+s.DataDir+utils.RESET)
+
+	wal, err := os.OpenFile(filepath.Join(s.DataDir, walFile), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		log.Fatal(err)
+	}
+	s.storage = &storage{dir: s.DataDir, wal: wal}
+
+	// L'état restauré devient le nouveau checkpoint, ce qui vide le journal rejoué
+	s.checkpoint()
+}
+
+// openKV remplace le repository du serveur par le fichier clé-valeur de son dossier des données. Un fichier vide reçoit
+// les entités du repository remplacé.
+func (s *Server) openKV() {
+	kv, err := repository.OpenKV(filepath.Join(s.DataDir, kvFile), types.Entities{Users: s.Repository.Users(), Events: s.Repository.Events()})
+	if err != nil {
+		log.Fatal(err)
+	}
+	s.Repository.Close()
+	s.Repository = kv
+}
+
+// loadCheckpoint remplace les utilisateurs, les manifestations et les estampilles par ceux du dernier checkpoint. Sans
+// checkpoint, le serveur conserve les entités avec lesquelles il a été créé.
+func (s *Server) loadCheckpoint() error {
+	content, err := os.ReadFile(filepath.Join(s.DataDir, checkpointFile))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	var checkpoint types.Checkpoint
+	if err := json.Unmarshal(content, &checkpoint); err != nil {
+		return err
+	}
+	if err := s.Repository.Replace(types.Entities{Users: checkpoint.Users, Events: checkpoint.Events}); err != nil {
+		return err
+	}
+	for id, stamp := range checkpoint.EventStamps {
+		s.eventStamps[id] = stamp
+	}
+	s.Stamp = utils.Max(s.Stamp, checkpoint.Stamp)
+	return nil
+}
+
+// replayWAL applique les entrées du journal des modifications et retourne leur nombre. Les entrées sont appliquées
+// directement dans le repository, aucun client n'étant encore abonné à leurs modifications.
+func (s *Server) replayWAL() (int, error) {
+	file, err := os.Open(filepath.Join(s.DataDir, walFile))
+	if errors.Is(err, os.ErrNotExist) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+
+	reader := bufio.NewReader(file)
+	nbEntries := 0
+	for {
+		line, err := reader.ReadBytes('\n')
+		if err == io.EOF {
+			if len(bytes.TrimSpace(line)) > 0 {
+				s.log(types.ERROR, utils.ORANGE+"Ignoring an incomplete entry at the end of the log"+utils.RESET)
+			}
+			return nbEntries, nil
+		}
+		if err != nil {
+			return nbEntries, err
+		}
+
+		var entry types.WALEntry
+		if err := json.Unmarshal(line, &entry); err != nil {
+			return nbEntries, errors.New("corrupted log entry #" + strconv.Itoa(nbEntries+1) + ": " + err.Error())
+		}
+		if entry.Event != nil {
+			if err := s.Repository.SaveEvent(entry.EventId, *entry.Event); err != nil {
+				return nbEntries, err
+			}
+		}
+		s.eventStamps[entry.EventId] = entry.Stamp
+		s.Stamp = utils.Max(s.Stamp, entry.Stamp)
+		nbEntries++
+	}
+}
+
+// persist ajoute une entrée au journal des modifications et attend qu'elle soit écrite sur le disque.
+func (s *Server) persist(entry types.WALEntry) {
+	if s.storage == nil {
+		return
+	}
+
+	content, err := json.Marshal(entry)
+	if err == nil {
+		_, err = s.storage.wal.Write(append(content, '\n'))
+	}
+	if err == nil {
+		err = s.storage.wal.Sync()
+	}
+	if err != nil {
+		s.log(types.ERROR, utils.RED+"Could not write to the log: "+err.Error()+utils.RESET)
+		return
+	}
+	s.storage.nbEntries++
+}
+
+// setEventStamp remplace l'estampille de la dernière modification d'une manifestation et l'ajoute au journal des
+// modifications.
+func (s *Server) setEventStamp(idEvent int, stamp int) {
+	s.persist(types.WALEntry{EventId: idEvent, Stamp: stamp})
+	s.eventStamps[idEvent] = stamp
+	s.checkpointIfDue()
+}
+
+// checkpointIfDue enregistre un checkpoint lorsque le journal a atteint le nombre d'entrées configuré. La méthode est
+// appelée après l'application d'une modification, pour que le checkpoint la contienne.
+func (s *Server) checkpointIfDue() {
+	interval := s.Config.CheckpointInterval
+	if interval <= 0 {
+		interval = defaultCheckpointInterval
+	}
+	if s.storage != nil && s.storage.nbEntries >= interval {
+		s.checkpoint()
+	}
+}
+
+// checkpoint enregistre les utilisateurs, les manifestations et les estampilles puis vide le journal des modifications.
+// Le checkpoint est écrit dans un fichier temporaire renommé une fois complet, un crash pendant l'écriture laissant
+// ainsi le checkpoint précédent et le journal intacts.
+func (s *Server) checkpoint() {
+	checkpoint := types.Checkpoint{
+		Time:        time.Now(),
+		Stamp:       s.Stamp,
+		Users:       s.Repository.Users(),
+		Events:      s.Repository.Events(),
+		EventStamps: s.eventStamps,
+	}
+	content, err := json.MarshalIndent(checkpoint, "", "  ")
+	if err != nil {
+		s.log(types.ERROR, err.Error())
+		return
+	}
+
+	path := filepath.Join(s.storage.dir, checkpointFile)
+	if err := os.WriteFile(path+".tmp", content, 0o644); err != nil {
+		s.log(types.ERROR, err.Error())
+		return
+	}
+	if err := os.Rename(path+".tmp", path); err != nil {
+		s.log(types.ERROR, err.Error())
+		return
+	}
+
+	// Un crash avant la troncature rejoue des entrées déjà contenues dans le checkpoint, sans effet sur le résultat
+	if err := s.storage.wal.Truncate(0); err != nil {
+		s.log(types.ERROR, err.Error())
+		return
+	}
+	s.storage.nbEntries = 0
+	s.log(types.INFO, utils.CYAN+"Checkpoint written to "+path+utils.RESET)
+}
+
+// raftStorage représente les fichiers de l'état Raft d'un serveur.
+type raftStorage struct {
+	dir   string          // Dossier des données
+	log   *os.File        // Journal répliqué, ouvert en ajout
+	ends  []int64         // Position de la fin de chaque entrée écrite dans le journal
+	state types.RaftState // Mandat et vote écrits dans le fichier de l'état
+}
+
+// recoverRaftState restaure le mandat, le vote et le journal enregistrés dans le dossier des données du serveur puis
+// ouvre son journal. Les entrées sont appliquées une fois validées à nouveau par le leader. Une entrée incomplète à la
+// fin du journal, écrite pendant un crash, est retirée. Le serveur se termine si l'état ne peut pas être lu.
+func (s *Server) recoverRaftState(r *raftNode) {
+	storage := &raftStorage{dir: s.DataDir}
+	content, err := os.ReadFile(filepath.Join(s.DataDir, raftFile))
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		log.Fatal(err)
+	}
+	if err == nil {
+		if err := json.Unmarshal(content, &storage.state); err != nil {
+			log.Fatal("Corrupted Raft state: " + err.Error())
+		}
+	}
+
+	file, err := os.OpenFile(filepath.Join(s.DataDir, raftLogFile), os.O_CREATE|os.O_RDWR|os.O_APPEND, 0o644)
+	if err != nil {
+		log.Fatal(err)
+	}
+	reader := bufio.NewReader(file)
+	var end int64
+	for {
+		line, err := reader.ReadBytes('\n')
+		if err == io.EOF {
+			if len(bytes.TrimSpace(line)) > 0 {
+				s.log(types.ERROR, utils.ORANGE+"Ignoring an incomplete entry at the end of the Raft log"+utils.RESET)
+				if err := file.Truncate(end); err != nil {
+					log.Fatal(err)
+				}
+			}
+			break
+		}
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		var entry types.LogEntry
+		if err := json.Unmarshal(line, &entry); err != nil {
+			log.Fatal("Corrupted Raft log entry #" + strconv.Itoa(len(r.log)+1) + ": " + err.Error())
+		}
+		r.log = append(r.log, entry)
+		end += int64(len(line))
+		storage.ends = append(storage.ends, end)
+	}
+
+	storage.log = file
+	r.storage = storage
+	r.persisted = len(r.log)
+	r.term = storage.state.Term
+	r.votedFor = storage.state.VotedFor
+	s.log(types.INFO, utils.GREEN+"Recovered term "+strconv.Itoa(r.term)+" and "+strconv.Itoa(len(r.log))+" log entry(ies) from "+s.DataDir+utils.RESET)
+}
+
+// saveState enregistre le mandat et le vote du serveur s'ils ont changé et attend qu'ils soient écrits sur le disque.
+// Comme le checkpoint, l'état est écrit dans un fichier temporaire renommé une fois complet.
+func (rs *raftStorage) saveState(state types.RaftState) error {
+	if state == rs.state {
+		return nil
+	}
+	content, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+
+	path := filepath.Join(rs.dir, raftFile)
+	file, err := os.Create(path + ".tmp")
+	if err != nil {
+		return err
+	}
+	_, err = file.Write(content)
+	if err == nil {
+		err = file.Sync()
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(path+".tmp", path)
+	}
+	if err == nil {
+		rs.state = state
+	}
+	return err
+}
+
+// truncateLog retire du journal les entrées écrites à partir d'un nombre d'entrées donné, remplacées par le leader.
+func (rs *raftStorage) truncateLog(nbEntries int) error {
+	var end int64
+	if nbEntries > 0 {
+		end = rs.ends[nbEntries-1]
+	}
+	if err := rs.log.Truncate(end); err != nil {
+		return err
+	}
+	rs.ends = rs.ends[:nbEntries]
+	return nil
+}
+
+// appendLog ajoute des entrées à la fin du journal et attend qu'elles soient écrites sur le disque. Seules les nouvelles
+// entrées sont écrites, ce qui évite de réécrire tout le journal à chaque ajout.
+func (rs *raftStorage) appendLog(entries []types.LogEntry) error {
+	if len(entries) == 0 {
+		return nil
+	}
+
+	var content []byte
+	ends := make([]int64, 0, len(entries))
+	end := int64(0)
+	if len(rs.ends) > 0 {
+		end = rs.ends[len(rs.ends)-1]
+	}
+	for _, entry := range entries {
+		line, err := json.Marshal(entry)
+		if err != nil {
+			return err
+		}
+		content = append(append(content, line...), '\n')
+		ends = append(ends, end+int64(len(content)))
+	}
+
+	// Une écriture incomplète est retirée pour que les entrées suivantes soient ajoutées après la dernière entrée valide
+	_, err := rs.log.Write(content)
+	if err == nil {
+		err = rs.log.Sync()
+	}
+	if err != nil {
+		rs.log.Truncate(end)
+		return err
+	}
+	rs.ends = append(rs.ends, ends...)
+	return nil
+}
@@ -0,0 +1,213 @@
+// Auteurs: Jonathan Friedli, Lazar Pavicevic
+// Labo 2 SDR
+
+package server
+
+import (
+	"strconv"
+
+	"github.com/Lazzzer/labo1-sdr/internal/utils"
+	"github.com/Lazzzer/labo1-sdr/internal/utils/types"
+)
+
+// suzukiKasamiMutex est l'implémentation de Mutex utilisant l'algorithme à jeton de Suzuki-Kasami.
+//
+// Un serveur accède à la section critique lorsqu'il possède le jeton. Pour l'obtenir, il diffuse une requête numérotée
+// à tous les autres serveurs et le détenteur du jeton le lui transmet une fois la section critique libérée. Le jeton
+// transporte les manifestations protégées par sa ressource, ce qui remplace leur diffusion à chaque REL.
+// Le jeton d'une ressource est créé par le serveur vivant ayant le plus petit numéro. Un serveur qui quitte le réseau
+// transmet le jeton à un autre serveur même si celui-ci ne l'a pas demandé.
+type suzukiKasamiMutex struct {
+	s          *Server      // Serveur utilisant l'algorithme
+	resource   int          // Ressource protégée
+	rn         map[int]int  // Numéro de séquence de la dernière requête connue de chaque serveur
+	token      *types.Token // Jeton, nil si le serveur ne le possède pas
+	requesting bool         // Indique si le serveur a une requête en cours
+	hasAccess  bool         // Booléen représentant la possession de la section critique
+}
+
+// newSuzukiKasamiMutex crée un suzukiKasamiMutex et attribue le jeton au serveur vivant ayant le plus petit numéro. Un
+// serveur défaillant ne crée donc pas le jeton des ressources utilisées pour la première fois après sa défaillance.
+//
+// Un serveur ayant rejoint un réseau en fonctionnement ne crée pas le jeton d'une ressource déjà utilisée par le
+// réseau, le jeton ayant pu être transmis. Ses numéros de séquence commencent à son estampille, qui est supérieure à
+// tous ceux utilisés avant son redémarrage.
+func newSuzukiKasamiMutex(s *Server, resource int) *suzukiKasamiMutex {
+	sk := &suzukiKasamiMutex{s: s, resource: resource, rn: make(map[int]int, len(s.Config.Servers))}
+
+	if s.rejoined {
+		sk.rn[s.Number] = s.Stamp
+	}
+	if s.Number == s.members()[0] && !s.usedResources[resource] {
+		sk.token = &types.Token{LN: make(map[int]int, len(s.Config.Servers)), Queue: []int{}}
+	}
+
+	return sk
+}
+
+// Acquire accorde directement l'accès si le serveur possède le jeton. Sinon, il diffuse une requête (REQ) à tous les
+// autres serveurs.
+func (sk *suzukiKasamiMutex) Acquire() {
+	sk.requesting = true
+	if sk.token != nil {
+		sk.grant()
+		return
+	}
+
+	sk.s.Stamp++
+	sk.rn[sk.s.Number]++
+	sk.s.send(types.Communication{
+		Type:     types.Request,
+		From:     sk.s.Number,
+		To:       utils.MapKeysToArray(sk.s.conns),
+		Stamp:    sk.s.Stamp,
+		Resource: sk.resource,
+		Seq:      sk.rn[sk.s.Number],
+	})
+}
+
+// Release libère la section critique et transmet le jeton au prochain serveur en attente.
+func (sk *suzukiKasamiMutex) Release() {
+	sk.hasAccess = false
+	sk.requesting = false
+	sk.token.LN[sk.s.Number] = sk.rn[sk.s.Number]
+	sk.dispatch()
+}
+
+// Leave transmet le jeton avant le départ du serveur, au prochain serveur en attente ou à défaut au serveur ayant le
+// plus petit numéro.
+func (sk *suzukiKasamiMutex) Leave() {
+	if sk.token == nil {
+		return
+	}
+
+	sk.token.LN[sk.s.Number] = sk.rn[sk.s.Number]
+	sk.dispatch()
+
+	if peers := sk.s.peers(); sk.token != nil && len(peers) > 0 {
+		sk.sendToken(peers[0])
+	}
+}
+
+// HandleMessage traite une communication (REQ, TOK) reçue d'un autre serveur.
+func (sk *suzukiKasamiMutex) HandleMessage(comm types.Communication) {
+	sk.s.Stamp = utils.Max(sk.s.Stamp, comm.Stamp) + 1
+
+	switch comm.Type {
+	case types.Request:
+		sk.rn[comm.From] = utils.Max(sk.rn[comm.From], comm.Seq)
+		sk.s.logComm(comm)
+
+		// Le jeton est transmis directement s'il n'est pas utilisé
+		if sk.token != nil && !sk.hasAccess && sk.isWaiting(comm.From) {
+			sk.sendToken(comm.From)
+		}
+	case types.TokenTransfer:
+		sk.token = comm.Token
+		sk.s.mergePayload(comm)
+		sk.s.logComm(comm)
+
+		// Un jeton transmis par un serveur quittant le réseau peut arriver sans requête en cours
+		if sk.requesting {
+			sk.grant()
+		} else {
+			sk.dispatch()
+		}
+	}
+}
+
+// RemovePeer oublie les requêtes d'un serveur et le retire de la file du jeton si le serveur le détient.
+//
+// Si le serveur retiré détenait le jeton ou était en train de le recevoir, le jeton est perdu et la ressource n'est
+// plus accessible, l'algorithme ne prévoyant pas sa régénération.
+func (sk *suzukiKasamiMutex) RemovePeer(number int) {
+	delete(sk.rn, number)
+	if sk.token == nil {
+		return
+	}
+
+	delete(sk.token.LN, number)
+	for i, queued := range sk.token.Queue {
+		if queued == number {
+			sk.token.Queue = append(sk.token.Queue[:i], sk.token.Queue[i+1:]...)
+			break
+		}
+	}
+}
+
+// AddPeer réintègre un serveur. Ses requêtes sont prises en compte dès que leur numéro de séquence dépasse celui de
+// sa dernière requête satisfaite.
+func (sk *suzukiKasamiMutex) AddPeer(number int) {}
+
+// Status affiche les numéros de séquence connus, la possession du jeton et sa file d'attente.
+func (sk *suzukiKasamiMutex) Status() string {
+	var str string
+	str += "[RN: "
+	for i, number := range sk.s.members() {
+		if i != 0 {
+			str += ", "
+		}
+		str += "S" + strconv.Itoa(number) + ": " + strconv.Itoa(sk.rn[number])
+	}
+
+	if sk.token != nil {
+		str += " | TOKEN, QUEUE: " + utils.IntToString(sk.token.Queue)
+	}
+	str += "]"
+
+	return str
+}
+
+// grant accorde l'accès à la section critique.
+func (sk *suzukiKasamiMutex) grant() {
+	sk.hasAccess = true
+	sk.s.grantAccess(sk.resource)
+}
+
+// dispatch ajoute à la file du jeton les serveurs ayant une requête en attente et transmet le jeton avec les
+// manifestations protégées au premier serveur de la file.
+func (sk *suzukiKasamiMutex) dispatch() {
+	for _, number := range sk.s.peers() {
+		if sk.isWaiting(number) && !sk.isQueued(number) {
+			sk.token.Queue = append(sk.token.Queue, number)
+		}
+	}
+
+	if len(sk.token.Queue) > 0 {
+		next := sk.token.Queue[0]
+		sk.token.Queue = sk.token.Queue[1:]
+		sk.sendToken(next)
+	}
+}
+
+// sendToken transmet le jeton accompagné des manifestations protégées à un serveur.
+func (sk *suzukiKasamiMutex) sendToken(to int) {
+	comm := types.Communication{
+		Type:     types.TokenTransfer,
+		From:     sk.s.Number,
+		To:       []int{to},
+		Resource: sk.resource,
+		Token:    sk.token,
+	}
+	sk.s.attachPayload(&comm)
+
+	sk.token = nil
+	sk.s.Stamp++
+	comm.Stamp = sk.s.Stamp
+	sk.s.send(comm)
+}
+
+// isWaiting indique si un serveur a une requête qui n'a pas encore été satisfaite par le jeton.
+func (sk *suzukiKasamiMutex) isWaiting(number int) bool {
+	return sk.rn[number] > sk.token.LN[number]
+}
+
+// isQueued indique si un serveur est déjà présent dans la file d'attente du jeton.
+func (sk *suzukiKasamiMutex) isQueued(number int) bool {
+	for _, queued := range sk.token.Queue {
+		if queued == number {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,51 @@
+// Auteurs: Jonathan Friedli, Lazar Pavicevic
+// Labo 2 SDR
+
+package server
+
+import (
+	"net"
+)
+
+// Transport représente la couche réseau utilisée par un serveur pour communiquer avec les autres serveurs. Les
+// connexions des clients utilisent toujours TCP.
+//
+// Les connexions retournées doivent délivrer les données dans l'ordre de leur envoi, ce dont dépendent les algorithmes
+// d'exclusion mutuelle et les snapshots globaux. Un serveur sans Transport utilise TCP.
+type Transport interface {
+	Listen(address string) (net.Listener, error) // Écoute les connexions des autres serveurs sur l'adresse du serveur
+	Dial(address string) (net.Conn, error)       // Se connecte à l'adresse d'un autre serveur
+}
+
+// TCPTransport est l'implémentation de Transport utilisant des connexions TCP.
+type TCPTransport struct{}
+
+// Listen écoute les connexions TCP sur le port de l'adresse donnée, quelle que soit l'interface réseau.
+func (TCPTransport) Listen(address string) (net.Listener, error) {
+	_, port, err := net.SplitHostPort(address)
+	if err != nil {
+		return nil, err
+	}
+	return net.Listen("tcp", ":"+port)
+}
+
+// Dial se connecte en TCP à l'adresse donnée.
+func (TCPTransport) Dial(address string) (net.Conn, error) {
+	return net.Dial("tcp", address)
+}
+
+// address retourne l'adresse sur laquelle le serveur écoute les connexions des autres serveurs.
+func (s *Server) address() string {
+	if address, ok := s.Config.Servers[s.Number]; ok {
+		return address
+	}
+	return ":" + s.Port
+}
+
+// transport retourne la couche réseau utilisée par le serveur, TCP par défaut.
+func (s *Server) transport() Transport {
+	if s.Transport == nil {
+		return TCPTransport{}
+	}
+	return s.Transport
+}
@@ -0,0 +1,124 @@
+// Auteurs: Jonathan Friedli, Lazar Pavicevic
+// Labo 2 SDR
+
+package server
+
+import (
+	"strconv"
+
+	"github.com/Lazzzer/labo1-sdr/internal/utils"
+	"github.com/Lazzzer/labo1-sdr/internal/utils/types"
+)
+
+// Lorsque les horloges vectorielles sont activées dans la configuration, chaque serveur maintient une horloge
+// vectorielle en plus de son estampille de Lamport, qui reste utilisée par les algorithmes d'exclusion mutuelle.
+// L'horloge est incrémentée à chaque envoi d'une communication d'un algorithme, à chaque réception d'une communication
+// autre qu'un heartbeat et à chaque modification de manifestations. Toutes les communications entre serveurs
+// transportent l'horloge de leur émetteur.
+//
+// Chaque manifestation conserve l'horloge de sa dernière modification, qui accompagne ses opérations et ses versions
+// complètes. Une version reçue dont l'horloge est concurrente à celle de la version locale signifie que deux serveurs
+// ont modifié la manifestation sans connaître la modification de l'autre : le conflit est affiché dans les logs et
+// comptabilisé dans la commande "status".
+
+// tickClock incrémente l'entrée du serveur dans son horloge vectorielle.
+func (s *Server) tickClock() {
+	if s.Config.VectorClock {
+		s.clock[s.Number]++
+	}
+}
+
+// attachClock ajoute à une communication une copie de l'horloge vectorielle du serveur.
+func (s *Server) attachClock(comm *types.Communication) {
+	if s.Config.VectorClock {
+		comm.Clock = copyClock(s.clock)
+	}
+}
+
+// receiveClock fusionne l'horloge vectorielle d'une communication reçue avec celle du serveur. La réception d'un
+// heartbeat n'est pas comptée comme un événement.
+func (s *Server) receiveClock(comm types.Communication) {
+	if !s.Config.VectorClock {
+		return
+	}
+
+	for number, value := range comm.Clock {
+		s.clock[number] = utils.Max(s.clock[number], value)
+	}
+	if comm.Type != types.Heartbeat {
+		s.clock[s.Number]++
+	}
+}
+
+// stampEventClock enregistre l'horloge vectorielle actuelle comme horloge de la dernière modification d'une
+// manifestation et la retourne.
+func (s *Server) stampEventClock(id int) types.VectorClock {
+	if !s.Config.VectorClock {
+		return nil
+	}
+
+	s.eventClocks[id] = copyClock(s.clock)
+	return s.eventClocks[id]
+}
+
+// receiveEventClock vérifie que la modification d'une manifestation reçue n'est pas concurrente à la dernière
+// modification connue. Si la version reçue remplace la version locale, son horloge devient celle de la manifestation.
+func (s *Server) receiveEventClock(id int, clock types.VectorClock, replaced bool) {
+	if !s.Config.VectorClock || clock == nil {
+		return
+	}
+
+	if local, ok := s.eventClocks[id]; ok && isConcurrent(local, clock) {
+		s.nbConflicts++
+		s.log(types.ERROR, utils.RED+"CONCURRENT UPDATES ON "+resourceToString(id)+": LOCAL "+clockToString(local)+" RECEIVED "+clockToString(clock)+utils.RESET)
+	}
+	if replaced {
+		s.eventClocks[id] = copyClock(clock)
+	}
+}
+
+// isConcurrent indique si deux horloges vectorielles sont concurrentes, c'est-à-dire qu'aucune n'est inférieure ou
+// égale à l'autre.
+func isConcurrent(a, b types.VectorClock) bool {
+	return !isBeforeOrEqual(a, b) && !isBeforeOrEqual(b, a)
+}
+
+// isBeforeOrEqual indique si chaque entrée de l'horloge a est inférieure ou égale à celle de l'horloge b.
+func isBeforeOrEqual(a, b types.VectorClock) bool {
+	for number, value := range a {
+		if value > b[number] {
+			return false
+		}
+	}
+	return true
+}
+
+// copyClock retourne une copie d'une horloge vectorielle.
+func copyClock(clock types.VectorClock) types.VectorClock {
+	copied := make(types.VectorClock, len(clock))
+	for number, value := range clock {
+		copied[number] = value
+	}
+	return copied
+}
+
+// clockToString affiche une horloge vectorielle triée par numéro de serveur.
+func clockToString(clock types.VectorClock) string {
+	str := "["
+	for i, number := range utils.MapKeysToArray(clock) {
+		if i != 0 {
+			str += ", "
+		}
+		str += "S" + strconv.Itoa(number) + ": " + strconv.Itoa(clock[number])
+	}
+	return str + "]"
+}
+
+// logClock retourne l'horloge vectorielle d'une communication à ajouter aux logs LAMPORT, vide si les horloges
+// vectorielles sont désactivées.
+func (s *Server) logClock(clock types.VectorClock) string {
+	if !s.Config.VectorClock {
+		return ""
+	}
+	return " VC " + clockToString(clock)
+}
@@ -0,0 +1,149 @@
+// Auteurs: Jonathan Friedli, Lazar Pavicevic
+// Labo 2 SDR
+
+package server
+
+import (
+	"bufio"
+	"encoding/json"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/Lazzzer/labo1-sdr/internal/utils"
+	"github.com/Lazzzer/labo1-sdr/internal/utils/types"
+)
+
+// La passerelle HTTP accepte des connexions WebSocket sur la route /ws. Chaque connexion est une session en mode JSON :
+// les messages texte du client sont des types.JSONRequest traitées comme les commandes des clients TCP, et le serveur
+// y répond avec des types.Response dans l'ordre des requêtes. La session est aussi abonnée aux modifications de toutes
+// les manifestations, qui lui sont envoyées sous forme de types.Notification dès qu'elles sont appliquées sur le serveur,
+// qu'elles proviennent d'une commande locale ou d'un autre serveur.
+
+// wsConn est une connexion WebSocket sur laquelle les réponses et les notifications sont écrites par des goroutines
+// différentes.
+type wsConn struct {
+	conn   net.Conn
+	reader *bufio.Reader
+	mu     sync.Mutex
+}
+
+// write écrit une frame complète sur la connexion.
+func (ws *wsConn) write(opcode types.WSOpcode, payload []byte) error {
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+	return utils.WriteWSFrame(ws.conn, types.WSFrame{Fin: true, Opcode: opcode, Payload: payload}, false)
+}
+
+// readMessage lit le prochain message du client en rassemblant ses fragments et répond aux pings. La méthode retourne
+// false lorsque le client ferme la connexion.
+func (ws *wsConn) readMessage() ([]byte, bool, error) {
+	var message []byte
+	for {
+		frame, err := utils.ReadWSFrame(ws.reader)
+		if err != nil {
+			return nil, false, err
+		}
+
+		switch frame.Opcode {
+		case types.WSPing:
+			if err := ws.write(types.WSPong, frame.Payload); err != nil {
+				return nil, false, err
+			}
+			continue
+		case types.WSPong:
+			continue
+		case types.WSClose:
+			return nil, false, ws.write(types.WSClose, frame.Payload)
+		}
+
+		message = append(message, frame.Payload...)
+		if frame.Fin {
+			return message, true, nil
+		}
+	}
+}
+
+// handleWebSocket ouvre une connexion WebSocket puis traite les requêtes du client jusqu'à la commande "quit" ou la
+// fermeture de la connexion.
+func (s *Server) handleWebSocket(w http.ResponseWriter, r *http.Request) {
+	key := r.Header.Get("Sec-WebSocket-Key")
+	if r.Method != http.MethodGet || key == "" || r.Header.Get("Sec-WebSocket-Version") != "13" ||
+		!strings.EqualFold(r.Header.Get("Upgrade"), "websocket") {
+		http.Error(w, "websocket upgrade required", http.StatusBadRequest)
+		return
+	}
+
+	hijacker, ok := w.(http.Hijacker)
+	if !ok {
+		http.Error(w, "websocket not supported", http.StatusInternalServerError)
+		return
+	}
+	conn, rw, err := hijacker.Hijack()
+	if err != nil {
+		s.log(types.ERROR, err.Error())
+		return
+	}
+	defer func() {
+		if err := conn.Close(); err != nil {
+			s.log(types.ERROR, err.Error())
+		}
+	}()
+
+	_, err = conn.Write([]byte("HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\nConnection: Upgrade\r\nSec-WebSocket-Accept: " + utils.WSAcceptKey(key) + "\r\n\r\n"))
+	if err != nil {
+		s.log(types.ERROR, err.Error())
+		return
+	}
+
+	se := &session{id: s.nbSessions.Add(1), name: r.URL.Query().Get("name"), conn: conn, json: true}
+	if se.name == "" {
+		se.name = r.RemoteAddr
+	}
+	ws := &wsConn{conn: conn, reader: rw.Reader}
+	s.log(types.INFO, utils.GREEN+se.name+" connected (websocket)"+utils.RESET)
+
+	// Les notifications sont envoyées jusqu'au désabonnement de la session, qui ferme leur channel
+	se.sub = s.subscribe(true)
+	defer s.unsubscribe(se.sub)
+	go func() {
+		for notification := range se.sub.updates {
+			content, err := json.Marshal(notification)
+			if err == nil {
+				err = ws.write(types.WSText, content)
+			}
+			if err != nil {
+				s.log(types.ERROR, err.Error())
+			}
+		}
+	}()
+
+	for {
+		message, open, err := ws.readMessage()
+		if err != nil {
+			s.log(types.ERROR, err.Error())
+		}
+		if !open {
+			s.log(types.INFO, utils.RED+se.name+" disconnected"+utils.RESET)
+			return
+		}
+
+		req, valid := se.newRequest(string(message))
+		s.log(types.INFO, utils.YELLOW+se.name+" ("+req.id+") -> "+req.input+utils.RESET)
+		if valid {
+			s.dispatch(req)
+		}
+
+		response, ok := <-req.response
+		if err := ws.write(types.WSText, []byte(strings.TrimSuffix(se.render(req, response, !ok), "\n"))); err != nil {
+			s.log(types.ERROR, err.Error())
+			return
+		}
+		if !ok {
+			s.log(types.INFO, utils.RED+se.name+" disconnected"+utils.RESET)
+			_ = ws.write(types.WSClose, nil)
+			return
+		}
+	}
+}
@@ -5,13 +5,18 @@ package utils
 
 import "github.com/Lazzzer/labo1-sdr/internal/utils/types"
 
-var HELP = types.Command{Name: "help", Auth: false, MinArgs: 0, MinOptArgs: -1}        // Propriétés de la commande "help"
-var CREATE = types.Command{Name: "create", Auth: true, MinArgs: 5, MinOptArgs: 2}      // Propriétés de la commande "create"
-var CLOSE = types.Command{Name: "close", Auth: true, MinArgs: 3, MinOptArgs: -1}       // Propriétés de la commande "close"
-var REGISTER = types.Command{Name: "register", Auth: true, MinArgs: 4, MinOptArgs: -1} // Propriétés de la commande "register"
-var SHOW = types.Command{Name: "show", Auth: false, MinArgs: 0, MinOptArgs: 1}         // Propriétés de la commande "show"
-var JOBS = types.Command{Name: "jobs", Auth: false, MinArgs: 1, MinOptArgs: -1}        // Propriétés de la commande "jobs"
-var QUIT = types.Command{Name: "quit", Auth: false, MinArgs: 0, MinOptArgs: -1}        // Propriétés de la commande "quit"
+var HELP = types.Command{Name: "help", Auth: false, MinArgs: 0, MinOptArgs: -1, ReadOnly: true} // Propriétés de la commande "help"
+var CREATE = types.Command{Name: "create", Auth: true, MinArgs: 5, MinOptArgs: 2}               // Propriétés de la commande "create"
+var CLOSE = types.Command{Name: "close", Auth: true, MinArgs: 3, MinOptArgs: -1}                // Propriétés de la commande "close"
+var REGISTER = types.Command{Name: "register", Auth: true, MinArgs: 4, MinOptArgs: -1}          // Propriétés de la commande "register"
+var SHOW = types.Command{Name: "show", Auth: false, MinArgs: 0, MinOptArgs: 1, ReadOnly: true}  // Propriétés de la commande "show"
+var JOBS = types.Command{Name: "jobs", Auth: false, MinArgs: 1, MinOptArgs: -1, ReadOnly: true} // Propriétés de la commande "jobs"
+var PEERS = types.Command{Name: "peers", Auth: false, MinArgs: 0, MinOptArgs: -1}               // Propriétés de la commande "peers"
+var LEADER = types.Command{Name: "leader", Auth: false, MinArgs: 0, MinOptArgs: -1}             // Propriétés de la commande "leader"
+var STATUS = types.Command{Name: "status", Auth: false, MinArgs: 0, MinOptArgs: -1}             // Propriétés de la commande "status"
+var SNAPSHOT = types.Command{Name: "snapshot", Auth: false, MinArgs: 0, MinOptArgs: -1}         // Propriétés de la commande "snapshot"
+var WATCH = types.Command{Name: "watch", Auth: false, MinArgs: 1, MinOptArgs: -1}               // Propriétés de la commande "watch"
+var QUIT = types.Command{Name: "quit", Auth: false, MinArgs: 0, MinOptArgs: -1}                 // Propriétés de la commande "quit"
 
 var COMMANDS = [...]types.Command{
 	HELP,
@@ -20,5 +25,23 @@ var COMMANDS = [...]types.Command{
 	REGISTER,
 	SHOW,
 	JOBS,
+	PEERS,
+	LEADER,
+	STATUS,
+	SNAPSHOT,
+	WATCH,
 	QUIT,
 }
+
+var STRONG_READ = "--strong" // Option forçant une commande de lecture à passer par la section critique distribuée
+var WATCH_ALL = "all"        // Argument de la commande "watch" suivant toutes les manifestations
+
+// GetCommand retourne la commande correspondant au nom donné et un booléen indiquant si elle existe.
+func GetCommand(name string) (types.Command, bool) {
+	for _, command := range COMMANDS {
+		if command.Name == name {
+			return command, true
+		}
+	}
+	return types.Command{}, false
+}
@@ -3,6 +3,8 @@
 
 package utils
 
+import "github.com/Lazzzer/labo1-sdr/internal/utils/types"
+
 // Message contient les variables représentant tous les messages utilisés par le serveur et le client
 type Message struct {
 	Error      errorMessage
@@ -29,25 +31,48 @@ type errorMessage = struct {
 	JobFull             string
 	AlreadyRegistered   string
 	NbVolunteersInteger string
+	CommandTimeout      string
+}
+
+// errorTexts contient le texte sans mise en forme du message de chaque code d'erreur
+var errorTexts = map[types.ErrorCode]string{
+	types.InvalidCommand:      "Invalid command. Type 'help' for a list of commands.",
+	types.InvalidNbArgs:       "Invalid number of arguments. Type 'help' for more information.",
+	types.AccessDenied:        "Access denied.",
+	types.MustBeInteger:       "Id must be an integer.",
+	types.EventNotFound:       "Event not found with given id.",
+	types.EventClosed:         "Event is closed.",
+	types.JobNotFound:         "Job not found with given id.",
+	types.NotCreator:          "Only the creator of the event can close it.",
+	types.AlreadyClosed:       "Event is already closed.",
+	types.IdEventNotMatchJob:  "Given event id does not match id in job.",
+	types.CreatorRegister:     "Creator of the event cannot register for a job.",
+	types.JobFull:             "Job is already full.",
+	types.AlreadyRegistered:   "User is already registered in this job.",
+	types.NbVolunteersInteger: "Number of volunteers must be a positive integer.",
+	types.CommandTimeout:      "Command was not committed in time, it may still be applied later.",
+	types.EmptyCommand:        "Empty command",
+	types.InvalidRequest:      "Invalid JSON request.",
 }
 
 // MESSAGE est une constante avec les messages d'erreurs formatés
 var MESSAGE = Message{
 	Error: errorMessage{
-		InvalidCommand:      wrapError("Invalid command. Type 'help' for a list of commands.\n"),
-		InvalidNbArgs:       wrapError("Invalid number of arguments. Type 'help' for more information.\n"),
-		AccessDenied:        wrapError("Access denied.\n"),
-		MustBeInteger:       wrapError("Id must be an integer.\n"),
-		EventNotFound:       wrapError("Event not found with given id.\n"),
-		EventClosed:         wrapError("Event is closed.\n"),
-		JobNotFound:         wrapError("Job not found with given id.\n"),
-		NotCreator:          wrapError("Only the creator of the event can close it.\n"),
-		AlreadyClosed:       wrapError("Event is already closed.\n"),
-		IdEventNotMatchJob:  wrapError("Given event id does not match id in job.\n"),
-		CreatorRegister:     wrapError("Creator of the event cannot register for a job.\n"),
-		JobFull:             wrapError("Job is already full.\n"),
-		AlreadyRegistered:   wrapError("User is already registered in this job.\n"),
-		NbVolunteersInteger: wrapError("Number of volunteers must be a positive integer.\n"),
+		InvalidCommand:      wrapError(errorTexts[types.InvalidCommand] + "\n"),
+		InvalidNbArgs:       wrapError(errorTexts[types.InvalidNbArgs] + "\n"),
+		AccessDenied:        wrapError(errorTexts[types.AccessDenied] + "\n"),
+		MustBeInteger:       wrapError(errorTexts[types.MustBeInteger] + "\n"),
+		EventNotFound:       wrapError(errorTexts[types.EventNotFound] + "\n"),
+		EventClosed:         wrapError(errorTexts[types.EventClosed] + "\n"),
+		JobNotFound:         wrapError(errorTexts[types.JobNotFound] + "\n"),
+		NotCreator:          wrapError(errorTexts[types.NotCreator] + "\n"),
+		AlreadyClosed:       wrapError(errorTexts[types.AlreadyClosed] + "\n"),
+		IdEventNotMatchJob:  wrapError(errorTexts[types.IdEventNotMatchJob] + "\n"),
+		CreatorRegister:     wrapError(errorTexts[types.CreatorRegister] + "\n"),
+		JobFull:             wrapError(errorTexts[types.JobFull] + "\n"),
+		AlreadyRegistered:   wrapError(errorTexts[types.AlreadyRegistered] + "\n"),
+		NbVolunteersInteger: wrapError(errorTexts[types.NbVolunteersInteger] + "\n"),
+		CommandTimeout:      wrapError(errorTexts[types.CommandTimeout] + "\n"),
 	},
 	Title:      title,
 	Goodbye:    goodbye,
@@ -56,6 +81,16 @@ var MESSAGE = Message{
 	LoginEnd:   loginEnd,
 }
 
+// ErrorText retourne le texte sans mise en forme du message d'un code d'erreur
+func ErrorText(code types.ErrorCode) string {
+	return errorTexts[code]
+}
+
+// WrapErrorCode formate le message d'un code d'erreur avec des traits coloriés en rouge
+func (m *Message) WrapErrorCode(code types.ErrorCode) string {
+	return wrapError(errorTexts[code] + "\n")
+}
+
 // WrapSuccess formate un message succès avec des traits coloriés en vert
 func (m *Message) WrapSuccess(message string) string {
 	success := GREEN + "\n===================== ✅ SUCCESS ✅ ==========================\n\n" + RESET
@@ -72,6 +107,30 @@ func (m *Message) WrapEvent(message string) string {
 	return event
 }
 
+// WrapPeers formate un message lié à l'état des serveurs avec des traits coloriés en violet
+func (m *Message) WrapPeers(message string) string {
+	peers := PINK + "\n====================== 🖥️ PEERS 🖥️ ===========================\n\n" + RESET
+	peers += message + "\n"
+	peers += PINK + "==============================================================" + RESET + "\n\n"
+	return peers
+}
+
+// WrapLeader formate un message lié au leader élu avec des traits coloriés en jaune
+func (m *Message) WrapLeader(message string) string {
+	leader := YELLOW + "\n===================== 👑 LEADER 👑 ===========================\n\n" + RESET
+	leader += message + "\n"
+	leader += YELLOW + "==============================================================" + RESET + "\n\n"
+	return leader
+}
+
+// WrapStatus formate un message lié à l'état interne du serveur avec des traits coloriés en bleu
+func (m *Message) WrapStatus(message string) string {
+	status := CYAN + "\n===================== 📊 STATUS 📊 ===========================\n\n" + RESET
+	status += message + "\n"
+	status += CYAN + "==============================================================" + RESET + "\n\n"
+	return status
+}
+
 // WrapSuccess formate un message d'erreur avec des traits coloriés en rouge
 func wrapError(message string) string {
 	err := RED + "\n===================== ❌ ERROR ❌ ============================\n\n" + RESET
@@ -116,9 +175,21 @@ var help = YELLOW +
 	"# 🔒 Register as a volunteer to a job\n" +
 	GREEN + "register" + RESET + " <idEvent> <idJob> [[<username> <password>]]\n\n" +
 	"# Show all events. If the id is specified, show the event with all its jobs instead\n" +
-	GREEN + "show" + RESET + " [<idEvent>]\n\n" +
+	GREEN + "show" + RESET + " [<idEvent>] [--strong]\n\n" +
 	"# Show the distribution of volunteers from each job of an event\n" +
-	GREEN + "jobs" + RESET + " <idEvent>\n\n" +
+	GREEN + "jobs" + RESET + " <idEvent> [--strong]\n\n" +
+	"ℹ️ Read commands are answered from the local copy of the server.\n" +
+	"Add --strong to get the latest version from the whole network.\n\n" +
+	"# Show the state of the other servers seen by the failure detector\n" +
+	GREEN + "peers" + RESET + "\n\n" +
+	"# Show the leader elected by the servers\n" +
+	GREEN + "leader" + RESET + "\n\n" +
+	"# Show the internal state of the server: clocks, mutexes and last update of each event\n" +
+	GREEN + "status" + RESET + "\n\n" +
+	"# Record a global snapshot of the network, written to a JSON file by each server\n" +
+	GREEN + "snapshot" + RESET + "\n\n" +
+	"# Receive the changes of an event (creation, closing, registrations) as soon as they happen on any server\n" +
+	GREEN + "watch" + RESET + " <idEvent> | all\n\n" +
 	"# Quit the program\n" +
 	GREEN + "quit" + RESET + "\n\n" +
 	YELLOW + "==============================================================" + RESET + "\n\n"
@@ -4,38 +4,138 @@
 // Package utils contient des fichiers utilitaires pour le projet.
 // Il contient notamment des variables globales de string pour les couleurs, les variables représentant les commandes
 // et les messages formatés pour les réponses du serveur ou du client.
-// Finalement, il contient un parser qui peut lire un fichier json et retourner soit la configuration soit les entités.
+// Finalement, il contient un parser qui peut lire un fichier json et retourner soit la configuration soit les entités
+// après les avoir validées, ainsi qu'une fonction chargeant le snapshot d'un serveur pour l'analyser hors ligne.
 package utils
 
 import (
 	_ "embed"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"os"
+	"strings"
 
 	"github.com/Lazzzer/labo1-sdr/internal/utils/types"
 )
 
-// GetEntities parse une string pour retourner un tuple contenant les entités créées.
+// GetEntities parse une string pour retourner un tuple contenant les entités créées. La fonction est réservée aux
+// entités intégrées au programme et panique si elles ne sont pas valides.
 func GetEntities(content string) (map[int]types.User, map[int]types.Event) {
-	entities := parse[types.Entities](content)
+	entities, err := ParseEntities(content)
+	if err != nil {
+		panic("Error: Could not parse entities: " + err.Error())
+	}
 	return entities.Users, entities.Events
 }
 
-// GetConfig parse une string et retourne une configuration.
-func GetConfig[T types.Config | types.ServerConfig](path string) T {
-	return *parse[T](path)
+// ResolvePath retourne le chemin d'un fichier chargé au démarrage : la valeur du flag si elle est renseignée, sinon
+// celle de la variable d'environnement, sinon le chemin par défaut.
+func ResolvePath(flagValue string, env string, defaultPath string) string {
+	if flagValue != "" {
+		return flagValue
+	}
+	if value := os.Getenv(env); value != "" {
+		return value
+	}
+	return defaultPath
+}
+
+// LoadConfig lit le fichier de configuration au chemin donné et retourne la configuration validée. L'erreur retournée
+// indique le fichier et le champ invalide.
+func LoadConfig[T types.Config | types.ServerConfig](path string) (T, error) {
+	var config T
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return config, fmt.Errorf("could not read config file: %w", err)
+	}
+
+	config, err = ParseConfig[T](string(content))
+	if err != nil {
+		return config, fmt.Errorf("invalid config file %s: %w", path, err)
+	}
+	return config, nil
+}
+
+// LoadEntities lit le fichier des entités au chemin donné et retourne les entités validées. L'erreur retournée indique
+// le fichier et l'entité invalide.
+func LoadEntities(path string) (types.Entities, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return types.Entities{}, fmt.Errorf("could not read entities file: %w", err)
+	}
+
+	entities, err := ParseEntities(string(content))
+	if err != nil {
+		return entities, fmt.Errorf("invalid entities file %s: %w", path, err)
+	}
+	return entities, nil
 }
 
-// parse est une fonction générique limitée aux types Config et Entities et permet de retourner le pointeur de l'objet parsé
-func parse[T types.Config | types.ServerConfig | types.Entities](content string) *T {
+// ParseConfig parse une string et retourne la configuration validée.
+func ParseConfig[T types.Config | types.ServerConfig](content string) (T, error) {
+	config, err := parse[T](content)
+	if err != nil {
+		return config, err
+	}
+
+	switch c := any(&config).(type) {
+	case *types.Config:
+		err = validateConfig(*c)
+	case *types.ServerConfig:
+		err = validateServerConfig(*c)
+	}
+	return config, err
+}
+
+// ParseEntities parse une string et retourne les entités validées.
+func ParseEntities(content string) (types.Entities, error) {
+	entities, err := parse[types.Entities](content)
+	if err != nil {
+		return entities, err
+	}
+	return entities, validateEntities(entities)
+}
+
+// parse est une fonction générique limitée aux types Config et Entities et permet de retourner l'objet parsé. En cas
+// d'erreur, la ligne du contenu où le JSON est invalide est indiquée.
+func parse[T types.Config | types.ServerConfig | types.Entities](content string) (T, error) {
 	var object T
 
 	err := json.Unmarshal([]byte(content), &object)
 
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+	switch {
+	case errors.As(err, &syntaxErr):
+		return object, fmt.Errorf("line %d: %s", lineAt(content, syntaxErr.Offset), syntaxErr.Error())
+	case errors.As(err, &typeErr):
+		return object, fmt.Errorf("line %d: field %q must be %s, not %s", lineAt(content, typeErr.Offset), typeErr.Field, typeErr.Type.String(), typeErr.Value)
+	case err != nil:
+		return object, err
+	}
+
+	return object, nil
+}
+
+// lineAt retourne le numéro de la ligne contenant l'octet à la position donnée.
+func lineAt(content string, offset int64) int {
+	if offset > int64(len(content)) {
+		offset = int64(len(content))
+	}
+	return strings.Count(content[:offset], "\n") + 1
+}
+
+// GetSnapshot lit le fichier JSON d'un snapshot global écrit par un serveur et retourne l'état enregistré.
+func GetSnapshot(path string) (types.Snapshot, error) {
+	var snapshot types.Snapshot
+
+	content, err := os.ReadFile(path)
 	if err != nil {
-		fmt.Println(err)
-		panic("Error: Could not parse object")
+		return snapshot, err
 	}
 
-	return &object
+	err = json.Unmarshal(content, &snapshot)
+	return snapshot, err
 }
@@ -0,0 +1,105 @@
+// Auteurs: Jonathan Friedli, Lazar Pavicevic
+// Labo 2 SDR
+
+package utils
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/Lazzzer/labo1-sdr/internal/utils/types"
+)
+
+// Un client choisit son protocole au moment de sa connexion avec la ligne contenant son nom. Par défaut, les commandes
+// et les réponses sont échangées en texte, chaque commande étant terminée par un retour à la ligne. Un client qui ajoute
+// FRAMED_PROTOCOL après son nom utilise le protocole framed, que le serveur confirme avec une frame d'identifiant 0.
+// Un client qui ajoute JSON_MODE après son nom, avec l'un ou l'autre des protocoles, envoie ses commandes sous forme de
+// types.JSONRequest et reçoit des types.Response encodées en JSON au lieu de texte mis en forme. En mode JSON avec le
+// protocole texte, chaque requête et chaque réponse tiennent sur une ligne.
+//
+// Dans le protocole framed, les requêtes et les réponses sont des frames préfixées par leur longueur :
+//
+//	| longueur (4 octets) | identifiant (4 octets) | statut (1 octet) | contenu (longueur - 5 octets) |
+//
+// Les entiers sont encodés en big-endian. La réponse à une requête reprend son identifiant, ce qui permet au client
+// d'envoyer plusieurs commandes sans attendre leurs réponses, qui sont retournées dans l'ordre des requêtes.
+
+var FRAMED_PROTOCOL = "--framed" // Option du nom d'un client demandant l'utilisation du protocole framed
+var JSON_MODE = "--json"         // Option du nom d'un client demandant des requêtes et des réponses en JSON
+
+// frameHeaderSize est la taille de l'identifiant et du statut d'une frame
+const frameHeaderSize = 5
+
+// MaxFrameSize est la taille maximale du contenu d'une frame
+const MaxFrameSize = 1 << 20
+
+// ParseHello retourne le nom d'un client à partir de la ligne envoyée à sa connexion et deux booléens indiquant s'il
+// demande le protocole framed et le mode JSON. Les options suivent le nom dans n'importe quel ordre.
+func ParseHello(line string) (name string, framed bool, jsonMode bool) {
+	name = strings.TrimSuffix(line, "\n")
+	for {
+		switch {
+		case strings.HasSuffix(name, " "+FRAMED_PROTOCOL):
+			name = strings.TrimSuffix(name, " "+FRAMED_PROTOCOL)
+			framed = true
+		case strings.HasSuffix(name, " "+JSON_MODE):
+			name = strings.TrimSuffix(name, " "+JSON_MODE)
+			jsonMode = true
+		default:
+			return name, framed, jsonMode
+		}
+	}
+}
+
+// WriteFrame écrit une frame en un seul appel à Write.
+func WriteFrame(w io.Writer, frame types.Frame) error {
+	if len(frame.Body) > MaxFrameSize {
+		return fmt.Errorf("frame body of %d bytes exceeds the maximum of %d bytes", len(frame.Body), MaxFrameSize)
+	}
+
+	buf := make([]byte, 4+frameHeaderSize+len(frame.Body))
+	binary.BigEndian.PutUint32(buf[0:4], uint32(frameHeaderSize+len(frame.Body)))
+	binary.BigEndian.PutUint32(buf[4:8], frame.Id)
+	buf[8] = byte(frame.Status)
+	copy(buf[9:], frame.Body)
+
+	_, err := w.Write(buf)
+	return err
+}
+
+// ReadFrame lit la prochaine frame. Une frame dont la longueur est invalide retourne une erreur, le reste du flux ne
+// pouvant plus être découpé.
+func ReadFrame(r io.Reader) (types.Frame, error) {
+	var header [4 + frameHeaderSize]byte
+	if _, err := io.ReadFull(r, header[:4]); err != nil {
+		return types.Frame{}, err
+	}
+	length := binary.BigEndian.Uint32(header[:4])
+	if length < frameHeaderSize || length > frameHeaderSize+MaxFrameSize {
+		return types.Frame{}, fmt.Errorf("invalid frame length %d", length)
+	}
+
+	if _, err := io.ReadFull(r, header[4:]); err != nil {
+		return types.Frame{}, unexpected(err)
+	}
+	body := make([]byte, length-frameHeaderSize)
+	if _, err := io.ReadFull(r, body); err != nil {
+		return types.Frame{}, unexpected(err)
+	}
+
+	return types.Frame{
+		Id:     binary.BigEndian.Uint32(header[4:8]),
+		Status: types.FrameStatus(header[8]),
+		Body:   string(body),
+	}, nil
+}
+
+// unexpected signale qu'un flux s'est terminé au milieu d'une frame.
+func unexpected(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
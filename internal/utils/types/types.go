@@ -4,6 +4,8 @@
 // Package types propose différents types utilisés par l'application pour parser les fichiers de configuration et les entités.
 package types
 
+import "time"
+
 // Config représente la configuration partagée par un serveur et un client.
 // Elle contient la liste des adresses des serveurs ainsi que leur numéro.
 // Pour le client, Address représente l'adresse du serveur auquel il se connecte.
@@ -18,11 +20,68 @@ type Config struct {
 type ServerConfig struct {
 	Config
 	ClientPorts map[int]string `json:"client_ports"`          // Ports des listeners à utiliser pour écouter les connexions des clients
+	HTTPPorts   map[int]string `json:"http_ports,omitempty"`  // Ports des passerelles HTTP, aucune passerelle pour un serveur absent
 	Debug       bool           `json:"debug"`                 // Activation du mode debug pour vérifier la concurrence
 	Silent      bool           `json:"silent"`                // Activation du mode silencieux pour ne pas afficher les logs
 	DebugDelay  int            `json:"debug_delay,omitempty"` // Délai d'attente pour la simulation de la concurrence
+	Mutex       MutexType      `json:"mutex,omitempty"`       // Algorithme d'exclusion mutuelle distribuée utilisé
+	Tree        map[int]int    `json:"tree,omitempty"`        // Parent de chaque serveur dans l'arbre logique de Raymond (0 pour la racine)
+	Election    ElectionType   `json:"election,omitempty"`    // Algorithme d'élection du leader utilisé
+	Consistency Consistency    `json:"consistency,omitempty"` // Mode de cohérence des manifestations entre les serveurs
+
+	HeartbeatInterval int `json:"heartbeat_interval,omitempty"` // Intervalle en millisecondes entre deux heartbeats envoyés aux autres serveurs
+	SuspicionTimeout  int `json:"suspicion_timeout,omitempty"`  // Délai en millisecondes sans communication après lequel un serveur est suspecté
+
+	VectorClock bool   `json:"vector_clock,omitempty"` // Activation des horloges vectorielles sur les communications et les manifestations
+	SnapshotDir string `json:"snapshot_dir,omitempty"` // Dossier dans lequel les snapshots globaux sont écrits
+
+	DataDirs           map[int]string `json:"data_dirs,omitempty"`           // Dossier des données persistantes de chaque serveur, aucune persistance pour un serveur absent
+	CheckpointInterval int            `json:"checkpoint_interval,omitempty"` // Nombre d'entrées du journal après lesquelles les manifestations et les utilisateurs sont enregistrés
+	Repository         RepositoryType `json:"repository,omitempty"`          // Stockage des utilisateurs et des manifestations, en mémoire par défaut
 }
 
+// VectorClock représente une horloge vectorielle associant à chaque serveur le nombre de ses événements connus.
+type VectorClock map[int]int
+
+// MutexType représente l'algorithme d'exclusion mutuelle distribuée utilisé par une "enum" contenant Lamport, RicartAgrawala,
+// SuzukiKasami, Raymond et Maekawa.
+type MutexType string
+
+const (
+	Lamport        MutexType = "lamport"
+	RicartAgrawala MutexType = "ricart-agrawala"
+	SuzukiKasami   MutexType = "suzuki-kasami"
+	Raymond        MutexType = "raymond"
+	Maekawa        MutexType = "maekawa"
+)
+
+// ElectionType représente l'algorithme d'élection du leader utilisé par une "enum" contenant Bully et ChangRoberts.
+type ElectionType string
+
+const (
+	Bully        ElectionType = "bully"
+	ChangRoberts ElectionType = "chang-roberts"
+)
+
+// Consistency représente le mode de cohérence des manifestations entre les serveurs utilisé par une "enum" contenant
+// MutexConsistency (section critique distribuée et diffusion des manifestations modifiées) et RaftConsistency (journal
+// de commandes répliqué par Raft).
+type Consistency string
+
+const (
+	MutexConsistency Consistency = "mutex"
+	RaftConsistency  Consistency = "raft"
+)
+
+// RepositoryType représente le stockage des utilisateurs et des manifestations d'un serveur par une "enum" contenant
+// MemoryRepository (maps en mémoire) et KVRepository (fichier clé-valeur dans le dossier des données du serveur).
+type RepositoryType string
+
+const (
+	MemoryRepository RepositoryType = "memory"
+	KVRepository     RepositoryType = "kv"
+)
+
 // LogType représente le type de log à afficher utilisé par une "enum" contenant INFO, ERROR, DEBUG et LAMPORT.
 type LogType string
 
@@ -33,30 +92,371 @@ const (
 	LAMPORT LogType = "LAMPORT"
 )
 
-// CommunicationType représente le type de communication utilisé par une "enum" contenant Request, Acknowledge, Release.
+// PeerState représente l'état d'un autre serveur vu par le détecteur de défaillances utilisé par une "enum" contenant
+// PeerAlive, PeerSuspected (aucune communication reçue depuis le délai de suspicion) et PeerDown (défaillance confirmée
+// par la fermeture de la connexion).
+type PeerState string
+
+const (
+	PeerAlive     PeerState = "ALIVE"
+	PeerSuspected PeerState = "SUSPECTED"
+	PeerDown      PeerState = "DOWN"
+)
+
+// CommunicationType représente le type de communication utilisé par une "enum" contenant Request, Acknowledge, Release,
+// TokenTransfer ainsi que Inquire, Yield et Failed utilisés par l'algorithme de Maekawa pour éviter les interblocages.
+// Heartbeat est utilisé par le détecteur de défaillances, State par un serveur pour transmettre son état à un serveur
+// qui se connecte et Leave par un serveur qui quitte le réseau. Election, Answer et Coordinator sont utilisés par les
+// algorithmes d'élection du leader. RequestVote, VoteReply, AppendEntries, AppendReply et Forward sont utilisés par le
+// mode de cohérence Raft. Ils ne concernent aucune ressource. SyncRequest et SyncReply permettent à un serveur ayant
+// manqué des opérations de demander la version complète des manifestations protégées par une ressource. Marker délimite
+// les communications enregistrées par un snapshot global.
 type CommunicationType string
 
 const (
-	Request     CommunicationType = "REQ"
-	Acknowledge CommunicationType = "ACK"
-	Release     CommunicationType = "REL"
+	Request       CommunicationType = "REQ"
+	Acknowledge   CommunicationType = "ACK"
+	Release       CommunicationType = "REL"
+	TokenTransfer CommunicationType = "TOK"
+	Inquire       CommunicationType = "INQ"
+	Yield         CommunicationType = "YLD"
+	Failed        CommunicationType = "FLD"
+	Heartbeat     CommunicationType = "HBT"
+	State         CommunicationType = "STA"
+	Leave         CommunicationType = "LVE"
+	Election      CommunicationType = "ELE"
+	Answer        CommunicationType = "ANS"
+	Coordinator   CommunicationType = "COO"
+	RequestVote   CommunicationType = "RVT"
+	VoteReply     CommunicationType = "VTR"
+	AppendEntries CommunicationType = "APP"
+	AppendReply   CommunicationType = "APR"
+	Forward       CommunicationType = "FWD"
+	SyncRequest   CommunicationType = "SYN"
+	SyncReply     CommunicationType = "SYR"
+	Marker        CommunicationType = "MRK"
 )
 
-// Communication représente une communication pour l'algorithme de Lamport optimisé entre deux serveurs.
+// Communication représente une communication pour l'algorithme d'exclusion mutuelle distribuée entre deux serveurs.
+// Chaque communication concerne une ressource de la section critique distribuée: 0 pour la création de manifestations,
+// sinon l'id de la manifestation protégée.
 type Communication struct {
-	Type    CommunicationType `json:"type"`              // Type de communication
-	From    int               `json:"from"`              // Numéro du serveur émetteur
-	To      []int             `json:"to"`                // Numéro des serveurs récepteurs
-	Stamp   int               `json:"stamp"`             // Estampille associée à la communication
-	Payload map[int]Event     `json:"payload,omitempty"` // Payload éventuel de la communication
+	Type      CommunicationType   `json:"type"`                // Type de communication
+	From      int                 `json:"from"`                // Numéro du serveur émetteur
+	To        []int               `json:"to"`                  // Numéro des serveurs récepteurs
+	Stamp     int                 `json:"stamp"`               // Estampille associée à la communication
+	Resource  int                 `json:"resource,omitempty"`  // Ressource de la section critique distribuée concernée
+	Seq       int                 `json:"seq,omitempty"`       // Numéro de séquence d'une requête pour les algorithmes à jeton
+	Token     *Token              `json:"token,omitempty"`     // Jeton éventuellement transmis avec la communication
+	Payload   map[int]Event       `json:"payload,omitempty"`   // Payload éventuel de la communication
+	Stamps    map[int]int         `json:"stamps,omitempty"`    // Estampille de la dernière modification de chaque manifestation du payload
+	Running   bool                `json:"running,omitempty"`   // Indique si l'émetteur d'un STA fait partie d'un réseau en fonctionnement
+	Instance  int64               `json:"instance,omitempty"`  // Instance de l'émetteur d'un STA, qui change à chaque redémarrage
+	Servers   map[int]string      `json:"servers,omitempty"`   // Adresses des serveurs membres du réseau connus par l'émetteur d'un STA
+	Resources []int               `json:"resources,omitempty"` // Ressources déjà utilisées par l'émetteur d'un STA
+	Leader    int                 `json:"leader,omitempty"`    // Candidat d'un ELE ou leader élu d'un COO
+	Raft      *RaftMessage        `json:"raft,omitempty"`      // Contenu d'une communication du mode de cohérence Raft
+	Ops       []Operation         `json:"ops,omitempty"`       // Opérations effectuées pendant la section critique libérée par un REL
+	Clock     VectorClock         `json:"clock,omitempty"`     // Horloge vectorielle de l'émetteur
+	Clocks    map[int]VectorClock `json:"clocks,omitempty"`    // Horloge vectorielle de la dernière modification de chaque manifestation du payload
+	Snapshot  string              `json:"snapshot,omitempty"`  // Identifiant du snapshot global d'un MRK
+}
+
+// OperationType représente une opération effectuée sur les manifestations utilisée par une "enum" contenant
+// CreateOperation, RegisterOperation et CloseOperation.
+type OperationType string
+
+const (
+	CreateOperation   OperationType = "CREATE"
+	RegisterOperation OperationType = "REGISTER"
+	CloseOperation    OperationType = "CLOSE"
+)
+
+// Operation représente une opération effectuée sur une manifestation pendant une section critique. Elle contient les
+// informations nécessaires pour la rejouer sur la version précédente de la manifestation.
+type Operation struct {
+	Type    OperationType `json:"type"`              // Type d'opération
+	EventId int           `json:"event_id"`          // Id de la manifestation modifiée
+	Prev    int           `json:"prev"`              // Estampille de la manifestation avant l'opération
+	Stamp   int           `json:"stamp"`             // Estampille de la manifestation après l'opération
+	Event   *Event        `json:"event,omitempty"`   // Manifestation créée
+	JobId   int           `json:"job_id,omitempty"`  // Id du job auquel l'utilisateur s'inscrit
+	UserId  int           `json:"user_id,omitempty"` // Id de l'utilisateur qui s'inscrit
+	Clock   VectorClock   `json:"clock,omitempty"`   // Horloge vectorielle de la manifestation après l'opération
+}
+
+// WALEntry représente une entrée du journal des modifications (write-ahead log) d'un serveur. Une entrée contient la
+// nouvelle version d'une manifestation modifiée par une opération, ou uniquement l'estampille de sa dernière
+// modification lorsqu'elle est attribuée après la modification.
+type WALEntry struct {
+	Type    OperationType `json:"type,omitempty"`  // Opération ayant modifié la manifestation, absente si seule son estampille change
+	EventId int           `json:"event_id"`        // Id de la manifestation
+	Event   *Event        `json:"event,omitempty"` // Manifestation après la modification
+	Stamp   int           `json:"stamp"`           // Estampille de la dernière modification de la manifestation
+}
+
+// Checkpoint représente l'état persistant d'un serveur, enregistré périodiquement pour limiter la taille du journal
+// des modifications.
+type Checkpoint struct {
+	Time        time.Time     `json:"time"`         // Date de l'enregistrement
+	Stamp       int           `json:"stamp"`        // Estampille du serveur
+	Users       map[int]User  `json:"users"`        // Utilisateurs pouvant s'authentifier
+	Events      map[int]Event `json:"events"`       // Manifestations
+	EventStamps map[int]int   `json:"event_stamps"` // Estampille de la dernière modification de chaque manifestation
+}
+
+// RaftMessage représente le contenu d'une communication du mode de cohérence Raft.
+type RaftMessage struct {
+	Term         int        `json:"term"`                     // Mandat de l'émetteur
+	PrevLogIndex int        `json:"prev_log_index,omitempty"` // Index de l'entrée précédant les entrées d'un APP, ou de la dernière entrée du candidat d'un RVT
+	PrevLogTerm  int        `json:"prev_log_term,omitempty"`  // Mandat de l'entrée d'index PrevLogIndex
+	Entries      []LogEntry `json:"entries,omitempty"`        // Entrées du journal envoyées par le leader (APP) ou transmises au leader (FWD)
+	LeaderCommit int        `json:"leader_commit,omitempty"`  // Index de la dernière entrée validée par le leader
+	Success      bool       `json:"success,omitempty"`        // Indique si un APP a été accepté ou si le vote est accordé (VTR)
+	MatchIndex   int        `json:"match_index,omitempty"`    // Index de la dernière entrée répliquée par l'émetteur d'un APR
+}
+
+// LogEntry représente une commande d'un client dans le journal répliqué par Raft. Une entrée sans commande est ajoutée
+// par chaque nouveau leader pour valider les entrées des mandats précédents.
+type LogEntry struct {
+	Term    int      `json:"term"`              // Mandat pendant lequel le leader a reçu l'entrée
+	Origin  int      `json:"origin"`            // Numéro du serveur ayant reçu la commande du client
+	ReqId   int      `json:"req_id"`            // Numéro de la requête, croissant pour chaque serveur d'origine
+	Command string   `json:"command,omitempty"` // Nom de la commande
+	Args    []string `json:"args,omitempty"`    // Arguments de la commande
+}
+
+// RaftState représente le mandat et le vote d'un serveur en mode Raft, enregistrés avant chaque réponse à un autre
+// serveur. Le journal est enregistré séparément, une entrée par ligne.
+type RaftState struct {
+	Term     int `json:"term"`      // Mandat actuel
+	VotedFor int `json:"voted_for"` // Serveur ayant reçu le vote du serveur pour le mandat actuel, 0 si aucun
+}
+
+// Snapshot représente l'état d'un serveur enregistré par un snapshot global de Chandy-Lamport. Les snapshots de tous
+// les serveurs ayant le même identifiant forment une coupe cohérente du réseau.
+type Snapshot struct {
+	Id          string                  `json:"id"`              // Identifiant du snapshot global
+	Server      int                     `json:"server"`          // Numéro du serveur enregistré
+	Time        time.Time               `json:"time"`            // Date de l'enregistrement de l'état local
+	Stamp       int                     `json:"stamp"`           // Estampille du serveur
+	Clock       VectorClock             `json:"clock,omitempty"` // Horloge vectorielle du serveur, si activée
+	Events      map[int]Event           `json:"events"`          // Manifestations connues par le serveur
+	EventStamps map[int]int             `json:"event_stamps"`    // Estampille de la dernière modification de chaque manifestation
+	Mutexes     map[int]MutexSnapshot   `json:"mutexes"`         // État de l'algorithme d'exclusion mutuelle de chaque ressource
+	Channels    map[int][]Communication `json:"channels"`        // Communications en transit reçues de chaque autre serveur
+}
+
+// MutexSnapshot représente l'état de l'algorithme d'exclusion mutuelle d'une ressource enregistré par un snapshot.
+type MutexSnapshot struct {
+	Status string                `json:"status"`          // Représentation de l'état de l'algorithme affichée dans les logs
+	Comms  map[int]Communication `json:"comms,omitempty"` // Dernière communication reçue de chaque serveur (Lamport)
+}
+
+// Token représente le jeton de l'algorithme de Suzuki-Kasami. Il est accompagné des manifestations protégées par sa
+// ressource lors de son transfert pour que son détenteur ait toujours leur dernière version. L'algorithme de Raymond
+// transfère un jeton sans contenu.
+type Token struct {
+	LN    map[int]int `json:"ln"`    // Numéro de séquence de la dernière requête satisfaite de chaque serveur
+	Queue []int       `json:"queue"` // File des serveurs en attente du jeton
 }
 
 // Command est un type représentant une commande valide à envoyer par un client au serveur.
 type Command struct {
-	Name       string // Nom de la commande
-	Auth       bool   // Indique si la commande nécessite des credentials
-	MinArgs    int    // Nombre minimum d'arguments
-	MinOptArgs int    // Nombre minimum d'arguments optionnels
+	Name       string `json:"name"`         // Nom de la commande
+	Auth       bool   `json:"auth"`         // Indique si la commande nécessite des credentials
+	MinArgs    int    `json:"min_args"`     // Nombre minimum d'arguments
+	MinOptArgs int    `json:"min_opt_args"` // Nombre minimum d'arguments optionnels
+	ReadOnly   bool   `json:"read_only"`    // Indique si la commande ne fait que lire les manifestations
+}
+
+// ErrorCode représente l'erreur retournée par une commande par une "enum" dont les valeurs correspondent aux messages
+// d'erreur de utils.MESSAGE.Error, ainsi qu'à une commande vide et à une requête JSON invalide.
+type ErrorCode string
+
+const (
+	InvalidCommand      ErrorCode = "INVALID_COMMAND"
+	InvalidNbArgs       ErrorCode = "INVALID_NB_ARGS"
+	AccessDenied        ErrorCode = "ACCESS_DENIED"
+	MustBeInteger       ErrorCode = "MUST_BE_INTEGER"
+	EventNotFound       ErrorCode = "EVENT_NOT_FOUND"
+	EventClosed         ErrorCode = "EVENT_CLOSED"
+	JobNotFound         ErrorCode = "JOB_NOT_FOUND"
+	NotCreator          ErrorCode = "NOT_CREATOR"
+	AlreadyClosed       ErrorCode = "ALREADY_CLOSED"
+	IdEventNotMatchJob  ErrorCode = "ID_EVENT_NOT_MATCH_JOB"
+	CreatorRegister     ErrorCode = "CREATOR_REGISTER"
+	JobFull             ErrorCode = "JOB_FULL"
+	AlreadyRegistered   ErrorCode = "ALREADY_REGISTERED"
+	NbVolunteersInteger ErrorCode = "NB_VOLUNTEERS_INTEGER"
+	CommandTimeout      ErrorCode = "COMMAND_TIMEOUT"
+	EmptyCommand        ErrorCode = "EMPTY_COMMAND"
+	InvalidRequest      ErrorCode = "INVALID_REQUEST"
+)
+
+// JSONRequest représente une commande envoyée par un client en mode JSON.
+type JSONRequest struct {
+	Id       string   `json:"id,omitempty"`       // Identifiant libre de la requête, repris par sa réponse
+	Command  string   `json:"command"`            // Nom de la commande
+	Args     []string `json:"args,omitempty"`     // Arguments de la commande, sans les credentials
+	Username string   `json:"username,omitempty"` // Nom d'utilisateur des commandes nécessitant des credentials
+	Password string   `json:"password,omitempty"` // Mot de passe des commandes nécessitant des credentials
+	Strong   bool     `json:"strong,omitempty"`   // Fait passer une commande de lecture par la section critique distribuée
+}
+
+// CreateEventRequest représente la création d'une manifestation envoyée à la passerelle HTTP.
+type CreateEventRequest struct {
+	Name string             `json:"name"` // Nom de la manifestation
+	Jobs []CreateJobRequest `json:"jobs"` // Jobs de la manifestation
+}
+
+// CreateJobRequest représente un job d'une manifestation à créer.
+type CreateJobRequest struct {
+	Name         string `json:"name"`          // Nom du job
+	NbVolunteers int    `json:"nb_volunteers"` // Nombre de bénévoles requis
+}
+
+// Response représente la réponse d'un serveur à une commande. Elle est envoyée telle quelle aux clients en mode JSON et
+// affichée par le serveur pour les autres clients. Seuls les champs concernant la commande sont présents.
+type Response struct {
+	Id       string        `json:"id,omitempty"`       // Identifiant de la requête en mode JSON
+	Command  string        `json:"command,omitempty"`  // Nom de la commande
+	Ok       bool          `json:"ok"`                 // Indique si la commande a réussi
+	Error    ErrorCode     `json:"error,omitempty"`    // Code de l'erreur si la commande a échoué
+	Message  string        `json:"message,omitempty"`  // Message de confirmation ou d'erreur, sans mise en forme
+	Event    *EventInfo    `json:"event,omitempty"`    // Manifestation concernée par la commande
+	Events   []EventInfo   `json:"events,omitempty"`   // Manifestations de la commande "show" sans argument
+	Commands []Command     `json:"commands,omitempty"` // Commandes de la commande "help"
+	Peers    []PeerInfo    `json:"peers,omitempty"`    // Serveurs du réseau de la commande "peers"
+	Leader   *LeaderInfo   `json:"leader,omitempty"`   // Leader de la commande "leader"
+	Status   *StatusInfo   `json:"status,omitempty"`   // État interne du serveur de la commande "status"
+	Snapshot *SnapshotInfo `json:"snapshot,omitempty"` // Snapshot global lancé par la commande "snapshot"
+}
+
+// EventInfo représente une manifestation dans une réponse, avec le nom de son organisateur et de ses bénévoles.
+type EventInfo struct {
+	Id      int       `json:"id"`      // Id de la manifestation
+	Name    string    `json:"name"`    // Nom de la manifestation
+	Creator string    `json:"creator"` // Nom d'utilisateur de l'organisateur
+	Closed  bool      `json:"closed"`  // Indique si la manifestation est fermée
+	Jobs    []JobInfo `json:"jobs"`    // Jobs de la manifestation, triés par id
+}
+
+// JobInfo représente un job d'une manifestation dans une réponse.
+type JobInfo struct {
+	Id           int      `json:"id"`            // Id du job
+	Name         string   `json:"name"`          // Nom du job
+	NbVolunteers int      `json:"nb_volunteers"` // Nombre de bénévoles requis
+	Volunteers   []string `json:"volunteers"`    // Noms d'utilisateur des bénévoles inscrits, dans l'ordre d'inscription
+}
+
+// PeerInfo représente l'état d'un serveur du réseau selon le détecteur de défaillances.
+type PeerInfo struct {
+	Server   int        `json:"server"`              // Numéro du serveur
+	Self     bool       `json:"self,omitempty"`      // Indique s'il s'agit du serveur ayant répondu
+	State    PeerState  `json:"state,omitempty"`     // État du serveur
+	LastSeen *time.Time `json:"last_seen,omitempty"` // Date de la dernière communication reçue du serveur
+}
+
+// LeaderInfo représente le leader élu et l'algorithme d'élection utilisé.
+type LeaderInfo struct {
+	Leader   int    `json:"leader"`         // Numéro du leader, 0 si une élection est en cours
+	Self     bool   `json:"self,omitempty"` // Indique si le serveur ayant répondu est le leader
+	Election string `json:"election"`       // Algorithme d'élection
+}
+
+// StatusInfo représente l'état interne d'un serveur.
+type StatusInfo struct {
+	Server      int              `json:"server"`                 // Numéro du serveur
+	Stamp       int              `json:"stamp"`                  // Estampille de Lamport
+	VectorClock VectorClock      `json:"vector_clock,omitempty"` // Horloge vectorielle, si activée
+	Conflicts   int              `json:"conflicts"`              // Nombre de modifications concurrentes détectées
+	Messages    int              `json:"messages"`               // Nombre de messages envoyés aux autres serveurs
+	Accesses    int              `json:"accesses"`               // Nombre d'accès à la section critique distribuée
+	Raft        *RaftInfo        `json:"raft,omitempty"`         // État du journal répliqué en mode Raft
+	Mutex       MutexType        `json:"mutex,omitempty"`        // Algorithme d'exclusion mutuelle hors mode Raft
+	Mutexes     []MutexInfo      `json:"mutexes,omitempty"`      // État de l'algorithme de chaque ressource
+	Events      []EventStampInfo `json:"events"`                 // Dernière modification de chaque manifestation
+}
+
+// RaftInfo représente l'état du journal répliqué d'un serveur en mode Raft.
+type RaftInfo struct {
+	Role        string `json:"role"`         // Rôle du serveur
+	Term        int    `json:"term"`         // Mandat actuel
+	CommitIndex int    `json:"commit_index"` // Index de la dernière entrée validée
+	LogLength   int    `json:"log_length"`   // Nombre d'entrées du journal
+}
+
+// MutexInfo représente l'état de l'algorithme d'exclusion mutuelle d'une ressource.
+type MutexInfo struct {
+	Resource int    `json:"resource"` // Ressource, 0 pour la création de manifestations
+	Status   string `json:"status"`   // État de l'algorithme
+}
+
+// EventStampInfo représente la dernière modification d'une manifestation.
+type EventStampInfo struct {
+	Id    int         `json:"id"`              // Id de la manifestation
+	Stamp int         `json:"stamp"`           // Estampille de la dernière modification
+	Clock VectorClock `json:"clock,omitempty"` // Horloge vectorielle de la dernière modification, si activée
+}
+
+// SnapshotInfo représente un snapshot global lancé par un serveur.
+type SnapshotInfo struct {
+	Id   string `json:"id"`   // Identifiant du snapshot
+	Path string `json:"path"` // Chemin des fichiers écrits par chaque serveur, <number> étant le numéro du serveur
+}
+
+// FrameStatus représente le statut d'une réponse du protocole framed par une "enum" contenant StatusOK, StatusError,
+// StatusClosed et StatusNotification.
+type FrameStatus uint8
+
+const (
+	StatusOK           FrameStatus = 0 // Commande exécutée
+	StatusError        FrameStatus = 1 // Commande refusée ou en erreur
+	StatusClosed       FrameStatus = 2 // Session fermée par la commande "quit"
+	StatusNotification FrameStatus = 3 // Modification d'une manifestation suivie, envoyée avec l'id 0 sans requête
+)
+
+// Frame représente une requête ou une réponse du protocole framed entre un client et un serveur.
+type Frame struct {
+	Id     uint32      // Identifiant de la requête choisi par le client, repris par sa réponse
+	Status FrameStatus // Statut de la réponse, toujours StatusOK pour une requête
+	Body   string      // Commande ou réponse
+}
+
+// WSOpcode représente le type d'une frame WebSocket (RFC 6455).
+type WSOpcode uint8
+
+const (
+	WSContinuation WSOpcode = 0x0 // Suite d'un message fragmenté
+	WSText         WSOpcode = 0x1 // Message texte
+	WSBinary       WSOpcode = 0x2 // Message binaire
+	WSClose        WSOpcode = 0x8 // Fermeture de la connexion
+	WSPing         WSOpcode = 0x9 // Ping, auquel l'autre extrémité répond par un pong
+	WSPong         WSOpcode = 0xA // Réponse à un ping
+)
+
+// WSFrame représente une frame WebSocket.
+type WSFrame struct {
+	Fin     bool     // Indique s'il s'agit de la dernière frame du message
+	Opcode  WSOpcode // Type de la frame
+	Payload []byte   // Contenu de la frame, démasqué
+}
+
+// NotificationType représente la modification d'une manifestation signalée aux clients par une "enum" contenant
+// NotifyCreated, NotifyClosed et NotifyRegistered.
+type NotificationType string
+
+const (
+	NotifyCreated    NotificationType = "created"    // Manifestation créée
+	NotifyClosed     NotificationType = "closed"     // Manifestation fermée
+	NotifyRegistered NotificationType = "registered" // Inscriptions d'une manifestation modifiées
+)
+
+// Notification représente la modification d'une manifestation envoyée aux clients abonnés sans qu'ils l'aient demandée.
+type Notification struct {
+	Type  NotificationType `json:"notification"` // Modification de la manifestation
+	Event EventInfo        `json:"event"`        // Manifestation après sa modification
 }
 
 // User est un type représentant un utilisateur pouvant être un organisateur de manifestations ou un bénévole s'inscrivant à des jobs.
@@ -4,7 +4,7 @@
 package utils
 
 import (
-	"net"
+	"sort"
 	"strconv"
 	"strings"
 )
@@ -17,15 +17,36 @@ func Max(a int, b int) int {
 	return b
 }
 
-// MapKeysToArray retourne les clés d'une map sous forme de tableau
-func MapKeysToArray[T net.Conn](m map[int]T) []int {
+// Min retourne le minimum entre deux entiers
+func Min(a int, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+// MapKeysToArray retourne les clés d'une map sous forme de tableau trié dans l'ordre croissant
+func MapKeysToArray[T any](m map[int]T) []int {
 	keys := make([]int, 0, len(m))
 	for k := range m {
 		keys = append(keys, k)
 	}
+	sort.Ints(keys)
 	return keys
 }
 
+// CopyMap retourne une copie d'une map, ou nil si la map est nil
+func CopyMap[K comparable, V any](m map[K]V) map[K]V {
+	if m == nil {
+		return nil
+	}
+	copied := make(map[K]V, len(m))
+	for k, v := range m {
+		copied[k] = v
+	}
+	return copied
+}
+
 // IntToString retourne un affichage spécifique identifiant un serveur avec son numéro
 func IntToString(numArray []int) string {
 	numArrayStr := make([]string, len(numArray))
@@ -0,0 +1,237 @@
+// Auteurs: Jonathan Friedli, Lazar Pavicevic
+// Labo 2 SDR
+
+package utils
+
+import (
+	"errors"
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/Lazzzer/labo1-sdr/internal/utils/types"
+)
+
+// problems accumule les erreurs de validation d'une configuration ou des entités afin de toutes les signaler en une
+// seule fois.
+type problems []string
+
+// add ajoute une erreur de validation.
+func (p *problems) add(message string) {
+	*p = append(*p, message)
+}
+
+// err retourne une erreur contenant toutes les erreurs de validation, ou nil s'il n'y en a aucune.
+func (p problems) err() error {
+	if len(p) == 0 {
+		return nil
+	}
+	return errors.New(strings.Join(p, "; "))
+}
+
+// validateConfig vérifie qu'une configuration contient au moins un serveur et que leurs adresses sont valides.
+func validateConfig(config types.Config) error {
+	var p problems
+	checkServers(&p, config)
+	return p.err()
+}
+
+// validateServerConfig vérifie qu'une configuration de serveur est utilisable : adresses et ports valides, maps
+// associées à des serveurs existants, arbre logique de Raymond valide, algorithmes connus et durées positives.
+func validateServerConfig(config types.ServerConfig) error {
+	var p problems
+	checkServers(&p, config.Config)
+
+	for _, number := range MapKeysToArray(config.Servers) {
+		if port, ok := config.ClientPorts[number]; !ok {
+			p.add("client_ports: missing port for server " + strconv.Itoa(number))
+		} else if !validPort(port) {
+			p.add("client_ports." + strconv.Itoa(number) + ": invalid port \"" + port + "\"")
+		}
+	}
+	for _, number := range MapKeysToArray(config.ClientPorts) {
+		checkServerKey(&p, config.Config, "client_ports", number)
+	}
+	for _, number := range MapKeysToArray(config.HTTPPorts) {
+		checkServerKey(&p, config.Config, "http_ports", number)
+		if !validPort(config.HTTPPorts[number]) {
+			p.add("http_ports." + strconv.Itoa(number) + ": invalid port \"" + config.HTTPPorts[number] + "\"")
+		}
+	}
+	for _, number := range MapKeysToArray(config.DataDirs) {
+		checkServerKey(&p, config.Config, "data_dirs", number)
+	}
+	for _, number := range MapKeysToArray(config.Tree) {
+		checkServerKey(&p, config.Config, "tree", number)
+	}
+	if config.Mutex == types.Raymond {
+		checkTree(&p, RaymondTree(config), config.Servers)
+	}
+
+	switch config.Mutex {
+	case "", types.Lamport, types.RicartAgrawala, types.SuzukiKasami, types.Raymond, types.Maekawa:
+	default:
+		p.add("mutex: unknown algorithm \"" + string(config.Mutex) + "\"")
+	}
+	switch config.Election {
+	case "", types.Bully, types.ChangRoberts:
+	default:
+		p.add("election: unknown algorithm \"" + string(config.Election) + "\"")
+	}
+	switch config.Consistency {
+	case "", types.MutexConsistency, types.RaftConsistency:
+	default:
+		p.add("consistency: unknown mode \"" + string(config.Consistency) + "\"")
+	}
+	switch config.Repository {
+	case "", types.MemoryRepository, types.KVRepository:
+	default:
+		p.add("repository: unknown repository \"" + string(config.Repository) + "\"")
+	}
+
+	durations := []struct {
+		field string
+		value int
+	}{
+		{"debug_delay", config.DebugDelay},
+		{"heartbeat_interval", config.HeartbeatInterval},
+		{"suspicion_timeout", config.SuspicionTimeout},
+		{"checkpoint_interval", config.CheckpointInterval},
+	}
+	for _, duration := range durations {
+		if duration.value < 0 {
+			p.add(duration.field + ": must not be negative")
+		}
+	}
+
+	return p.err()
+}
+
+// validateEntities vérifie que les entités sont cohérentes : ids des manifestations et des jobs consécutifs à partir
+// de 1, comme les attribue le serveur, organisateurs et bénévoles existants et jobs non surchargés.
+func validateEntities(entities types.Entities) error {
+	var p problems
+
+	for _, id := range MapKeysToArray(entities.Users) {
+		if entities.Users[id].Username == "" {
+			p.add("users." + strconv.Itoa(id) + ": missing username")
+		}
+	}
+
+	for i, id := range MapKeysToArray(entities.Events) {
+		event := entities.Events[id]
+		field := "events." + strconv.Itoa(id)
+		if id != i+1 {
+			p.add(field + ": event ids must be consecutive from 1, expected id " + strconv.Itoa(i+1))
+		}
+		if _, ok := entities.Users[event.CreatorId]; !ok {
+			p.add(field + ": unknown creator " + strconv.Itoa(event.CreatorId))
+		}
+
+		for j, jobId := range MapKeysToArray(event.Jobs) {
+			job := event.Jobs[jobId]
+			jobField := field + ".jobs." + strconv.Itoa(jobId)
+			if jobId != j+1 {
+				p.add(jobField + ": job ids must be consecutive from 1, expected id " + strconv.Itoa(j+1))
+			}
+			if job.NbVolunteers < 0 {
+				p.add(jobField + ": nb_volunteers must not be negative")
+			} else if len(job.VolunteerIds) > job.NbVolunteers {
+				p.add(jobField + ": more volunteers than nb_volunteers")
+			}
+			for _, userId := range job.VolunteerIds {
+				if _, ok := entities.Users[userId]; !ok {
+					p.add(jobField + ": unknown volunteer " + strconv.Itoa(userId))
+				}
+			}
+		}
+	}
+
+	return p.err()
+}
+
+// RaymondTree retourne l'arbre logique de l'algorithme de Raymond déclaré dans la configuration. Si aucun arbre n'est
+// déclaré, un arbre binaire est utilisé où le parent du serveur i est i/2.
+func RaymondTree(config types.ServerConfig) map[int]int {
+	if len(config.Tree) > 0 {
+		return config.Tree
+	}
+
+	tree := make(map[int]int, len(config.Servers))
+	for _, number := range MapKeysToArray(config.Servers) {
+		tree[number] = number / 2
+	}
+	return tree
+}
+
+// ValidateTree vérifie que l'arbre logique contient tous les serveurs, qu'il possède une unique racine et que chaque
+// serveur remonte jusqu'à la racine sans cycle.
+func ValidateTree(tree map[int]int, servers map[int]string) error {
+	var p problems
+	checkTree(&p, tree, servers)
+	return p.err()
+}
+
+// checkTree ajoute les erreurs de l'arbre logique de Raymond : serveur manquant, parent inconnu, nombre de racines
+// différent de un ou cycle.
+func checkTree(p *problems, tree map[int]int, servers map[int]string) {
+	roots := 0
+	valid := true
+	for _, number := range MapKeysToArray(servers) {
+		parent, ok := tree[number]
+		if !ok {
+			p.add("tree: missing parent for server " + strconv.Itoa(number))
+			valid = false
+		} else if parent == 0 {
+			roots++
+		} else if _, ok := servers[parent]; !ok || parent == number {
+			p.add("tree." + strconv.Itoa(number) + ": parent " + strconv.Itoa(parent) + " is not a valid server")
+			valid = false
+		}
+	}
+	if roots != 1 {
+		p.add("tree: expected exactly one root, found " + strconv.Itoa(roots))
+		valid = false
+	}
+	if !valid {
+		return
+	}
+
+	for _, number := range MapKeysToArray(servers) {
+		current := number
+		for steps := 0; tree[current] != 0; steps++ {
+			if steps > len(servers) {
+				p.add("tree: cycle detected from server " + strconv.Itoa(number))
+				return
+			}
+			current = tree[current]
+		}
+	}
+}
+
+// checkServers vérifie qu'une configuration contient au moins un serveur et que leurs adresses sont de la forme
+// hôte:port.
+func checkServers(p *problems, config types.Config) {
+	if len(config.Servers) == 0 {
+		p.add("servers: at least one server is required")
+	}
+	for _, number := range MapKeysToArray(config.Servers) {
+		address := config.Servers[number]
+		if _, port, err := net.SplitHostPort(address); err != nil || !validPort(port) {
+			p.add("servers." + strconv.Itoa(number) + ": invalid address \"" + address + "\", expected host:port")
+		}
+	}
+}
+
+// checkServerKey vérifie que la clé d'une map de la configuration correspond à un serveur.
+func checkServerKey(p *problems, config types.Config, field string, number int) {
+	if _, ok := config.Servers[number]; !ok {
+		p.add(field + "." + strconv.Itoa(number) + ": unknown server " + strconv.Itoa(number))
+	}
+}
+
+// validPort indique si une string est un numéro de port valide.
+func validPort(port string) bool {
+	number, err := strconv.Atoi(port)
+	return err == nil && number > 0 && number <= 65535
+}
@@ -0,0 +1,121 @@
+// Auteurs: Jonathan Friedli, Lazar Pavicevic
+// Labo 2 SDR
+
+package utils
+
+import (
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"io"
+
+	"github.com/Lazzzer/labo1-sdr/internal/utils/types"
+)
+
+// Les fonctions suivantes implémentent le découpage en frames du protocole WebSocket (RFC 6455), sans extensions :
+//
+//	| FIN + opcode (1 octet) | masque + longueur (1 octet) | longueur étendue (0, 2 ou 8 octets) | clé (0 ou 4 octets) | contenu |
+//
+// Les frames envoyées par un client sont masquées par une clé aléatoire, celles envoyées par un serveur ne le sont pas.
+
+// wsGUID est la constante ajoutée à la clé du client pour calculer la réponse du serveur lors de l'ouverture
+const wsGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+// WSAcceptKey retourne la valeur de l'en-tête Sec-WebSocket-Accept correspondant à la clé Sec-WebSocket-Key d'un client.
+func WSAcceptKey(key string) string {
+	hash := sha1.Sum([]byte(key + wsGUID))
+	return base64.StdEncoding.EncodeToString(hash[:])
+}
+
+// WriteWSFrame écrit une frame WebSocket en un seul appel à Write. Le contenu est masqué si mask est vrai, ce qui est
+// obligatoire pour les frames envoyées par un client.
+func WriteWSFrame(w io.Writer, frame types.WSFrame, mask bool) error {
+	if len(frame.Payload) > MaxFrameSize {
+		return fmt.Errorf("websocket payload of %d bytes exceeds the maximum of %d bytes", len(frame.Payload), MaxFrameSize)
+	}
+
+	header := make([]byte, 2, 14)
+	header[0] = byte(frame.Opcode)
+	if frame.Fin {
+		header[0] |= 0x80
+	}
+
+	length := len(frame.Payload)
+	switch {
+	case length < 126:
+		header[1] = byte(length)
+	case length <= 0xFFFF:
+		header[1] = 126
+		header = binary.BigEndian.AppendUint16(header, uint16(length))
+	default:
+		header[1] = 127
+		header = binary.BigEndian.AppendUint64(header, uint64(length))
+	}
+
+	payload := frame.Payload
+	if mask {
+		var key [4]byte
+		if _, err := rand.Read(key[:]); err != nil {
+			return err
+		}
+		header[1] |= 0x80
+		header = append(header, key[:]...)
+		payload = make([]byte, length)
+		for i := range frame.Payload {
+			payload[i] = frame.Payload[i] ^ key[i%4]
+		}
+	}
+
+	_, err := w.Write(append(header, payload...))
+	return err
+}
+
+// ReadWSFrame lit la prochaine frame WebSocket et démasque son contenu. Une frame dont le contenu dépasse MaxFrameSize
+// retourne une erreur.
+func ReadWSFrame(r io.Reader) (types.WSFrame, error) {
+	var header [2]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return types.WSFrame{}, err
+	}
+	frame := types.WSFrame{Fin: header[0]&0x80 != 0, Opcode: types.WSOpcode(header[0] & 0x0F)}
+	masked := header[1]&0x80 != 0
+
+	length := uint64(header[1] & 0x7F)
+	switch length {
+	case 126:
+		var extended [2]byte
+		if _, err := io.ReadFull(r, extended[:]); err != nil {
+			return types.WSFrame{}, unexpected(err)
+		}
+		length = uint64(binary.BigEndian.Uint16(extended[:]))
+	case 127:
+		var extended [8]byte
+		if _, err := io.ReadFull(r, extended[:]); err != nil {
+			return types.WSFrame{}, unexpected(err)
+		}
+		length = binary.BigEndian.Uint64(extended[:])
+	}
+	if length > MaxFrameSize {
+		return types.WSFrame{}, fmt.Errorf("invalid websocket payload length %d", length)
+	}
+
+	var key [4]byte
+	if masked {
+		if _, err := io.ReadFull(r, key[:]); err != nil {
+			return types.WSFrame{}, unexpected(err)
+		}
+	}
+	frame.Payload = make([]byte, length)
+	if _, err := io.ReadFull(r, frame.Payload); err != nil {
+		return types.WSFrame{}, unexpected(err)
+	}
+	if masked {
+		for i := range frame.Payload {
+			frame.Payload[i] ^= key[i%4]
+		}
+	}
+
+	return frame, nil
+}
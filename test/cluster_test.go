@@ -0,0 +1,594 @@
+// Auteurs: Jonathan Friedli, Lazar Pavicevic
+// Labo 2 SDR
+
+package test
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Lazzzer/labo1-sdr/internal/server"
+	"github.com/Lazzzer/labo1-sdr/internal/utils"
+	"github.com/Lazzzer/labo1-sdr/internal/utils/types"
+)
+
+// clusterSize est le nombre de serveurs du cluster de test
+const clusterSize = 3
+
+// clusterNetwork est le réseau en mémoire reliant les serveurs du cluster de test
+var clusterNetwork = server.NewMemoryNetwork()
+
+// clusterPeer retourne l'adresse d'un serveur du cluster sur le réseau en mémoire
+func clusterPeer(number int) string {
+	return "localhost:" + strconv.Itoa(8110+number)
+}
+
+// clusterClientPort retourne le port TCP sur lequel un serveur du cluster écoute les clients
+func clusterClientPort(number int) string {
+	return strconv.Itoa(8190 + number)
+}
+
+// clusterHTTPPort retourne le port sur lequel un serveur du cluster écoute les requêtes HTTP et WebSocket
+func clusterHTTPPort(number int) string {
+	return strconv.Itoa(8290 + number)
+}
+
+// init() lance les serveurs du cluster de test dans le processus des tests
+func init() {
+	servers := make(map[int]string, clusterSize)
+	clientPorts := make(map[int]string, clusterSize)
+	for i := 1; i <= clusterSize; i++ {
+		servers[i] = clusterPeer(i)
+		clientPorts[i] = clusterClientPort(i)
+	}
+
+	for i := 1; i <= clusterSize; i++ {
+		config := types.ServerConfig{Config: types.Config{Address: servers[i], Servers: servers}, ClientPorts: clientPorts, Silent: true}
+		serv := server.NewServer(i, strings.Split(servers[i], ":")[1], clientPorts[i], config, server.DefaultEntities())
+		serv.Transport = clusterNetwork.Transport(servers[i])
+		serv.HTTPPort = clusterHTTPPort(i)
+		go serv.Run()
+	}
+}
+
+// testCluster est un cluster de serveurs lancés en même temps dans le processus des tests sur leur propre réseau en
+// mémoire. Un serveur relancé écoute les clients sur un nouveau port, celui de son instance précédente restant ouvert.
+type testCluster struct {
+	network   *server.MemoryNetwork
+	config    types.ServerConfig
+	base      int                                     // Premier port du cluster, suivi d'une vingtaine de ports par instance
+	instances map[int]int                             // Nombre d'instances lancées de chaque serveur
+	wrap      func(server.Transport) server.Transport // Modifie la couche réseau des serveurs, aucune modification si nil
+	dataDirs  map[int]string                          // Dossier des données de chaque serveur, aucune persistance si absent
+	servers   map[int]*server.Server                  // Dernière instance lancée de chaque serveur
+}
+
+// newTestCluster crée un cluster de serveurs numérotés de 1 à size, jusqu'à 19, sans les lancer. La configuration
+// partagée par les serveurs peut être modifiée par configure.
+func newTestCluster(base int, size int, configure func(*types.ServerConfig)) *testCluster {
+	c := &testCluster{network: server.NewMemoryNetwork(), base: base, instances: make(map[int]int, size), servers: make(map[int]*server.Server, size)}
+	c.config = types.ServerConfig{Config: types.Config{Servers: make(map[int]string, size)}, ClientPorts: make(map[int]string, size), Silent: true}
+	for i := 1; i <= size; i++ {
+		c.config.Servers[i] = c.peer(i)
+		c.config.ClientPorts[i] = c.clientPort(i)
+	}
+	if configure != nil {
+		configure(&c.config)
+	}
+	return c
+}
+
+// peer retourne l'adresse d'un serveur du cluster sur le réseau en mémoire
+func (c *testCluster) peer(number int) string {
+	return "localhost:" + strconv.Itoa(c.base+number)
+}
+
+// clientPort retourne le port TCP sur lequel la dernière instance d'un serveur écoute les clients
+func (c *testCluster) clientPort(number int) string {
+	return strconv.Itoa(c.base + 20*utils.Max(c.instances[number], 1) + number)
+}
+
+// start lance une nouvelle instance d'un serveur avec les entités par défaut. Un serveur qui quitte le réseau est isolé
+// au lieu d'arrêter le processus des tests.
+func (c *testCluster) start(number int) {
+	c.instances[number]++
+	config := c.config
+	config.Address = c.peer(number)
+	serv := server.NewServer(number, strconv.Itoa(c.base+number), c.clientPort(number), config, server.DefaultEntities())
+	serv.Transport = c.network.Transport(c.peer(number))
+	serv.DataDir = c.dataDirs[number]
+	serv.Exit = func(int) { c.kill(number) }
+	c.servers[number] = serv
+	if c.wrap != nil {
+		serv.Transport = c.wrap(serv.Transport)
+	}
+	go serv.Run()
+}
+
+// startAll lance tous les serveurs du cluster en même temps
+func (c *testCluster) startAll() {
+	for _, number := range utils.MapKeysToArray(c.config.Servers) {
+		c.start(number)
+	}
+}
+
+// connect ouvre une session de client sur la dernière instance d'un serveur du cluster
+func (c *testCluster) connect(t *testing.T, number int) *clusterSession {
+	return dialSession(t, c.clientPort(number), number, "cluster-client")
+}
+
+// connectJSON ouvre une session de client en mode JSON sur la dernière instance d'un serveur du cluster
+func (c *testCluster) connectJSON(t *testing.T, number int) *jsonSession {
+	session := dialSession(t, c.clientPort(number), number, "cluster-client "+utils.JSON_MODE)
+	return &jsonSession{conn: session.conn, reader: session.reader, number: number}
+}
+
+// connectAll ouvre une session de client sur chaque serveur du cluster, indexée par son numéro
+func (c *testCluster) connectAll(t *testing.T) map[int]*clusterSession {
+	sessions := make(map[int]*clusterSession, len(c.config.Servers))
+	for _, number := range utils.MapKeysToArray(c.config.Servers) {
+		session := c.connect(t, number)
+		sessions[number] = session
+		t.Cleanup(func() { session.conn.Close() })
+	}
+	return sessions
+}
+
+// connectAllJSON ouvre une session de client en mode JSON sur chaque serveur du cluster, indexée par son numéro
+func (c *testCluster) connectAllJSON(t *testing.T) map[int]*jsonSession {
+	sessions := make(map[int]*jsonSession, len(c.config.Servers))
+	for _, number := range utils.MapKeysToArray(c.config.Servers) {
+		session := c.connectJSON(t, number)
+		sessions[number] = session
+		t.Cleanup(func() { session.conn.Close() })
+	}
+	return sessions
+}
+
+// kill simule le crash d'un serveur du cluster en l'isolant du réseau
+func (c *testCluster) kill(number int) {
+	c.network.Isolate(c.peer(number))
+}
+
+// delayedTransport est une couche réseau dont les connexions aux autres serveurs sont établies après un délai
+type delayedTransport struct {
+	server.Transport
+	delay time.Duration
+}
+
+// Dial attend le délai de la couche réseau avant de se connecter à un autre serveur
+func (dt delayedTransport) Dial(address string) (net.Conn, error) {
+	time.Sleep(dt.delay)
+	return dt.Transport.Dial(address)
+}
+
+// clusterSession est une connexion de client à un serveur d'un cluster de test
+type clusterSession struct {
+	conn   net.Conn
+	reader *bufio.Reader
+	number int // Numéro du serveur
+}
+
+// connect ouvre une session de client sur un serveur du cluster
+func connect(t *testing.T, number int) *clusterSession {
+	return dialSession(t, clusterClientPort(number), number, "cluster-client")
+}
+
+// dialSession ouvre une session de client ayant le nom donné sur le port d'un serveur en attendant qu'il soit prêt à
+// recevoir des clients
+func dialSession(t *testing.T, port string, number int, name string) *clusterSession {
+	var conn net.Conn
+	var err error
+
+	for deadline := time.Now().Add(10 * time.Second); time.Now().Before(deadline); time.Sleep(20 * time.Millisecond) {
+		if conn, err = net.Dial("tcp", "localhost:"+port); err == nil {
+			break
+		}
+	}
+	if err != nil {
+		t.Fatal(utils.RED + "FAIL: " + utils.RESET + "Error: could not connect to server #" + strconv.Itoa(number) + " on port " + port)
+	}
+
+	if _, err := conn.Write([]byte(name + "\n")); err != nil {
+		t.Fatal(utils.RED + "FAIL: " + utils.RESET + "Error: could not send name to server #" + strconv.Itoa(number))
+	}
+	// Le serveur lit le nom du client avec son propre buffer, la première commande ne doit pas être envoyée avec lui
+	time.Sleep(10 * time.Millisecond)
+	return &clusterSession{conn: conn, reader: bufio.NewReader(conn), number: number}
+}
+
+// request envoie une commande et lit la réponse du serveur jusqu'à sa ligne de fin
+func (cs *clusterSession) request(input string) (string, error) {
+	if _, err := cs.conn.Write([]byte(input + "\n")); err != nil {
+		return "", err
+	}
+
+	var response strings.Builder
+	out := make([]byte, 4096)
+	for !strings.HasSuffix(response.String(), responseEnd) {
+		n, err := cs.reader.Read(out)
+		if err != nil {
+			return response.String(), err
+		}
+		response.Write(out[:n])
+	}
+	return response.String(), nil
+}
+
+// send envoie une commande et retourne la réponse du serveur, le test échouant si la réponse ne peut pas être lue
+func (cs *clusterSession) send(t *testing.T, input string) string {
+	response, err := cs.request(input)
+	if err != nil {
+		t.Fatal(utils.RED + "FAIL: " + utils.RESET + "Error: could not read the response of server #" + strconv.Itoa(cs.number) + ": " + err.Error())
+	}
+	return response
+}
+
+// waitFor renvoie une commande jusqu'à ce que sa réponse contienne le texte attendu
+func (cs *clusterSession) waitFor(t *testing.T, input string, expected string, description string) {
+	var response string
+	for i := 0; i < 100; i++ {
+		if response = cs.send(t, input); strings.Contains(response, expected) {
+			fmt.Println(utils.GREEN + "PASS: " + utils.RESET + description)
+			return
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	t.Error("\n" + utils.RED + "FAIL: " + utils.RESET + description + utils.GREEN + "\n\nExpected to contain\n" + utils.RESET + expected + utils.RED + "\nReceived\n" + utils.RESET + response)
+}
+
+// create crée une manifestation avec un job et retourne son id
+func (cs *clusterSession) create(name string) (int, error) {
+	response, err := cs.request("create " + name + " Bar 1 lazar root")
+	if err != nil {
+		return 0, err
+	}
+
+	// La confirmation de création contient "Event #<id> <nom>"
+	_, after, found := strings.Cut(response, "Event #")
+	if before, _, ok := strings.Cut(after, " "+name+" "); found && ok {
+		if id, err := strconv.Atoi(before); err == nil {
+			return id, nil
+		}
+	}
+	return 0, errors.New("create " + name + " failed on server #" + strconv.Itoa(cs.number) + ": " + response)
+}
+
+// createConcurrently crée en même temps le nombre de manifestations donné sur chaque session et retourne leurs ids
+func createConcurrently(t *testing.T, sessions map[int]*clusterSession, nbEvents int) []int {
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	var ids []int
+
+	for number, session := range sessions {
+		wg.Add(1)
+		go func(number int, session *clusterSession) {
+			defer wg.Done()
+			for i := 0; i < nbEvents; i++ {
+				id, err := session.create("S" + strconv.Itoa(number) + "-" + strconv.Itoa(i))
+				if err != nil {
+					t.Error(utils.RED + "FAIL: " + utils.RESET + err.Error())
+					return
+				}
+				mu.Lock()
+				ids = append(ids, id)
+				mu.Unlock()
+			}
+		}(number, session)
+	}
+	wg.Wait()
+
+	return ids
+}
+
+// checkUniqueIds vérifie que des manifestations créées sur différents serveurs ont toutes reçu un id différent
+func checkUniqueIds(t *testing.T, ids []int, expected int, description string) {
+	seen := make(map[int]bool, len(ids))
+	for _, id := range ids {
+		if seen[id] {
+			t.Error(utils.RED + "FAIL: " + utils.RESET + description + "\nId " + strconv.Itoa(id) + " was given to several events")
+			return
+		}
+		seen[id] = true
+	}
+	if len(ids) != expected {
+		t.Error(utils.RED + "FAIL: " + utils.RESET + description + "\nExpected " + strconv.Itoa(expected) + " events, created " + strconv.Itoa(len(ids)))
+		return
+	}
+	fmt.Println(utils.GREEN + "PASS: " + utils.RESET + description)
+}
+
+// waitConverged attend que les manifestations lues avec l'option "--strong" sur tous les serveurs soient les mêmes, au
+// nombre donné
+func waitConverged(t *testing.T, sessions map[int]*clusterSession, nbEvents int, description string) {
+	var last string
+	for deadline := time.Now().Add(10 * time.Second); time.Now().Before(deadline); time.Sleep(50 * time.Millisecond) {
+		converged := true
+		last = ""
+		for _, number := range utils.MapKeysToArray(sessions) {
+			response := sessions[number].send(t, utils.SHOW.Name+" "+utils.STRONG_READ)
+			if strings.Count(response, " / Creator: ") != nbEvents || (last != "" && response != last) {
+				converged = false
+			}
+			last = response
+		}
+		if converged {
+			fmt.Println(utils.GREEN + "PASS: " + utils.RESET + description)
+			return
+		}
+	}
+	t.Error(utils.RED + "FAIL: " + utils.RESET + description + "\nLast events: " + last)
+}
+
+// waitPeer attend que le serveur de la session considère un autre serveur dans l'état donné selon la commande "peers"
+func (cs *clusterSession) waitPeer(t *testing.T, number int, state types.PeerState, description string) {
+	var last string
+	for deadline := time.Now().Add(10 * time.Second); time.Now().Before(deadline); time.Sleep(50 * time.Millisecond) {
+		last = cs.send(t, utils.PEERS.Name)
+		for _, line := range strings.Split(last, "\n") {
+			if strings.HasPrefix(strings.TrimPrefix(line, utils.RESET), "S"+strconv.Itoa(number)+"\t") && strings.Contains(line, string(state)) {
+				fmt.Println(utils.GREEN + "PASS: " + utils.RESET + description)
+				return
+			}
+		}
+	}
+	t.Error(utils.RED + "FAIL: " + utils.RESET + description + "\nLast peers: " + last)
+}
+
+// waitLeader attend que le serveur de la session considère le serveur donné comme leader selon la commande "leader"
+func (cs *clusterSession) waitLeader(t *testing.T, leader int, description string) {
+	var last string
+	for deadline := time.Now().Add(10 * time.Second); time.Now().Before(deadline); time.Sleep(50 * time.Millisecond) {
+		last = cs.send(t, utils.LEADER.Name)
+		if line := "Leader: S" + strconv.Itoa(leader); strings.Contains(last, line+"\n") || strings.Contains(last, line+" ") {
+			fmt.Println(utils.GREEN + "PASS: " + utils.RESET + description)
+			return
+		}
+	}
+	t.Error(utils.RED + "FAIL: " + utils.RESET + description + "\nLast leader: " + last)
+}
+
+// waitEvents attend que la lecture locale du serveur de la session contienne le nombre de manifestations donné
+func (cs *clusterSession) waitEvents(t *testing.T, nbEvents int, description string) {
+	var last string
+	for deadline := time.Now().Add(10 * time.Second); time.Now().Before(deadline); time.Sleep(50 * time.Millisecond) {
+		if last = cs.send(t, utils.SHOW.Name); strings.Count(last, " / Creator: ") == nbEvents {
+			fmt.Println(utils.GREEN + "PASS: " + utils.RESET + description)
+			return
+		}
+	}
+	t.Error(utils.RED + "FAIL: " + utils.RESET + description + "\nLast events: " + last)
+}
+
+// jsonSession est une connexion de client en mode JSON à un serveur d'un cluster de test
+type jsonSession struct {
+	conn   net.Conn
+	reader *bufio.Reader
+	number int // Numéro du serveur
+}
+
+// request envoie une requête et décode la réponse du serveur
+func (js *jsonSession) request(request types.JSONRequest) (types.Response, error) {
+	var response types.Response
+	content, err := json.Marshal(request)
+	if err != nil {
+		return response, err
+	}
+	if _, err := js.conn.Write(append(content, '\n')); err != nil {
+		return response, err
+	}
+
+	// Les notifications des manifestations suivies sont ignorées
+	for {
+		line, err := js.reader.ReadString('\n')
+		if err != nil {
+			return response, err
+		}
+		if !strings.Contains(line, `"notification"`) {
+			return response, json.Unmarshal([]byte(line), &response)
+		}
+	}
+}
+
+// send envoie une requête et retourne la réponse du serveur, le test échouant si la réponse ne peut pas être lue
+func (js *jsonSession) send(t *testing.T, request types.JSONRequest) types.Response {
+	response, err := js.request(request)
+	if err != nil {
+		t.Fatal(utils.RED + "FAIL: " + utils.RESET + "Error: could not read the response of server #" + strconv.Itoa(js.number) + ": " + err.Error())
+	}
+	return response
+}
+
+// waitUntil renvoie une requête jusqu'à ce que sa réponse vérifie la condition donnée, pendant au plus dix secondes
+func (js *jsonSession) waitUntil(t *testing.T, request types.JSONRequest, condition func(types.Response) bool, description string) types.Response {
+	var response types.Response
+	for deadline := time.Now().Add(10 * time.Second); time.Now().Before(deadline); time.Sleep(50 * time.Millisecond) {
+		if response = js.send(t, request); condition(response) {
+			fmt.Println(utils.GREEN + "PASS: " + utils.RESET + description)
+			return response
+		}
+	}
+	content, _ := json.Marshal(response)
+	t.Error(utils.RED + "FAIL: " + utils.RESET + description + "\nLast response: " + string(content))
+	return response
+}
+
+// waitLeader attend que le serveur connaisse le leader donné
+func (js *jsonSession) waitLeader(t *testing.T, leader int, description string) {
+	js.waitUntil(t, types.JSONRequest{Command: utils.LEADER.Name}, func(response types.Response) bool {
+		return response.Leader != nil && response.Leader.Leader == leader
+	}, description)
+}
+
+// create crée une manifestation avec un job et retourne son id, 0 si la création a échoué
+func (js *jsonSession) create(name string) (int, error) {
+	response, err := js.request(types.JSONRequest{Command: utils.CREATE.Name, Args: []string{name, "Bar", "1"}, Username: "lazar", Password: "root"})
+	if err != nil {
+		return 0, err
+	}
+	if !response.Ok || response.Event == nil {
+		return 0, errors.New("create " + name + " failed on server #" + strconv.Itoa(js.number) + ": " + response.Message)
+	}
+	return response.Event.Id, nil
+}
+
+// check affiche le résultat d'une vérification d'un test
+func check(t *testing.T, ok bool, description string) {
+	if ok {
+		fmt.Println(utils.GREEN + "PASS: " + utils.RESET + description)
+	} else {
+		t.Error(utils.RED + "FAIL: " + utils.RESET + description)
+	}
+}
+
+func TestClusterReplication(t *testing.T) {
+	sessions := make([]*clusterSession, clusterSize+1)
+	for i := 1; i <= clusterSize; i++ {
+		sessions[i] = connect(t, i)
+		defer sessions[i].conn.Close()
+	}
+
+	// Attend que tous les serveurs soient connectés entre eux
+	for i := 1; i <= clusterSize; i++ {
+		sessions[i].waitFor(t, "leader", "Leader: S"+strconv.Itoa(clusterSize), "Server #"+strconv.Itoa(i)+" knows the leader of the cluster")
+	}
+
+	created := sessions[1].send(t, "create Cluster Montage 2 lazar root")
+	if created != utils.MESSAGE.WrapSuccess("Event #4 Cluster and 1 job(s) created\n") {
+		t.Fatal(utils.RED + "FAIL: " + utils.RESET + "Create on server #1 failed: " + created)
+	}
+	for i := 2; i <= clusterSize; i++ {
+		sessions[i].waitFor(t, "show 4", "Cluster", "Event created on server #1 is replicated on server #"+strconv.Itoa(i))
+	}
+
+	registered := sessions[2].send(t, "register 4 1 john root")
+	if !strings.Contains(registered, "SUCCESS") {
+		t.Fatal(utils.RED + "FAIL: " + utils.RESET + "Register on server #2 failed: " + registered)
+	}
+	for i := 1; i <= clusterSize; i++ {
+		sessions[i].waitFor(t, "jobs 4", "john", "Registration on server #2 is replicated on server #"+strconv.Itoa(i))
+	}
+}
+
+func TestClusterHeldDelivery(t *testing.T) {
+	first := connect(t, 1)
+	defer first.conn.Close()
+	third := connect(t, 3)
+	defer third.conn.Close()
+
+	for _, session := range []*clusterSession{first, third} {
+		session.waitFor(t, "leader", "Leader: S"+strconv.Itoa(clusterSize), "Server knows the leader of the cluster")
+	}
+
+	// La requête du serveur #1 n'atteint pas le serveur #3, la section critique ne peut pas être accordée
+	clusterNetwork.Hold(clusterPeer(1), clusterPeer(3))
+	done := make(chan string, 1)
+	go func() {
+		response, _ := first.request("create Held Montage 1 lazar root")
+		done <- response
+	}()
+
+	select {
+	case response := <-done:
+		t.Fatal(utils.RED + "FAIL: " + utils.RESET + "Create should wait for the held request, received " + response)
+	case <-time.After(300 * time.Millisecond):
+		fmt.Println(utils.GREEN + "PASS: " + utils.RESET + "Create waits while its request to server #3 is held")
+	}
+
+	clusterNetwork.Release(clusterPeer(1), clusterPeer(3))
+	select {
+	case response := <-done:
+		if !strings.Contains(response, "Held and 1 job(s) created") {
+			t.Fatal(utils.RED + "FAIL: " + utils.RESET + "Create failed after release: " + response)
+		}
+		fmt.Println(utils.GREEN + "PASS: " + utils.RESET + "Create completes once the held request is delivered")
+	case <-time.After(5 * time.Second):
+		t.Fatal(utils.RED + "FAIL: " + utils.RESET + "Create did not complete after release")
+	}
+
+	third.waitFor(t, "show", "Held", "Event created on server #1 is replicated on server #3 after release")
+}
+
+func TestClusterSimultaneousStart(t *testing.T) {
+	// Chaque serveur se connecte aux autres après qu'ils ont tous commencé à écouter, les serveurs se contactant donc
+	// mutuellement
+	cluster := newTestCluster(9100, 3, nil)
+	cluster.wrap = func(transport server.Transport) server.Transport {
+		return delayedTransport{Transport: transport, delay: 100 * time.Millisecond}
+	}
+	cluster.startAll()
+
+	sessions := cluster.connectAll(t)
+	for i := 1; i <= 3; i++ {
+		sessions[i].waitFor(t, "leader", "Leader: S3", "Server #"+strconv.Itoa(i)+" knows the leader of the cluster")
+	}
+
+	if created := sessions[2].send(t, "create Simultaneous Bar 1 lazar root"); !strings.Contains(created, "Event #4 Simultaneous") {
+		t.Fatal(utils.RED + "FAIL: " + utils.RESET + "Create on server #2 failed: " + created)
+	}
+	for _, i := range []int{1, 3} {
+		sessions[i].waitFor(t, "show 4", "Simultaneous", "Event created on server #2 is replicated on server #"+strconv.Itoa(i))
+	}
+}
+
+func TestClusterLocalReadDuringHeldWrite(t *testing.T) {
+	cluster := newTestCluster(10800, 3, tolerantFailureDetector)
+	cluster.startAll()
+	writer := cluster.connect(t, 1)
+	defer writer.conn.Close()
+	reader := cluster.connect(t, 1)
+	defer reader.conn.Close()
+
+	// La requête du serveur #1 n'atteint pas le serveur #3, la création attend la section critique distribuée
+	cluster.network.Hold(cluster.peer(1), cluster.peer(3))
+	created := make(chan error, 1)
+	go func() {
+		_, err := writer.create("Held")
+		created <- err
+	}()
+	time.Sleep(200 * time.Millisecond)
+
+	read := make(chan string, 1)
+	go func() {
+		response, _ := reader.request(utils.SHOW.Name)
+		read <- response
+	}()
+	select {
+	case response := <-read:
+		if strings.Count(response, " / Creator: ") != 3 {
+			t.Error(utils.RED + "FAIL: " + utils.RESET + "Local read on server #1 completes without the held event")
+		} else {
+			fmt.Println(utils.GREEN + "PASS: " + utils.RESET + "Local read on server #1 completes without the held event")
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal(utils.RED + "FAIL: " + utils.RESET + "Local read on server #1 waits for the write held in the critical section")
+	}
+
+	select {
+	case err := <-created:
+		t.Fatal(utils.RED + "FAIL: " + utils.RESET + "Create should wait for the held request, received " + fmt.Sprint(err))
+	default:
+		fmt.Println(utils.GREEN + "PASS: " + utils.RESET + "Create still waits while its request to server #3 is held")
+	}
+
+	cluster.network.Release(cluster.peer(1), cluster.peer(3))
+	select {
+	case err := <-created:
+		if err != nil {
+			t.Error(utils.RED + "FAIL: " + utils.RESET + "Create completes once the held request is delivered")
+		} else {
+			fmt.Println(utils.GREEN + "PASS: " + utils.RESET + "Create completes once the held request is delivered")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal(utils.RED + "FAIL: " + utils.RESET + "Create did not complete after release")
+	}
+	reader.waitEvents(t, 4, "Local read on server #1 sees the event once created")
+}
@@ -0,0 +1,117 @@
+// Auteurs: Jonathan Friedli, Lazar Pavicevic
+// Labo 2 SDR
+
+package test
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/Lazzzer/labo1-sdr/internal/utils"
+	"github.com/Lazzzer/labo1-sdr/internal/utils/types"
+)
+
+// ConfigTest est un fichier de configuration ou des entités et l'erreur attendue à son chargement, vide s'il est valide
+type ConfigTest struct {
+	Description string
+	Content     string
+	Expected    string
+}
+
+// checkError vérifie que l'erreur obtenue contient le message attendu, ou qu'il n'y a aucune erreur si aucun message
+// n'est attendu
+func checkError(t *testing.T, test ConfigTest, err error) {
+	switch {
+	case test.Expected == "" && err != nil:
+		t.Error(utils.RED + "FAIL: " + utils.RESET + test.Description + "\nUnexpected error: " + err.Error())
+	case test.Expected != "" && (err == nil || !strings.Contains(err.Error(), test.Expected)):
+		t.Error(utils.RED + "FAIL: " + utils.RESET + test.Description + "\nExpected error containing: " + test.Expected + "\nReceived: " + fmt.Sprint(err))
+	default:
+		fmt.Println(utils.GREEN + "PASS: " + utils.RESET + test.Description)
+	}
+}
+
+func TestLoadServerConfig(t *testing.T) {
+	tests := []ConfigTest{
+		{Description: "Valid config is loaded", Content: `{"servers": {"1": "localhost:8001"}, "client_ports": {"1": "8081"}, "mutex": "maekawa"}`},
+		{Description: "Invalid JSON reports its line", Content: "{\n\"servers\": {\"1\": \"localhost:8001\"},,\n}", Expected: "line 2"},
+		{Description: "Field with a wrong type is reported", Content: `{"servers": {"1": "localhost:8001"}, "client_ports": {"1": 8081}}`, Expected: `field "client_ports.1" must be string`},
+		{Description: "Config without servers is rejected", Content: `{"client_ports": {}}`, Expected: "servers: at least one server is required"},
+		{Description: "Invalid server address is rejected", Content: `{"servers": {"1": "localhost"}, "client_ports": {"1": "8081"}}`, Expected: `servers.1: invalid address "localhost"`},
+		{Description: "Server without client port is rejected", Content: `{"servers": {"1": "localhost:8001", "2": "localhost:8002"}, "client_ports": {"1": "8081"}}`, Expected: "client_ports: missing port for server 2"},
+		{Description: "Data directory of an unknown server is rejected", Content: `{"servers": {"1": "localhost:8001"}, "client_ports": {"1": "8081"}, "data_dirs": {"4": "data"}}`, Expected: "data_dirs.4: unknown server 4"},
+		{Description: "Unknown mutex algorithm is rejected", Content: `{"servers": {"1": "localhost:8001"}, "client_ports": {"1": "8081"}, "mutex": "peterson"}`, Expected: `mutex: unknown algorithm "peterson"`},
+		{Description: "Valid Raymond tree is loaded", Content: `{"servers": {"1": "localhost:8001", "2": "localhost:8002", "3": "localhost:8003"}, "client_ports": {"1": "8081", "2": "8082", "3": "8083"}, "mutex": "raymond", "tree": {"1": 2, "2": 0, "3": 2}}`},
+		{Description: "Raymond tree with two roots is rejected", Content: `{"servers": {"1": "localhost:8001", "2": "localhost:8002"}, "client_ports": {"1": "8081", "2": "8082"}, "mutex": "raymond", "tree": {"1": 0, "2": 0}}`, Expected: "tree: expected exactly one root, found 2"},
+		{Description: "Raymond tree with an unknown parent is rejected", Content: `{"servers": {"1": "localhost:8001", "2": "localhost:8002"}, "client_ports": {"1": "8081", "2": "8082"}, "mutex": "raymond", "tree": {"1": 0, "2": 5}}`, Expected: "tree.2: parent 5 is not a valid server"},
+		{Description: "Raymond tree with a cycle is rejected", Content: `{"servers": {"1": "localhost:8001", "2": "localhost:8002", "3": "localhost:8003"}, "client_ports": {"1": "8081", "2": "8082", "3": "8083"}, "mutex": "raymond", "tree": {"1": 0, "2": 3, "3": 2}}`, Expected: "tree: cycle detected from server 2"},
+		{Description: "Raymond tree missing a server is rejected", Content: `{"servers": {"1": "localhost:8001", "2": "localhost:8002"}, "client_ports": {"1": "8081", "2": "8082"}, "mutex": "raymond", "tree": {"1": 0}}`, Expected: "tree: missing parent for server 2"},
+		{Description: "Default binary tree without server 1 is rejected", Content: `{"servers": {"2": "localhost:8002", "3": "localhost:8003"}, "client_ports": {"2": "8082", "3": "8083"}, "mutex": "raymond"}`, Expected: "tree.2: parent 1 is not a valid server"},
+		{Description: "Negative interval is rejected", Content: `{"servers": {"1": "localhost:8001"}, "client_ports": {"1": "8081"}, "suspicion_timeout": -5}`, Expected: "suspicion_timeout: must not be negative"},
+	}
+
+	dir := t.TempDir()
+	for i, test := range tests {
+		path := filepath.Join(dir, fmt.Sprintf("config-%d.json", i))
+		if err := os.WriteFile(path, []byte(test.Content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		_, err := utils.LoadConfig[types.ServerConfig](path)
+		if err != nil && !strings.Contains(err.Error(), path) {
+			t.Error(utils.RED + "FAIL: " + utils.RESET + test.Description + "\nError should name the file: " + err.Error())
+		}
+		checkError(t, test, err)
+	}
+
+	_, err := utils.LoadConfig[types.Config](filepath.Join(dir, "missing.json"))
+	checkError(t, ConfigTest{Description: "Missing config file is reported", Expected: "could not read config file"}, err)
+
+	_, err = utils.LoadConfig[types.ServerConfig]("../cmd/server/config.json")
+	checkError(t, ConfigTest{Description: "Default server config file is valid"}, err)
+	_, err = utils.LoadConfig[types.Config]("../cmd/client/config.json")
+	checkError(t, ConfigTest{Description: "Default client config file is valid"}, err)
+}
+
+func TestLoadEntities(t *testing.T) {
+	users := `"users": {"1": {"username": "lazar", "password": "root"}, "2": {"username": "john", "password": "root"}}`
+	tests := []ConfigTest{
+		{Description: "Valid entities are loaded", Content: `{` + users + `, "events": {"1": {"name": "Festival", "creator_id": 1, "jobs": {"1": {"name": "Bar", "nb_volunteers": 1, "volunteer_ids": [2]}}}}}`},
+		{Description: "Non consecutive event ids are rejected", Content: `{` + users + `, "events": {"2": {"name": "Festival", "creator_id": 1, "jobs": {}}}}`, Expected: "events.2: event ids must be consecutive from 1"},
+		{Description: "Unknown creator is rejected", Content: `{` + users + `, "events": {"1": {"name": "Festival", "creator_id": 9, "jobs": {}}}}`, Expected: "events.1: unknown creator 9"},
+		{Description: "Unknown volunteer is rejected", Content: `{` + users + `, "events": {"1": {"name": "Festival", "creator_id": 1, "jobs": {"1": {"name": "Bar", "nb_volunteers": 2, "volunteer_ids": [7]}}}}}`, Expected: "events.1.jobs.1: unknown volunteer 7"},
+		{Description: "Job with too many volunteers is rejected", Content: `{` + users + `, "events": {"1": {"name": "Festival", "creator_id": 1, "jobs": {"1": {"name": "Bar", "nb_volunteers": 0, "volunteer_ids": [2]}}}}}`, Expected: "more volunteers than nb_volunteers"},
+	}
+
+	for _, test := range tests {
+		_, err := utils.ParseEntities(test.Content)
+		checkError(t, test, err)
+	}
+
+	_, err := utils.LoadEntities("../internal/server/entities.json")
+	checkError(t, ConfigTest{Description: "Default entities file is valid"}, err)
+}
+
+func TestResolvePath(t *testing.T) {
+	t.Setenv("SDR_TEST_CONFIG", "from-env.json")
+
+	tests := []struct {
+		Description string
+		Flag        string
+		Env         string
+		Expected    string
+	}{
+		{Description: "Flag takes precedence over the environment variable", Flag: "from-flag.json", Env: "SDR_TEST_CONFIG", Expected: "from-flag.json"},
+		{Description: "Environment variable is used without flag", Env: "SDR_TEST_CONFIG", Expected: "from-env.json"},
+		{Description: "Default path is used without flag nor environment variable", Env: "SDR_TEST_UNSET", Expected: "default.json"},
+	}
+	for _, test := range tests {
+		if path := utils.ResolvePath(test.Flag, test.Env, "default.json"); path != test.Expected {
+			t.Error(utils.RED + "FAIL: " + utils.RESET + test.Description + "\nExpected " + test.Expected + " but received " + path)
+		} else {
+			fmt.Println(utils.GREEN + "PASS: " + utils.RESET + test.Description)
+		}
+	}
+}
@@ -0,0 +1,42 @@
+// Auteurs: Jonathan Friedli, Lazar Pavicevic
+// Labo 2 SDR
+
+package test
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/Lazzzer/labo1-sdr/internal/utils/types"
+)
+
+// testElectionFailover lance un cluster de quatre serveurs utilisant l'algorithme d'élection donné et vérifie que le
+// serveur vivant ayant le plus grand numéro est élu après l'arrêt des leaders successifs
+func testElectionFailover(t *testing.T, base int, election types.ElectionType) {
+	cluster := newTestCluster(base, 4, func(config *types.ServerConfig) {
+		tolerantFailureDetector(config)
+		config.Election = election
+	})
+	cluster.startAll()
+	sessions := cluster.connectAll(t)
+	for _, session := range sessions {
+		session.waitLeader(t, 4, "Server #"+strconv.Itoa(session.number)+" elects server #4 with "+string(election))
+	}
+
+	for leader := 4; leader > 2; leader-- {
+		cluster.kill(leader)
+		delete(sessions, leader)
+		for _, session := range sessions {
+			session.waitLeader(t, leader-1, "Server #"+strconv.Itoa(session.number)+" elects server #"+strconv.Itoa(leader-1)+" after the crash of the leader with "+string(election))
+		}
+	}
+}
+
+func TestBullyFailover(t *testing.T) {
+	testElectionFailover(t, 10300, types.Bully)
+}
+
+// Avec Chang-Roberts, le message d'élection fait le tour de l'anneau en ignorant les serveurs arrêtés
+func TestChangRobertsFailover(t *testing.T) {
+	testElectionFailover(t, 10400, types.ChangRoberts)
+}
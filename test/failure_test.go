@@ -0,0 +1,182 @@
+// Auteurs: Jonathan Friedli, Lazar Pavicevic
+// Labo 2 SDR
+
+package test
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Lazzzer/labo1-sdr/internal/utils"
+	"github.com/Lazzzer/labo1-sdr/internal/utils/types"
+)
+
+// fastFailureDetector configure un détecteur de défaillances rapide pour les clusters de test
+func fastFailureDetector(config *types.ServerConfig) {
+	config.HeartbeatInterval = 100
+	config.SuspicionTimeout = 500
+}
+
+// tolerantFailureDetector configure un délai de suspicion long pour les clusters de test qui ne doivent pas suspecter
+// un serveur ralenti par les autres tests, un serveur arrêté étant tout de même détecté par la fermeture de ses
+// connexions
+func tolerantFailureDetector(config *types.ServerConfig) {
+	config.SuspicionTimeout = 10000
+}
+
+func TestClusterPartitionHeals(t *testing.T) {
+	cluster := newTestCluster(9200, 3, fastFailureDetector)
+	cluster.startAll()
+	sessions := cluster.connectAll(t)
+	for _, session := range sessions {
+		session.waitLeader(t, 3, "Server knows the leader of the cluster")
+	}
+
+	// Les communications entre les serveurs #1 et #3 sont retenues dans les deux sens pendant plusieurs délais de
+	// suspicion, le serveur #2 restant connecté aux deux
+	cluster.network.Hold(cluster.peer(1), cluster.peer(3))
+	cluster.network.Hold(cluster.peer(3), cluster.peer(1))
+	sessions[1].waitPeer(t, 3, types.PeerDown, "Server #1 considers server #3 down during the partition")
+	sessions[3].waitPeer(t, 1, types.PeerDown, "Server #3 considers server #1 down during the partition")
+	sessions[2].waitPeer(t, 1, types.PeerAlive, "Server #2 still considers server #1 alive")
+	sessions[2].waitPeer(t, 3, types.PeerAlive, "Server #2 still considers server #3 alive")
+	time.Sleep(2 * time.Second)
+
+	cluster.network.Release(cluster.peer(1), cluster.peer(3))
+	cluster.network.Release(cluster.peer(3), cluster.peer(1))
+	sessions[1].waitPeer(t, 3, types.PeerAlive, "Server #1 readmits server #3 after the partition")
+	sessions[3].waitPeer(t, 1, types.PeerAlive, "Server #3 readmits server #1 after the partition")
+
+	creators := map[int]*clusterSession{1: sessions[1], 3: sessions[3]}
+	ids := createConcurrently(t, creators, 10)
+	checkUniqueIds(t, ids, 20, "Events created concurrently on servers #1 and #3 after the partition get unique ids")
+	waitConverged(t, sessions, 23, "Strong reads of all servers converge after the partition")
+}
+
+func TestClusterDetectsCrash(t *testing.T) {
+	cluster := newTestCluster(10000, 3, tolerantFailureDetector)
+	cluster.startAll()
+	sessions := cluster.connectAll(t)
+
+	cluster.kill(3)
+	delete(sessions, 3)
+	sessions[1].waitPeer(t, 3, types.PeerDown, "Server #1 considers the crashed server #3 down")
+	sessions[2].waitPeer(t, 3, types.PeerDown, "Server #2 considers the crashed server #3 down")
+	sessions[1].waitPeer(t, 2, types.PeerAlive, "Server #1 still considers server #2 alive")
+
+	ids := createConcurrently(t, sessions, 5)
+	checkUniqueIds(t, ids, 10, "Events created concurrently on the remaining servers get unique ids")
+	waitConverged(t, sessions, 13, "Strong reads of the remaining servers converge")
+}
+
+func TestClusterRejoinTransfersState(t *testing.T) {
+	cluster := newTestCluster(10100, 3, tolerantFailureDetector)
+	cluster.startAll()
+	sessions := cluster.connectAll(t)
+
+	cluster.kill(2)
+	sessions[1].waitPeer(t, 2, types.PeerDown, "Server #1 considers the crashed server #2 down")
+	sessions[3].waitPeer(t, 2, types.PeerDown, "Server #3 considers the crashed server #2 down")
+	delete(sessions, 2)
+	ids := createConcurrently(t, sessions, 5)
+	checkUniqueIds(t, ids, 10, "Events created while server #2 is down get unique ids")
+
+	// La nouvelle instance du serveur #2 démarre avec les entités par défaut et reçoit l'état des autres serveurs
+	cluster.start(2)
+	sessions[2] = cluster.connect(t, 2)
+	t.Cleanup(func() { sessions[2].conn.Close() })
+	sessions[2].waitEvents(t, 13, "Rejoining server #2 receives the events created during its absence")
+	sessions[1].waitPeer(t, 2, types.PeerAlive, "Server #1 readmits server #2")
+
+	ids = createConcurrently(t, sessions, 3)
+	checkUniqueIds(t, ids, 9, "Events created concurrently after the rejoin get unique ids")
+	waitConverged(t, sessions, 22, "Strong reads of all servers converge after the rejoin")
+}
+
+func TestSuzukiKasamiSurvivesTokenCreatorCrash(t *testing.T) {
+	cluster := newTestCluster(10700, 3, func(config *types.ServerConfig) {
+		tolerantFailureDetector(config)
+		config.Mutex = types.SuzukiKasami
+	})
+	cluster.startAll()
+	sessions := cluster.connectAll(t)
+
+	// Le jeton de la ressource de création quitte le serveur #1, qui crée les jetons, avant son crash
+	if _, err := sessions[2].create("Before"); err != nil {
+		t.Fatal(utils.RED + "FAIL: " + utils.RESET + err.Error())
+	}
+
+	cluster.kill(1)
+	delete(sessions, 1)
+	sessions[2].waitPeer(t, 1, types.PeerDown, "Server #2 considers the crashed server #1 down")
+	sessions[3].waitPeer(t, 1, types.PeerDown, "Server #3 considers the crashed server #1 down")
+
+	ids := createConcurrently(t, sessions, 3)
+	checkUniqueIds(t, ids, 6, "Events created after the crash of server #1 get unique ids")
+
+	// Les manifestations créées après le crash n'ont jamais eu de jeton, qui doit être créé par un serveur restant. Une
+	// lecture forte transmet d'abord à chaque serveur les manifestations créées par l'autre.
+	for i, id := range ids {
+		session := sessions[2+i%2]
+		for _, input := range []string{utils.SHOW.Name + " " + utils.STRONG_READ, "register " + strconv.Itoa(id) + " 1 john root"} {
+			done := make(chan string, 1)
+			go func() {
+				response, _ := session.request(input)
+				done <- response
+			}()
+
+			select {
+			case response := <-done:
+				if !strings.HasPrefix(input, "register") {
+					break
+				}
+				description := "Server #" + strconv.Itoa(session.number) + " registers to event #" + strconv.Itoa(id) + " created after the crash"
+				if !strings.Contains(response, "User registered") {
+					t.Error(utils.RED + "FAIL: " + utils.RESET + description + "\nReceived: " + response)
+				} else {
+					fmt.Println(utils.GREEN + "PASS: " + utils.RESET + description)
+				}
+			case <-time.After(10 * time.Second):
+				t.Fatal(utils.RED + "FAIL: " + utils.RESET + input + " on server #" + strconv.Itoa(session.number) + " waits for a token that no server created")
+			}
+		}
+	}
+	waitConverged(t, sessions, 10, "Strong reads of the remaining servers converge")
+}
+
+func TestClusterSyncsDroppedRelease(t *testing.T) {
+	cluster := newTestCluster(11300, 3, fastFailureDetector)
+	cluster.startAll()
+	sessions := cluster.connectAll(t)
+
+	// Le premier REL envoyé par le serveur #1 au serveur #3 est perdu
+	var dropped atomic.Bool
+	cluster.network.Drop(cluster.peer(1), cluster.peer(3), func(data []byte) bool {
+		return bytes.HasPrefix(data, []byte(`{"type":"`+string(types.Release)+`"`)) && dropped.CompareAndSwap(false, true)
+	})
+	id, err := sessions[1].create("Dropped")
+	if err != nil {
+		t.Fatal(utils.RED + "FAIL: " + utils.RESET + err.Error())
+	}
+	if !dropped.Load() {
+		t.Fatal(utils.RED + "FAIL: " + utils.RESET + "REL of the create on server #1 is dropped before reaching server #3")
+	}
+	fmt.Println(utils.GREEN + "PASS: " + utils.RESET + "REL of the create on server #1 is dropped before reaching server #3")
+	sessions[2].waitEvents(t, 4, "Server #2 receives the create from the REL of server #1")
+	if response := sessions[3].send(t, utils.SHOW.Name); strings.Count(response, " / Creator: ") != 3 {
+		t.Error(utils.RED + "FAIL: " + utils.RESET + "Server #3 misses the event whose REL was dropped\nReceived: " + response)
+	} else {
+		fmt.Println(utils.GREEN + "PASS: " + utils.RESET + "Server #3 misses the event whose REL was dropped")
+	}
+
+	// L'opération suivante a été effectuée sur la manifestation manquée, le serveur #3 la met en attente puis demande la
+	// version complète de la manifestation au serveur #1 (SYN) et applique sa réponse (SYR)
+	sessions[1].register(t, id, 1, "jonathan")
+	sessions[3].waitLocalRead(t, utils.JOBS.Name+" "+strconv.Itoa(id), "jonathan", "Server #3 recovers the dropped event and the following registration through a full sync")
+	waitConverged(t, sessions, 4, "Strong reads of all servers converge after the full sync")
+}
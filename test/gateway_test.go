@@ -0,0 +1,104 @@
+// Auteurs: Jonathan Friedli, Lazar Pavicevic
+// Labo 2 SDR
+
+package test
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Lazzzer/labo1-sdr/internal/utils"
+	"github.com/Lazzzer/labo1-sdr/internal/utils/types"
+)
+
+// TestRoute définit une requête HTTP envoyée à la passerelle et la réponse attendue
+type TestRoute struct {
+	Description string
+	Method      string
+	Path        string
+	Username    string // Nom d'utilisateur de l'authentification basic, aucune authentification si vide
+	Body        string
+	Status      int
+	Error       types.ErrorCode
+}
+
+// sendHTTP envoie une requête à la passerelle HTTP du serveur des tests de charge et décode sa réponse
+func sendHTTP(route TestRoute, password string) (int, types.Response, error) {
+	var response types.Response
+	req, err := http.NewRequest(route.Method, "http://localhost:"+stressHTTPPort+route.Path, strings.NewReader(route.Body))
+	if err != nil {
+		return 0, response, err
+	}
+	if route.Username != "" {
+		req.SetBasicAuth(route.Username, password)
+	}
+
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return 0, response, err
+	}
+	defer res.Body.Close()
+	if res.Header.Get("Content-Type") == "application/json" {
+		err = json.NewDecoder(res.Body).Decode(&response)
+	}
+	return res.StatusCode, response, err
+}
+
+func TestHTTPGateway(t *testing.T) {
+	// Attend que la passerelle soit prête à recevoir des requêtes
+	var err error
+	for i := 0; i < 200; i++ {
+		if _, _, err = sendHTTP(TestRoute{Method: http.MethodGet, Path: "/events"}, ""); err == nil {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if err != nil {
+		t.Fatal(utils.RED + "FAIL: " + utils.RESET + "Error: could not connect to the HTTP gateway")
+	}
+
+	create := TestRoute{Method: http.MethodPost, Path: "/events", Username: "lazar", Body: `{"name":"Gateway","jobs":[{"name":"Bar","nb_volunteers":1}]}`}
+	status, response, err := sendHTTP(create, "root")
+	if err != nil || status != http.StatusOK || response.Event == nil || response.Event.Name != "Gateway" || len(response.Event.Jobs) != 1 {
+		t.Fatal(utils.RED + "FAIL: " + utils.RESET + "POST /events should create the event")
+	}
+	fmt.Println(utils.GREEN + "PASS: " + utils.RESET + "POST /events creates the event")
+
+	event := "/events/" + strconv.Itoa(response.Event.Id)
+	job := event + "/jobs/" + strconv.Itoa(response.Event.Jobs[0].Id) + "/volunteers"
+
+	tests := []TestRoute{
+		{Description: "POST /events without credentials is unauthorized", Method: http.MethodPost, Path: "/events", Body: create.Body, Status: http.StatusUnauthorized, Error: types.AccessDenied},
+		{Description: "POST /events with an invalid body is a bad request", Method: http.MethodPost, Path: "/events", Username: "lazar", Body: `{"name":"Gateway"}`, Status: http.StatusBadRequest, Error: types.InvalidRequest},
+		{Description: "GET /events/<id> returns the event", Method: http.MethodGet, Path: event, Status: http.StatusOK},
+		{Description: "GET /events/<id> of an unknown event is not found", Method: http.MethodGet, Path: "/events/9999", Status: http.StatusNotFound, Error: types.EventNotFound},
+		{Description: "POST volunteers registers the user", Method: http.MethodPost, Path: job, Username: "john", Status: http.StatusOK},
+		{Description: "POST volunteers in a full job is a conflict", Method: http.MethodPost, Path: job, Username: "valentin", Status: http.StatusConflict, Error: types.JobFull},
+		{Description: "GET /events/<id>/jobs with a strong read returns the volunteers", Method: http.MethodGet, Path: event + "/jobs?strong=true", Status: http.StatusOK},
+		{Description: "POST close from another user is forbidden", Method: http.MethodPost, Path: event + "/close", Username: "john", Status: http.StatusForbidden, Error: types.NotCreator},
+		{Description: "POST close from the creator closes the event", Method: http.MethodPost, Path: event + "/close", Username: "lazar", Status: http.StatusOK},
+		{Description: "POST close of a closed event is a conflict", Method: http.MethodPost, Path: event + "/close", Username: "lazar", Status: http.StatusConflict, Error: types.AlreadyClosed},
+		{Description: "Unknown route is not found", Method: http.MethodDelete, Path: event, Status: http.StatusNotFound},
+	}
+
+	for _, test := range tests {
+		status, response, err := sendHTTP(test, "root")
+		if err != nil || status != test.Status || response.Error != test.Error {
+			t.Error(utils.RED + "FAIL: " + utils.RESET + test.Description + fmt.Sprintf(" (received status %d error %q)", status, response.Error))
+		} else {
+			fmt.Println(utils.GREEN + "PASS: " + utils.RESET + test.Description)
+		}
+	}
+
+	status, response, err = sendHTTP(TestRoute{Method: http.MethodPost, Path: "/events", Username: "lazar", Body: create.Body}, "wrong")
+	if err != nil || status != http.StatusUnauthorized || response.Error != types.AccessDenied {
+		t.Error(utils.RED + "FAIL: " + utils.RESET + "POST /events with a wrong password should be unauthorized")
+	} else {
+		fmt.Println(utils.GREEN + "PASS: " + utils.RESET + "POST /events with a wrong password is unauthorized")
+	}
+}
@@ -23,7 +23,7 @@ type TestClient struct {
 var testConfig = types.Config{Address: "localhost:8091", Servers: map[int]string{1: "localhost:8011"}}
 
 var testClient = TestClient{Name: "test-client", Config: testConfig}
-var testServer = server.Server{Number: 1, Port: "8011", ClientPort: "8091", Config: types.ServerConfig{Config: testConfig, Silent: true}}
+var testServer = server.NewServer(1, "8011", "8091", types.ServerConfig{Config: testConfig, Silent: true}, server.DefaultEntities())
 
 // TestInput définit un test pour un input du client
 type TestInput struct {
@@ -39,7 +39,16 @@ func init() {
 
 // Run est une méthode de TestClient qui peut accepter plusieurs tests à run
 func (tc *TestClient) Run(tests []TestInput, t *testing.T) {
-	conn, err := net.Dial("tcp", tc.Config.Address)
+	var conn net.Conn
+	var err error
+
+	// Attend que le serveur de test soit prêt à recevoir des connexions
+	for i := 0; i < 50; i++ {
+		if conn, err = net.Dial("tcp", tc.Config.Address); err == nil {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
 
 	if err != nil {
 		t.Error(utils.RED + "FAIL: " + utils.RESET + "Error: could not connect to server")
@@ -110,6 +119,16 @@ func TestShowCommand(t *testing.T) {
 			Input:       "show 1\n",
 			Expected:    showFirstEvent,
 		},
+		{
+			Description: "Send show command with strong read option and receive all events",
+			Input:       "show --strong\n",
+			Expected:    utils.MESSAGE.WrapEvent(showAll),
+		},
+		{
+			Description: "Send show command with id of first event and strong read option and receive that event",
+			Input:       "show 1 --strong\n",
+			Expected:    showFirstEvent,
+		},
 		{
 			Description: "Send show command with invalid nb of args and receive error message",
 			Input:       "show 1 1 1\n",
@@ -140,6 +159,11 @@ func TestJobsCommand(t *testing.T) {
 			Input:       "jobs 3\n",
 			Expected:    showJobsEmpty,
 		},
+		{
+			Description: "Send jobs command for event 2 with strong read option and receive jobs distribution with volunteers",
+			Input:       "jobs 2 --strong\n",
+			Expected:    showJobs,
+		},
 		{
 			Description: "Send jobs command with no args and receive error message",
 			Input:       "jobs\n",
@@ -159,6 +183,74 @@ func TestJobsCommand(t *testing.T) {
 	testClient.Run(tests, t)
 }
 
+func TestPeersCommand(t *testing.T) {
+	var peers = "S1\t" + utils.BOLD + "SELF" + utils.RESET + "\n"
+
+	tests := []TestInput{
+		{
+			Description: "Send peers command and receive the state of the servers",
+			Input:       "peers\n",
+			Expected:    utils.MESSAGE.WrapPeers(peers),
+		},
+		{
+			Description: "Send peers command with invalid nb of args and receive error message",
+			Input:       "peers 1\n",
+			Expected:    utils.MESSAGE.Error.InvalidNbArgs,
+		},
+		{
+			Description: "Send invalid peers command and receive error message",
+			Input:       "peerss\n",
+			Expected:    utils.MESSAGE.Error.InvalidCommand,
+		},
+	}
+	testClient.Run(tests, t)
+}
+
+func TestLeaderCommand(t *testing.T) {
+	var leader = "Leader: S1 " + utils.BOLD + "(SELF)" + utils.RESET + "\nElection: bully\n"
+
+	tests := []TestInput{
+		{
+			Description: "Send leader command and receive the elected leader",
+			Input:       "leader\n",
+			Expected:    utils.MESSAGE.WrapLeader(leader),
+		},
+		{
+			Description: "Send leader command with invalid nb of args and receive error message",
+			Input:       "leader 1\n",
+			Expected:    utils.MESSAGE.Error.InvalidNbArgs,
+		},
+	}
+	testClient.Run(tests, t)
+}
+
+func TestStatusCommand(t *testing.T) {
+	tests := []TestInput{
+		{
+			Description: "Send status command with invalid nb of args and receive error message",
+			Input:       "status 1\n",
+			Expected:    utils.MESSAGE.Error.InvalidNbArgs,
+		},
+		{
+			Description: "Send misspelled status command and receive error message",
+			Input:       "statuss\n",
+			Expected:    utils.MESSAGE.Error.InvalidCommand,
+		},
+	}
+	testClient.Run(tests, t)
+}
+
+func TestSnapshotCommand(t *testing.T) {
+	tests := []TestInput{
+		{
+			Description: "Send snapshot command with invalid nb of args and receive error message",
+			Input:       "snapshot now\n",
+			Expected:    utils.MESSAGE.Error.InvalidNbArgs,
+		},
+	}
+	testClient.Run(tests, t)
+}
+
 func TestCreateCommand(t *testing.T) {
 	tests := []TestInput{
 		{
@@ -186,6 +278,11 @@ func TestCreateCommand(t *testing.T) {
 			Input:       "create Test TestJob 1 lazar rooooot\n",
 			Expected:    utils.MESSAGE.Error.AccessDenied,
 		},
+		{
+			Description: "Send create command with a job named like the strong read option and receive confirmation message",
+			Input:       "create Test --strong 1 lazar root\n",
+			Expected:    utils.MESSAGE.WrapSuccess("Event #6 Test and 1 job(s) created\n"),
+		},
 		{
 			Description: "Send invalid create command and receive error message",
 			Input:       "createe\n",
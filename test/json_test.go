@@ -0,0 +1,96 @@
+// Auteurs: Jonathan Friedli, Lazar Pavicevic
+// Labo 2 SDR
+
+package test
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"testing"
+
+	"github.com/Lazzzer/labo1-sdr/internal/utils"
+	"github.com/Lazzzer/labo1-sdr/internal/utils/types"
+)
+
+// sendJSON envoie une requête en mode JSON et décode la réponse du serveur
+func (sc *stressClient) sendJSON(input string) (types.Response, error) {
+	var response types.Response
+	if _, err := sc.conn.Write([]byte(input + "\n")); err != nil {
+		return response, err
+	}
+	line, err := sc.reader.ReadString('\n')
+	if err != nil {
+		return response, err
+	}
+	err = json.Unmarshal([]byte(line), &response)
+	return response, err
+}
+
+func TestJSONMode(t *testing.T) {
+	client, err := dialStress("json-client " + utils.JSON_MODE)
+	if err != nil {
+		t.Fatal(utils.RED + "FAIL: " + utils.RESET + "Error: could not connect to server")
+	}
+	defer client.conn.Close()
+
+	response, err := client.sendJSON(`{"id":"a","command":"create","args":["Json","Job","1"],"username":"lazar","password":"root"}`)
+	if err != nil || !response.Ok || response.Id != "a" || response.Command != utils.CREATE.Name || response.Event == nil {
+		t.Fatal(utils.RED + "FAIL: " + utils.RESET + "Create command should return the created event with the request id")
+	}
+	event := *response.Event
+	if event.Name != "Json" || event.Creator != "lazar" || len(event.Jobs) != 1 || event.Jobs[0].Name != "Job" || event.Jobs[0].NbVolunteers != 1 {
+		t.Fatal(utils.RED + "FAIL: " + utils.RESET + "Created event does not match the request")
+	}
+	fmt.Println(utils.GREEN + "PASS: " + utils.RESET + "Create command returns the created event with the request id")
+
+	idEvent := strconv.Itoa(event.Id)
+	idJob := strconv.Itoa(event.Jobs[0].Id)
+
+	tests := []struct {
+		Description string
+		Input       string
+		Error       types.ErrorCode
+	}{
+		{Description: "Register command with credentials fields succeeds", Input: `{"command":"register","args":["` + idEvent + `","` + idJob + `"],"username":"john","password":"root"}`},
+		{Description: "Register command in a full job returns JOB_FULL", Input: `{"command":"register","args":["` + idEvent + `","` + idJob + `"],"username":"valentin","password":"root"}`, Error: types.JobFull},
+		{Description: "Close command from another user returns NOT_CREATOR", Input: `{"command":"close","args":["` + idEvent + `"],"username":"john","password":"root"}`, Error: types.NotCreator},
+		{Description: "Create command with bad credentials returns ACCESS_DENIED", Input: `{"command":"create","args":["Json","Job","1"],"username":"lazar","password":"wrong"}`, Error: types.AccessDenied},
+		{Description: "Unknown command returns INVALID_COMMAND", Input: `{"command":"helpp"}`, Error: types.InvalidCommand},
+		{Description: "Invalid JSON returns INVALID_REQUEST", Input: `create Json Job 1 lazar root`, Error: types.InvalidRequest},
+	}
+
+	for _, test := range tests {
+		response, err := client.sendJSON(test.Input)
+		if err != nil || response.Ok != (test.Error == "") || response.Error != test.Error {
+			t.Error(utils.RED + "FAIL: " + utils.RESET + test.Description + fmt.Sprintf(" (received ok=%t error=%q)", response.Ok, response.Error))
+		} else {
+			fmt.Println(utils.GREEN + "PASS: " + utils.RESET + test.Description)
+		}
+	}
+
+	response, err = client.sendJSON(`{"command":"jobs","args":["` + idEvent + `"]}`)
+	if err != nil || !response.Ok || response.Event == nil || len(response.Event.Jobs[0].Volunteers) != 1 || response.Event.Jobs[0].Volunteers[0] != "john" {
+		t.Error(utils.RED + "FAIL: " + utils.RESET + "Jobs command should list the registered volunteer")
+	} else {
+		fmt.Println(utils.GREEN + "PASS: " + utils.RESET + "Jobs command lists the registered volunteer")
+	}
+
+	response, err = client.sendJSON(`{"command":"help"}`)
+	if err != nil || !response.Ok || len(response.Commands) != len(utils.COMMANDS) {
+		t.Error(utils.RED + "FAIL: " + utils.RESET + "Help command should list every command")
+	} else {
+		fmt.Println(utils.GREEN + "PASS: " + utils.RESET + "Help command lists every command")
+	}
+
+	response, err = client.sendJSON(`{"command":"quit"}`)
+	if err != nil || !response.Ok || response.Command != utils.QUIT.Name {
+		t.Error(utils.RED + "FAIL: " + utils.RESET + "Quit command should be confirmed before closing the session")
+	} else {
+		fmt.Println(utils.GREEN + "PASS: " + utils.RESET + "Quit command is confirmed before closing the session")
+	}
+	if _, err := client.reader.ReadString('\n'); err != io.EOF {
+		t.Error(utils.RED + "FAIL: " + utils.RESET + "Connection should be closed after quit")
+	}
+}
@@ -0,0 +1,64 @@
+// Auteurs: Jonathan Friedli, Lazar Pavicevic
+// Labo 2 SDR
+
+package test
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Lazzzer/labo1-sdr/internal/utils"
+)
+
+// waitMembers attend que le serveur de la session connaisse exactement les autres serveurs donnés selon la commande
+// "peers"
+func (cs *clusterSession) waitMembers(t *testing.T, members []int, description string) {
+	var last string
+	for deadline := time.Now().Add(10 * time.Second); time.Now().Before(deadline); time.Sleep(50 * time.Millisecond) {
+		last = cs.send(t, utils.PEERS.Name)
+		var others []int
+		for _, line := range strings.Split(last, "\n") {
+			server, _, found := strings.Cut(strings.TrimPrefix(line, utils.RESET), "\t")
+			number, err := strconv.Atoi(strings.TrimPrefix(server, "S"))
+			if found && err == nil && !strings.Contains(line, "SELF") {
+				others = append(others, number)
+			}
+		}
+		if utils.IntToString(others) == utils.IntToString(members) {
+			fmt.Println(utils.GREEN + "PASS: " + utils.RESET + description)
+			return
+		}
+	}
+	t.Error(utils.RED + "FAIL: " + utils.RESET + description + "\nLast peers: " + last)
+}
+
+func TestClusterJoinAndLeave(t *testing.T) {
+	cluster := newTestCluster(10200, 3, tolerantFailureDetector)
+	cluster.startAll()
+	sessions := cluster.connectAll(t)
+
+	// Le serveur #4 ne figure pas dans la configuration des serveurs déjà lancés
+	cluster.config.Servers[4] = cluster.peer(4)
+	cluster.config.ClientPorts[4] = cluster.clientPort(4)
+	cluster.start(4)
+	sessions[4] = cluster.connect(t, 4)
+	t.Cleanup(func() { sessions[4].conn.Close() })
+
+	sessions[1].waitMembers(t, []int{2, 3, 4}, "Server #1 adds the new server #4 to the network")
+	sessions[3].waitMembers(t, []int{1, 2, 4}, "Server #3 adds the new server #4 to the network")
+	sessions[4].waitMembers(t, []int{1, 2, 3}, "New server #4 knows every member of the network")
+	ids := createConcurrently(t, sessions, 5)
+	checkUniqueIds(t, ids, 20, "Events created concurrently with the new server get unique ids")
+	waitConverged(t, sessions, 23, "Strong reads of all servers converge after the join")
+
+	cluster.servers[2].Leave()
+	delete(sessions, 2)
+	sessions[1].waitMembers(t, []int{3, 4}, "Server #1 removes server #2 after it left the network")
+	sessions[4].waitMembers(t, []int{1, 3}, "Server #4 removes server #2 after it left the network")
+	ids = createConcurrently(t, sessions, 5)
+	checkUniqueIds(t, ids, 15, "Events created concurrently after the leave get unique ids")
+	waitConverged(t, sessions, 38, "Strong reads of the remaining servers converge after the leave")
+}
@@ -0,0 +1,170 @@
+// Auteurs: Jonathan Friedli, Lazar Pavicevic
+// Labo 2 SDR
+
+package test
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Lazzzer/labo1-sdr/internal/utils"
+	"github.com/Lazzzer/labo1-sdr/internal/utils/types"
+)
+
+// nbConcurrentEvents est le nombre de manifestations créées en même temps sur chaque serveur par les tests des
+// algorithmes d'exclusion mutuelle
+const nbConcurrentEvents = 5
+
+// testMutexCluster lance un cluster utilisant l'algorithme d'exclusion mutuelle donné, crée des manifestations en même
+// temps sur tous ses serveurs et vérifie qu'elles reçoivent des ids différents et que les lectures fortes convergent
+func testMutexCluster(t *testing.T, base int, size int, mutex types.MutexType) {
+	cluster := newTestCluster(base, size, func(config *types.ServerConfig) {
+		tolerantFailureDetector(config)
+		config.Mutex = mutex
+	})
+	cluster.startAll()
+	sessions := cluster.connectAll(t)
+
+	ids := createConcurrently(t, sessions, nbConcurrentEvents)
+	checkUniqueIds(t, ids, size*nbConcurrentEvents, "Events created concurrently on every server get unique ids with "+string(mutex))
+	waitConverged(t, sessions, 3+size*nbConcurrentEvents, "Strong reads of all servers converge with "+string(mutex))
+}
+
+func TestRicartAgrawalaCluster(t *testing.T) {
+	testMutexCluster(t, 9500, 3, types.RicartAgrawala)
+}
+
+func TestSuzukiKasamiCluster(t *testing.T) {
+	testMutexCluster(t, 9600, 3, types.SuzukiKasami)
+}
+
+// Avec cinq serveurs, l'arbre binaire par défaut a deux niveaux sous sa racine
+func TestRaymondCluster(t *testing.T) {
+	testMutexCluster(t, 9700, 5, types.Raymond)
+}
+
+func TestMaekawaCluster(t *testing.T) {
+	testMutexCluster(t, 9800, 3, types.Maekawa)
+}
+
+// Avec neuf serveurs, la grille est complète et chaque quorum ne contient que cinq serveurs
+func TestMaekawaGridCluster(t *testing.T) {
+	testMutexCluster(t, 9900, 9, types.Maekawa)
+}
+
+// Avec seize serveurs, la grille de quatre lignes et quatre colonnes est complète et chaque quorum contient sept
+// serveurs
+func TestMaekawaLargeGridCluster(t *testing.T) {
+	testMutexCluster(t, 11400, 16, types.Maekawa)
+}
+
+func TestEventLocksAreIndependent(t *testing.T) {
+	cluster := newTestCluster(10900, 3, func(config *types.ServerConfig) {
+		tolerantFailureDetector(config)
+		config.Mutex = types.SuzukiKasami
+	})
+	cluster.startAll()
+	sessions := cluster.connectAll(t)
+	second := cluster.connect(t, 1)
+	t.Cleanup(func() { second.conn.Close() })
+
+	// Le jeton de la manifestation #3 est transmis au serveur #3, celui de la manifestation #2 reste sur le serveur #1
+	registered := sessions[3].send(t, "register 3 1 lazar root")
+	if !strings.Contains(registered, "User registered") {
+		t.Fatal(utils.RED + "FAIL: " + utils.RESET + "Server #3 could not register to event #3: " + registered)
+	}
+
+	// La requête du serveur #1 pour le jeton de la manifestation #3 n'atteint pas le serveur #3
+	cluster.network.Hold(cluster.peer(1), cluster.peer(3))
+	held := make(chan string, 1)
+	go func() {
+		response, _ := sessions[1].request("register 3 2 john root")
+		held <- response
+	}()
+	time.Sleep(200 * time.Millisecond)
+
+	other := make(chan string, 1)
+	go func() {
+		other <- second.send(t, "register 2 3 lazar root")
+	}()
+	select {
+	case response := <-other:
+		if !strings.Contains(response, "User registered") {
+			t.Error(utils.RED + "FAIL: " + utils.RESET + "Register to event #2 on server #1 completes while event #3 waits for its token\nReceived: " + response)
+		} else {
+			fmt.Println(utils.GREEN + "PASS: " + utils.RESET + "Register to event #2 on server #1 completes while event #3 waits for its token")
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal(utils.RED + "FAIL: " + utils.RESET + "Register to event #2 on server #1 waits for the critical section of event #3")
+	}
+
+	select {
+	case response := <-held:
+		t.Fatal(utils.RED + "FAIL: " + utils.RESET + "Register to event #3 should wait for the held request, received " + response)
+	default:
+		fmt.Println(utils.GREEN + "PASS: " + utils.RESET + "Register to event #3 on server #1 still waits for its token")
+	}
+
+	cluster.network.Release(cluster.peer(1), cluster.peer(3))
+	select {
+	case response := <-held:
+		if !strings.Contains(response, "User registered") {
+			t.Error(utils.RED + "FAIL: " + utils.RESET + "Register to event #3 on server #1 completes once its request is delivered\nReceived: " + response)
+		} else {
+			fmt.Println(utils.GREEN + "PASS: " + utils.RESET + "Register to event #3 on server #1 completes once its request is delivered")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal(utils.RED + "FAIL: " + utils.RESET + "Register to event #3 did not complete after release")
+	}
+}
+
+// testOperationReplication lance un cluster utilisant un algorithme diffusant le REL à tous les serveurs et vérifie
+// que les opérations effectuées sur des serveurs différents sont appliquées par les lectures locales de chaque serveur
+func testOperationReplication(t *testing.T, base int, mutex types.MutexType) {
+	cluster := newTestCluster(base, 3, func(config *types.ServerConfig) {
+		tolerantFailureDetector(config)
+		config.Mutex = mutex
+	})
+	cluster.startAll()
+	sessions := cluster.connectAll(t)
+
+	id, err := sessions[1].create("Operations")
+	if err != nil {
+		t.Fatal(utils.RED + "FAIL: " + utils.RESET + err.Error())
+	}
+	event := strconv.Itoa(id)
+	if response := sessions[2].send(t, "register "+event+" 1 john root"); !strings.Contains(response, "User registered") {
+		t.Fatal(utils.RED + "FAIL: " + utils.RESET + "Server #2 could not register to event #" + event + ": " + response)
+	}
+	if response := sessions[3].send(t, "close "+event+" lazar root"); !strings.Contains(response, "is closed") {
+		t.Fatal(utils.RED + "FAIL: " + utils.RESET + "Server #3 could not close event #" + event + ": " + response)
+	}
+
+	for _, session := range sessions {
+		session.waitLocalRead(t, utils.JOBS.Name+" "+event, "john", "Server #"+strconv.Itoa(session.number)+" applies the registration made on server #2 with "+string(mutex))
+		session.waitLocalRead(t, utils.SHOW.Name, "Closed"+utils.RESET+"\t#"+event+" ", "Server #"+strconv.Itoa(session.number)+" applies the closing made on server #3 with "+string(mutex))
+	}
+}
+
+// waitLocalRead attend que la lecture locale donnée du serveur de la session contienne le texte donné
+func (cs *clusterSession) waitLocalRead(t *testing.T, input string, expected string, description string) {
+	var last string
+	for deadline := time.Now().Add(10 * time.Second); time.Now().Before(deadline); time.Sleep(50 * time.Millisecond) {
+		if last = cs.send(t, input); strings.Contains(last, expected) {
+			fmt.Println(utils.GREEN + "PASS: " + utils.RESET + description)
+			return
+		}
+	}
+	t.Error(utils.RED + "FAIL: " + utils.RESET + description + "\nLast response: " + last)
+}
+
+func TestLamportReplicatesOperations(t *testing.T) {
+	testOperationReplication(t, 11600, types.Lamport)
+}
+
+func TestRicartAgrawalaReplicatesOperations(t *testing.T) {
+	testOperationReplication(t, 11700, types.RicartAgrawala)
+}
@@ -0,0 +1,106 @@
+// Auteurs: Jonathan Friedli, Lazar Pavicevic
+// Labo 2 SDR
+
+package test
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Lazzzer/labo1-sdr/internal/utils"
+	"github.com/Lazzzer/labo1-sdr/internal/utils/types"
+)
+
+// TestFrame définit une requête du protocole framed et la réponse attendue
+type TestFrame struct {
+	Description string
+	Input       string
+	Status      types.FrameStatus
+	Expected    string // Contenu attendu dans la réponse
+}
+
+func TestFrameEncoding(t *testing.T) {
+	var buf bytes.Buffer
+	sent := types.Frame{Id: 42, Status: types.StatusError, Body: "line 1\nline 2\n"}
+	if err := utils.WriteFrame(&buf, sent); err != nil {
+		t.Fatal(utils.RED + "FAIL: " + utils.RESET + err.Error())
+	}
+	if received, err := utils.ReadFrame(&buf); err != nil || received != sent {
+		t.Fatal(utils.RED + "FAIL: " + utils.RESET + "Frame was not decoded as it was encoded")
+	}
+	fmt.Println(utils.GREEN + "PASS: " + utils.RESET + "Frame is decoded as it was encoded")
+
+	if _, err := utils.ReadFrame(bytes.NewReader([]byte{0, 0, 0, 2, 0, 0})); err == nil {
+		t.Error(utils.RED + "FAIL: " + utils.RESET + "Frame with an invalid length should be rejected")
+	} else {
+		fmt.Println(utils.GREEN + "PASS: " + utils.RESET + "Frame with an invalid length is rejected")
+	}
+
+	if _, err := utils.ReadFrame(bytes.NewReader([]byte{0, 0, 0, 9, 0, 0, 0, 1, 0, 'a'})); err != io.ErrUnexpectedEOF {
+		t.Error(utils.RED + "FAIL: " + utils.RESET + "Truncated frame should be rejected")
+	} else {
+		fmt.Println(utils.GREEN + "PASS: " + utils.RESET + "Truncated frame is rejected")
+	}
+}
+
+func TestFramedProtocol(t *testing.T) {
+	var conn net.Conn
+	var err error
+
+	// Attend que le serveur soit prêt à recevoir des connexions
+	for i := 0; i < 200; i++ {
+		if conn, err = net.Dial("tcp", stressConfig.Address); err == nil {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if err != nil {
+		t.Fatal(utils.RED + "FAIL: " + utils.RESET + "Error: could not connect to server")
+	}
+	defer conn.Close()
+
+	if _, err := conn.Write([]byte("framed-client " + utils.FRAMED_PROTOCOL + "\n")); err != nil {
+		t.Fatal(utils.RED + "FAIL: " + utils.RESET + "Error: could not send name to server")
+	}
+	if ack, err := utils.ReadFrame(conn); err != nil || ack.Id != 0 || ack.Body != utils.FRAMED_PROTOCOL {
+		t.Fatal(utils.RED + "FAIL: " + utils.RESET + "Server did not confirm the framed protocol")
+	}
+	fmt.Println(utils.GREEN + "PASS: " + utils.RESET + "Server confirms the framed protocol")
+
+	tests := []TestFrame{
+		{Description: "Pipelined help command receives the help message", Input: "help", Status: types.StatusOK, Expected: utils.MESSAGE.Help},
+		{Description: "Pipelined invalid command receives an error", Input: "helpp", Status: types.StatusError, Expected: utils.MESSAGE.Error.InvalidCommand},
+		{Description: "Pipelined create command receives a confirmation", Input: "create Framed Job 1 lazar root", Status: types.StatusOK, Expected: "Framed and 1 job(s) created"},
+		{Description: "Pipelined create command with bad credentials receives an error", Input: "create Framed Job 1 lazar wrong", Status: types.StatusError, Expected: utils.MESSAGE.Error.AccessDenied},
+		{Description: "Pipelined show command sees the event created before", Input: "show", Status: types.StatusOK, Expected: "Framed"},
+		{Description: "Pipelined quit command closes the session", Input: "quit", Status: types.StatusClosed},
+	}
+
+	// Toutes les requêtes sont envoyées avant de lire la première réponse
+	for i, test := range tests {
+		if err := utils.WriteFrame(conn, types.Frame{Id: uint32(100 + i), Body: test.Input}); err != nil {
+			t.Fatal(utils.RED + "FAIL: " + utils.RESET + "Error: could not write to server")
+		}
+	}
+
+	for i, test := range tests {
+		frame, err := utils.ReadFrame(conn)
+		if err != nil {
+			t.Fatal(utils.RED + "FAIL: " + utils.RESET + "Error: could not read from connection")
+		}
+		if frame.Id != uint32(100+i) || frame.Status != test.Status || !strings.Contains(frame.Body, test.Expected) {
+			t.Error("\n" + utils.RED + "FAIL: " + utils.RESET + test.Description + utils.GREEN + "\n\nExpected\n" + utils.RESET + fmt.Sprintf("#%d (status %d) %s", 100+i, test.Status, test.Expected) + utils.RED + "\nReceived\n" + utils.RESET + fmt.Sprintf("#%d (status %d) %s", frame.Id, frame.Status, frame.Body))
+		} else {
+			fmt.Println(utils.GREEN + "PASS: " + utils.RESET + test.Description)
+		}
+	}
+
+	if _, err := utils.ReadFrame(conn); err != io.EOF {
+		t.Error(utils.RED + "FAIL: " + utils.RESET + "Connection should be closed after quit")
+	}
+}
@@ -0,0 +1,103 @@
+// Auteurs: Jonathan Friedli, Lazar Pavicevic
+// Labo 2 SDR
+
+package test
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Lazzzer/labo1-sdr/internal/utils"
+	"github.com/Lazzzer/labo1-sdr/internal/utils/types"
+)
+
+// raftConsistency configure un cluster de test en mode Raft avec un détecteur de défaillances rapide, qui fixe aussi
+// le délai d'élection de Raft
+func raftConsistency(config *types.ServerConfig) {
+	fastFailureDetector(config)
+	config.Consistency = types.RaftConsistency
+}
+
+// waitRaftLeader attend que le serveur connaisse un leader Raft différent de celui donné et retourne son numéro
+func (cs *clusterSession) waitRaftLeader(t *testing.T, previous int, description string) int {
+	var last string
+	for deadline := time.Now().Add(10 * time.Second); time.Now().Before(deadline); time.Sleep(50 * time.Millisecond) {
+		last = cs.send(t, utils.LEADER.Name)
+		_, after, found := strings.Cut(last, "Leader: S")
+		if !found {
+			continue
+		}
+		end := strings.IndexAny(after, " \n")
+		if end < 0 {
+			continue
+		}
+		if leader, err := strconv.Atoi(after[:end]); err == nil && leader != 0 && leader != previous {
+			fmt.Println(utils.GREEN + "PASS: " + utils.RESET + description)
+			return leader
+		}
+	}
+	t.Error(utils.RED + "FAIL: " + utils.RESET + description + "\nLast leader: " + last)
+	return 0
+}
+
+func TestRaftFailover(t *testing.T) {
+	cluster := newTestCluster(10500, 3, raftConsistency)
+	cluster.startAll()
+	sessions := cluster.connectAll(t)
+
+	ids := createConcurrently(t, sessions, 5)
+	checkUniqueIds(t, ids, 15, "Entries submitted concurrently to every server get unique ids")
+	waitConverged(t, sessions, 18, "Reads of all servers converge")
+
+	leader := sessions[1].waitRaftLeader(t, 0, "Server #1 knows the Raft leader")
+	if leader == 0 {
+		t.FailNow()
+	}
+	cluster.kill(leader)
+	delete(sessions, leader)
+	for _, session := range sessions {
+		session.waitRaftLeader(t, leader, "Server #"+strconv.Itoa(session.number)+" follows a new Raft leader after the crash of server #"+strconv.Itoa(leader))
+	}
+
+	ids = createConcurrently(t, sessions, 5)
+	checkUniqueIds(t, ids, 10, "Entries submitted after the failover get unique ids")
+	waitConverged(t, sessions, 28, "Reads of the remaining servers converge after the failover")
+}
+
+func TestRaftSubmitTimeout(t *testing.T) {
+	cluster := newTestCluster(11000, 3, raftConsistency)
+	cluster.startAll()
+	sessions := cluster.connectAll(t)
+
+	if _, err := sessions[1].create("Committed"); err != nil {
+		t.Fatal(utils.RED + "FAIL: " + utils.RESET + err.Error())
+	}
+
+	// Sans majorité joignable, aucune entrée ne peut être validée
+	cluster.kill(2)
+	cluster.kill(3)
+	done := make(chan string, 1)
+	go func() {
+		response, _ := sessions[1].request("create Lost Job 1 john root")
+		done <- response
+	}()
+
+	select {
+	case response := <-done:
+		if strings.Contains(response, "Command was not committed in time") {
+			fmt.Println(utils.GREEN + "PASS: " + utils.RESET + "Command without a reachable majority fails with a timeout")
+		} else {
+			t.Error(utils.RED + "FAIL: " + utils.RESET + "Command without a reachable majority fails with a timeout\nResponse: " + response)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal(utils.RED + "FAIL: " + utils.RESET + "Command without a reachable majority waits forever")
+	}
+	if response := sessions[1].send(t, utils.SHOW.Name); strings.Contains(response, "Committed") {
+		fmt.Println(utils.GREEN + "PASS: " + utils.RESET + "Server #1 still answers local reads after the timeout")
+	} else {
+		t.Error(utils.RED + "FAIL: " + utils.RESET + "Server #1 still answers local reads after the timeout\nResponse: " + response)
+	}
+}
@@ -0,0 +1,146 @@
+// Auteurs: Jonathan Friedli, Lazar Pavicevic
+// Labo 2 SDR
+
+package test
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/Lazzzer/labo1-sdr/internal/repository"
+	"github.com/Lazzzer/labo1-sdr/internal/server"
+	"github.com/Lazzzer/labo1-sdr/internal/utils"
+	"github.com/Lazzzer/labo1-sdr/internal/utils/types"
+)
+
+// newEvent retourne une manifestation de l'organisateur lazar avec deux jobs sans bénévoles
+func newEvent(name string) types.Event {
+	return types.Event{Name: name, CreatorId: 2, Jobs: map[int]types.Job{
+		1: {Name: "Bar", NbVolunteers: 2, VolunteerIds: []int{}},
+		2: {Name: "Cuisine", NbVolunteers: 1, VolunteerIds: []int{}},
+	}}
+}
+
+func TestRepositories(t *testing.T) {
+	kv, err := repository.OpenKV(filepath.Join(t.TempDir(), "entities.db"), server.DefaultEntities())
+	if err != nil {
+		t.Fatal(utils.RED + "FAIL: " + utils.RESET + err.Error())
+	}
+	defer kv.Close()
+
+	repos := map[string]repository.Repository{
+		"memory": repository.NewMemory(server.DefaultEntities()),
+		"kv":     kv,
+	}
+	for name, repo := range repos {
+		prefix := "(" + name + ") "
+
+		id, ok := repository.FindUser(repo, "lazar", "root")
+		check(t, ok && id == 2, prefix+"User is found with its credentials")
+		_, ok = repository.FindUser(repo, "lazar", "wrong")
+		check(t, !ok, prefix+"User is not found with a wrong password")
+		user, ok := repo.User(6)
+		check(t, ok && user.Username == "john", prefix+"User is found with its id")
+		check(t, repo.NbEvents() == 3, prefix+"Initial events are loaded")
+
+		check(t, repo.CreateEvent(4, newEvent("Festival")) == nil, prefix+"Event is created")
+		check(t, errors.Is(repo.CreateEvent(4, newEvent("Other")), repository.ErrEventExists), prefix+"Event with an existing id is not created")
+
+		before, _ := repo.Event(4)
+		check(t, repo.UpdateJob(4, 1, types.Job{Name: "Bar", NbVolunteers: 2, VolunteerIds: []int{6}}) == nil, prefix+"Job is updated")
+		after, _ := repo.Event(4)
+		check(t, len(after.Jobs[1].VolunteerIds) == 1 && after.Jobs[1].VolunteerIds[0] == 6, prefix+"Updated job has its new volunteers")
+		check(t, len(before.Jobs[1].VolunteerIds) == 0, prefix+"Previous version of the event is not modified by an update")
+		check(t, errors.Is(repo.UpdateJob(4, 9, types.Job{}), repository.ErrJobNotFound), prefix+"Unknown job is not updated")
+		check(t, errors.Is(repo.UpdateJob(99, 1, types.Job{}), repository.ErrEventNotFound), prefix+"Job of an unknown event is not updated")
+
+		check(t, repo.CloseEvent(4) == nil, prefix+"Event is closed")
+		closed, _ := repo.Event(4)
+		check(t, closed.Closed && closed.Name == "Festival", prefix+"Closed event keeps its content")
+		check(t, errors.Is(repo.CloseEvent(99), repository.ErrEventNotFound), prefix+"Unknown event is not closed")
+
+		check(t, repo.NbEvents() == 4 && len(repo.Events()) == 4, prefix+"All events are listed")
+	}
+}
+
+func TestKVRepositoryReopen(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "entities.db")
+
+	kv, err := repository.OpenKV(path, server.DefaultEntities())
+	if err != nil {
+		t.Fatal(utils.RED + "FAIL: " + utils.RESET + err.Error())
+	}
+	kv.CreateEvent(4, newEvent("Persisted"))
+	kv.UpdateJob(4, 2, types.Job{Name: "Cuisine", NbVolunteers: 1, VolunteerIds: []int{3}})
+	kv.Close()
+
+	// Simule un crash pendant l'écriture d'une valeur
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := file.WriteString(`{"key":"event/5","value":{"name":"Lost"`); err != nil {
+		t.Fatal(err)
+	}
+	file.Close()
+
+	// Les entités données sont ignorées, le fichier contenant déjà des entités
+	kv, err = repository.OpenKV(path, types.Entities{})
+	if err != nil {
+		t.Fatal(utils.RED + "FAIL: " + utils.RESET + err.Error())
+	}
+	event, ok := kv.Event(4)
+	check(t, ok && event.Name == "Persisted", "Created event is recovered after reopening the file")
+	check(t, ok && len(event.Jobs[2].VolunteerIds) == 1 && event.Jobs[2].VolunteerIds[0] == 3, "Updated job is recovered after reopening the file")
+	_, ok = kv.User(2)
+	check(t, ok, "Users are recovered after reopening the file")
+	_, ok = kv.Event(5)
+	check(t, !ok && kv.NbEvents() == 4, "Incomplete value is ignored after reopening the file")
+
+	check(t, kv.CreateEvent(5, newEvent("Next")) == nil, "Event is created after an incomplete value")
+	kv.Close()
+
+	kv, err = repository.OpenKV(path, types.Entities{})
+	if err != nil {
+		t.Fatal(utils.RED + "FAIL: " + utils.RESET + err.Error())
+	}
+	defer kv.Close()
+	event, ok = kv.Event(5)
+	check(t, ok && event.Name == "Next", "Event created after an incomplete value is recovered")
+}
+
+func TestKVRepositoryServer(t *testing.T) {
+	dir := t.TempDir()
+
+	first := startStorageServer(t, "8016", "8096", dir, types.KVRepository)
+	defer first.conn.Close()
+
+	if response := first.send(t, "create Stored Bar 2 lazar root"); !strings.Contains(response, "SUCCESS") {
+		t.Fatal(utils.RED + "FAIL: " + utils.RESET + "Command failed before the restart\n" + response)
+	}
+	first.send(t, "register 4 1 john root")
+
+	// Sans journal ni checkpoint, les manifestations ne peuvent être restaurées que depuis le fichier clé-valeur
+	os.Remove(filepath.Join(dir, "wal.log"))
+	os.Remove(filepath.Join(dir, "checkpoint.json"))
+
+	second := startStorageServer(t, "8017", "8097", dir, types.KVRepository)
+	defer second.conn.Close()
+
+	tests := []TestInput{
+		{Description: "Event stored in the kv repository is recovered", Input: "show 4", Expected: "Stored"},
+		{Description: "Registration stored in the kv repository is recovered", Input: "jobs 4", Expected: "john"},
+		{Description: "New events get the next id with the kv repository", Input: "create Next Bar 1 lazar root", Expected: "Event #5 Next"},
+	}
+	for _, test := range tests {
+		if response := second.send(t, test.Input); !strings.Contains(response, test.Expected) {
+			t.Error("\n" + utils.RED + "FAIL: " + utils.RESET + test.Description + utils.GREEN + "\n\nExpected to contain\n" + utils.RESET + test.Expected + utils.RED + "\nReceived\n" + utils.RESET + response)
+		} else {
+			fmt.Println(utils.GREEN + "PASS: " + utils.RESET + test.Description)
+		}
+	}
+}
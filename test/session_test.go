@@ -0,0 +1,201 @@
+// Auteurs: Jonathan Friedli, Lazar Pavicevic
+// Labo 2 SDR
+
+package test
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Lazzzer/labo1-sdr/internal/server"
+	"github.com/Lazzzer/labo1-sdr/internal/utils"
+	"github.com/Lazzzer/labo1-sdr/internal/utils/types"
+)
+
+// nbStressClients est le nombre de clients connectés simultanément pendant les tests de charge
+const nbStressClients = 300
+
+var stressConfig = types.Config{Address: "localhost:8092", Servers: map[int]string{1: "localhost:8012"}}
+var stressServer = server.NewServer(1, "8012", "8092", types.ServerConfig{Config: stressConfig, Silent: true}, server.DefaultEntities())
+
+// stressHTTPPort est le port de la passerelle HTTP du serveur des tests de charge
+const stressHTTPPort = "8093"
+
+// createdEvent permet de récupérer l'id de la manifestation créée dans la réponse d'une commande "create"
+var createdEvent = regexp.MustCompile(`Event #(\d+) (\S+) and`)
+
+// init() lance le serveur des tests de charge, qui sert aussi la passerelle HTTP
+func init() {
+	stressServer.HTTPPort = stressHTTPPort
+	go stressServer.Run()
+}
+
+// stressClient est un client des tests de charge
+type stressClient struct {
+	conn   net.Conn
+	reader *bufio.Reader
+}
+
+// dialStress ouvre la connexion d'un client au serveur des tests de charge
+func dialStress(name string) (*stressClient, error) {
+	var conn net.Conn
+	var err error
+
+	// Attend que le serveur soit prêt à recevoir des connexions
+	for i := 0; i < 200; i++ {
+		if conn, err = net.Dial("tcp", stressConfig.Address); err == nil {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := conn.Write([]byte(name + "\n")); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return &stressClient{conn: conn, reader: bufio.NewReader(conn)}, nil
+}
+
+// responseEnd est la fin de chaque réponse du serveur
+var responseEnd = "==============================================================" + utils.RESET + "\n\n"
+
+// send envoie une commande et lit la réponse complète du serveur
+func (sc *stressClient) send(input string) (string, error) {
+	if _, err := sc.conn.Write([]byte(input + "\n")); err != nil {
+		return "", err
+	}
+
+	var response strings.Builder
+	for !strings.HasSuffix(response.String(), responseEnd) {
+		line, err := sc.reader.ReadString('\n')
+		if err != nil {
+			return response.String(), err
+		}
+		response.WriteString(line)
+	}
+	return response.String(), nil
+}
+
+// runStressClients lance une fonction pour chaque client de test en même temps et retourne les erreurs rencontrées
+func runStressClients(f func(i int, client *stressClient) error) []error {
+	var wg sync.WaitGroup
+	errs := make(chan error, nbStressClients)
+	start := make(chan struct{})
+
+	for i := 0; i < nbStressClients; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			client, err := dialStress("stress-" + strconv.Itoa(i))
+			if err != nil {
+				errs <- err
+				return
+			}
+			defer client.conn.Close()
+
+			<-start
+			if err := f(i, client); err != nil {
+				errs <- fmt.Errorf("client %d: %w", i, err)
+			}
+		}(i)
+	}
+	close(start)
+	wg.Wait()
+	close(errs)
+
+	var result []error
+	for err := range errs {
+		result = append(result, err)
+	}
+	return result
+}
+
+func TestConcurrentSessions(t *testing.T) {
+	var mu sync.Mutex
+	ids := make(map[string]bool, nbStressClients)
+
+	errs := runStressClients(func(i int, client *stressClient) error {
+		name := "Stress" + strconv.Itoa(i)
+
+		// La réponse de la création doit concerner la manifestation créée par le client
+		response, err := client.send("create " + name + " Job 1 lazar root")
+		if err != nil {
+			return err
+		}
+		match := createdEvent.FindStringSubmatch(response)
+		if match == nil || match[2] != name {
+			return fmt.Errorf("expected the creation of %s, received %q", name, response)
+		}
+
+		mu.Lock()
+		duplicate := ids[match[1]]
+		ids[match[1]] = true
+		mu.Unlock()
+		if duplicate {
+			return fmt.Errorf("event #%s was created twice", match[1])
+		}
+
+		// Les commandes suivantes du client reçoivent aussi leur propre réponse
+		for j := 0; j < 3; j++ {
+			response, err = client.send("show " + match[1])
+			if err != nil {
+				return err
+			}
+			if !strings.Contains(response, name) {
+				return fmt.Errorf("expected event %s, received %q", name, response)
+			}
+		}
+		return nil
+	})
+
+	for _, err := range errs {
+		t.Error(utils.RED + "FAIL: " + utils.RESET + err.Error())
+	}
+	if len(errs) == 0 {
+		fmt.Println(utils.GREEN + "PASS: " + utils.RESET + strconv.Itoa(nbStressClients) + " concurrent clients receive the responses to their own commands")
+	}
+}
+
+func TestConcurrentQuit(t *testing.T) {
+	errs := runStressClients(func(i int, client *stressClient) error {
+		// Un client sur deux quitte pendant que les autres envoient leurs commandes
+		if i%2 == 0 {
+			if _, err := client.conn.Write([]byte("quit\n")); err != nil {
+				return err
+			}
+			if _, err := client.reader.ReadByte(); err != io.EOF {
+				return fmt.Errorf("expected the connection to be closed after quit, received %v", err)
+			}
+			return nil
+		}
+
+		for j := 0; j < 3; j++ {
+			response, err := client.send("help")
+			if err != nil {
+				return err
+			}
+			if response != utils.MESSAGE.Help {
+				return fmt.Errorf("expected the help message, received %q", response)
+			}
+		}
+		return nil
+	})
+
+	for _, err := range errs {
+		t.Error(utils.RED + "FAIL: " + utils.RESET + err.Error())
+	}
+	if len(errs) == 0 {
+		fmt.Println(utils.GREEN + "PASS: " + utils.RESET + "Quit only closes the session of the client who sent it")
+	}
+}
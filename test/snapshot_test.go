@@ -0,0 +1,119 @@
+// Auteurs: Jonathan Friedli, Lazar Pavicevic
+// Labo 2 SDR
+
+package test
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Lazzzer/labo1-sdr/internal/utils"
+	"github.com/Lazzzer/labo1-sdr/internal/utils/types"
+)
+
+// waitSnapshot attend que le serveur donné ait écrit son état pour un snapshot et retourne l'état relu depuis son
+// fichier, pendant au plus dix secondes
+func waitSnapshot(t *testing.T, path string, number int) types.Snapshot {
+	path = strings.Replace(path, "<number>", strconv.Itoa(number), 1)
+	deadline := time.Now().Add(10 * time.Second)
+	for {
+		snapshot, err := utils.GetSnapshot(path)
+		if err == nil {
+			return snapshot
+		}
+		if time.Now().After(deadline) {
+			t.Fatal(utils.RED + "FAIL: " + utils.RESET + "Server #" + strconv.Itoa(number) + " did not write snapshot " + path + ": " + err.Error())
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
+
+// inTransit indique si un serveur a enregistré une communication du type donné sur la ressource de création dans le
+// canal d'un autre serveur
+func inTransit(snapshot types.Snapshot, from int, commType types.CommunicationType) bool {
+	for _, comm := range snapshot.Channels[from] {
+		if comm.Type == commType && comm.Resource == 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// checkSnapshot affiche le résultat d'une vérification du snapshot
+func checkSnapshot(t *testing.T, ok bool, description string) {
+	if ok {
+		fmt.Println(utils.GREEN + "PASS: " + utils.RESET + description)
+	} else {
+		t.Error(utils.RED + "FAIL: " + utils.RESET + description)
+	}
+}
+
+func TestClusterSnapshot(t *testing.T) {
+	dir := t.TempDir()
+	cluster := newTestCluster(11100, 3, func(config *types.ServerConfig) {
+		tolerantFailureDetector(config)
+		config.VectorClock = true
+		config.SnapshotDir = dir
+	})
+	cluster.startAll()
+	sessions := cluster.connectAll(t)
+
+	// La requête du serveur #2 vers le serveur #1 est envoyée avant le snapshot mais n'est reçue qu'après, elle est donc
+	// en transit dans la coupe enregistrée
+	cluster.network.Hold(cluster.peer(2), cluster.peer(1))
+	created := make(chan error, 1)
+	go func() {
+		_, err := sessions[2].create("InFlight")
+		created <- err
+	}()
+	time.Sleep(200 * time.Millisecond)
+
+	response := sessions[3].send(t, utils.SNAPSHOT.Name)
+	_, after, found := strings.Cut(response, "writes its state to ")
+	path, _, _ := strings.Cut(after, "\n")
+	if !found || !strings.Contains(path, "<number>") {
+		t.Fatal(utils.RED + "FAIL: " + utils.RESET + "Server #3 should start a snapshot, received " + response)
+	}
+	fmt.Println(utils.GREEN + "PASS: " + utils.RESET + "Server #3 starts a snapshot while a create is in flight")
+	time.Sleep(200 * time.Millisecond)
+	cluster.network.Release(cluster.peer(2), cluster.peer(1))
+
+	select {
+	case err := <-created:
+		checkSnapshot(t, err == nil, "Create in flight completes after the snapshot")
+	case <-time.After(5 * time.Second):
+		t.Fatal(utils.RED + "FAIL: " + utils.RESET + "Create in flight did not complete after release")
+	}
+
+	snapshots := make(map[int]types.Snapshot)
+	for number := 1; number <= 3; number++ {
+		snapshots[number] = waitSnapshot(t, path, number)
+	}
+
+	for number := 1; number <= 3; number++ {
+		snapshot := snapshots[number]
+		server := "Server #" + strconv.Itoa(number)
+		checkSnapshot(t, snapshot.Id == snapshots[3].Id && snapshot.Server == number, server+" writes its state for the snapshot")
+		checkSnapshot(t, len(snapshot.Channels) == 2, server+" records the channel of every other server until its marker")
+		checkSnapshot(t, len(snapshot.Events) == 3, server+" does not record the event whose creation is in flight")
+	}
+	checkSnapshot(t, len(snapshots[2].Mutexes[0].Status) > 0, "Server #2 records the state of its mutex on the create resource")
+
+	checkSnapshot(t, inTransit(snapshots[1], 2, types.Request), "Server #1 records the held request of server #2 as in transit")
+
+	// La coupe est cohérente si aucun serveur ne connaît plus d'événements d'un autre serveur que ce dernier n'en a
+	// enregistrés
+	consistent := true
+	for number := 1; number <= 3; number++ {
+		for other, value := range snapshots[number].Clock {
+			if value > snapshots[other].Clock[other] {
+				consistent = false
+				t.Log("Server #" + strconv.Itoa(number) + " knows " + strconv.Itoa(value) + " events of server #" + strconv.Itoa(other) + " which recorded " + strconv.Itoa(snapshots[other].Clock[other]))
+			}
+		}
+	}
+	checkSnapshot(t, consistent, "Vector clocks of the recorded states form a consistent cut")
+}
@@ -0,0 +1,175 @@
+// Auteurs: Jonathan Friedli, Lazar Pavicevic
+// Labo 2 SDR
+
+package test
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Lazzzer/labo1-sdr/internal/server"
+	"github.com/Lazzzer/labo1-sdr/internal/utils"
+	"github.com/Lazzzer/labo1-sdr/internal/utils/types"
+)
+
+// startStorageServer lance un serveur seul dans son réseau qui enregistre ses manifestations dans le dossier donné avec
+// le repository donné et retourne la session d'un client connecté à ce serveur
+func startStorageServer(t *testing.T, port string, clientPort string, dir string, repo types.RepositoryType) *clusterSession {
+	config := types.ServerConfig{
+		Config:             types.Config{Address: "localhost:" + port, Servers: map[int]string{1: "localhost:" + port}},
+		Silent:             true,
+		CheckpointInterval: 3,
+		Repository:         repo,
+	}
+	serv := server.NewServer(1, port, clientPort, config, server.DefaultEntities())
+	serv.DataDir = dir
+	go serv.Run()
+
+	var conn net.Conn
+	var err error
+	for i := 0; i < 200; i++ {
+		if conn, err = net.Dial("tcp", "localhost:"+clientPort); err == nil {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if err != nil {
+		t.Fatal(utils.RED + "FAIL: " + utils.RESET + "Error: could not connect to server")
+	}
+	if _, err := conn.Write([]byte("storage-client\n")); err != nil {
+		t.Fatal(utils.RED + "FAIL: " + utils.RESET + "Error: could not send name to server")
+	}
+	return &clusterSession{conn: conn, reader: bufio.NewReader(conn)}
+}
+
+func TestStorageRecovery(t *testing.T) {
+	dir := t.TempDir()
+
+	first := startStorageServer(t, "8014", "8094", dir, types.MemoryRepository)
+	defer first.conn.Close()
+
+	inputs := []string{
+		"create Persisted Bar 2 Cuisine 1 lazar root",
+		"register 4 1 john root",
+		"register 4 2 valentin root",
+		"create Closed Bar 1 lazar root",
+		"close 5 lazar root",
+	}
+	for _, input := range inputs {
+		if response := first.send(t, input); !strings.Contains(response, "SUCCESS") {
+			t.Fatal(utils.RED + "FAIL: " + utils.RESET + "Command failed before the restart: " + input + "\n" + response)
+		}
+	}
+	// La lecture suivante attend la libération de la dernière commande et l'enregistrement de son estampille
+	first.send(t, "show")
+
+	if _, err := os.Stat(filepath.Join(dir, "checkpoint.json")); err != nil {
+		t.Error(utils.RED + "FAIL: " + utils.RESET + "A checkpoint should be written in the data directory")
+	} else {
+		fmt.Println(utils.GREEN + "PASS: " + utils.RESET + "A checkpoint is written in the data directory")
+	}
+
+	// Simule un crash pendant l'écriture d'une entrée du journal
+	wal, err := os.OpenFile(filepath.Join(dir, "wal.log"), os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		t.Fatal(utils.RED + "FAIL: " + utils.RESET + "The log should exist in the data directory")
+	}
+	if _, err := wal.WriteString(`{"type":"CREATE","event_id":6,"event":{"name":"Lost"`); err != nil {
+		t.Fatal(err)
+	}
+	wal.Close()
+
+	// Un nouveau serveur utilisant le même dossier des données remplace le serveur arrêté
+	second := startStorageServer(t, "8015", "8095", dir, types.MemoryRepository)
+	defer second.conn.Close()
+
+	tests := []TestInput{
+		{Description: "Created event is recovered after a restart", Input: "show 4", Expected: "Persisted"},
+		{Description: "Registrations are recovered after a restart", Input: "jobs 4", Expected: "valentin"},
+		{Description: "Closed event is recovered as closed after a restart", Input: "register 5 1 john root", Expected: utils.MESSAGE.Error.EventClosed},
+		{Description: "Incomplete log entry is ignored after a restart", Input: "show 6", Expected: utils.MESSAGE.Error.EventNotFound},
+		{Description: "New events get the next id after a restart", Input: "create Next Bar 1 lazar root", Expected: "Event #6 Next"},
+	}
+	for _, test := range tests {
+		if response := second.send(t, test.Input); !strings.Contains(response, test.Expected) {
+			t.Error("\n" + utils.RED + "FAIL: " + utils.RESET + test.Description + utils.GREEN + "\n\nExpected to contain\n" + utils.RESET + test.Expected + utils.RED + "\nReceived\n" + utils.RESET + response)
+		} else {
+			fmt.Println(utils.GREEN + "PASS: " + utils.RESET + test.Description)
+		}
+	}
+}
+
+// copyRaftState copie l'état Raft enregistré dans un dossier des données vers un nouveau dossier, que l'instance
+// isolée qui l'utilisait ne modifie plus
+func copyRaftState(t *testing.T, dir string) string {
+	copied := t.TempDir()
+	for _, name := range []string{"raft.json", "raft.log"} {
+		content, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatal(utils.RED + "FAIL: " + utils.RESET + "The Raft state should be written in " + name + " of the data directory")
+		}
+		if err := os.WriteFile(filepath.Join(copied, name), content, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return copied
+}
+
+// countLines retourne le nombre de lignes complètes d'un fichier, 0 s'il ne peut pas être lu
+func countLines(path string) int {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return 0
+	}
+	return strings.Count(string(content), "\n")
+}
+
+func TestRaftStorageRecovery(t *testing.T) {
+	cluster := newTestCluster(9400, 3, func(config *types.ServerConfig) {
+		fastFailureDetector(config)
+		config.Consistency = types.RaftConsistency
+	})
+	cluster.dataDirs = map[int]string{1: t.TempDir(), 2: t.TempDir(), 3: t.TempDir()}
+	cluster.startAll()
+	sessions := cluster.connectAll(t)
+	statuses := cluster.connectAllJSON(t)
+
+	ids := createConcurrently(t, sessions, 3)
+	checkUniqueIds(t, ids, 9, "Events created on every server before the restart get unique ids")
+	term := statuses[1].send(t, types.JSONRequest{Command: utils.STATUS.Name}).Status.Raft.Term
+
+	// Chaque entrée du journal est ajoutée sur sa propre ligne du journal enregistré
+	for number, session := range statuses {
+		path := filepath.Join(cluster.dataDirs[number], "raft.log")
+		session.waitUntil(t, types.JSONRequest{Command: utils.STATUS.Name}, func(response types.Response) bool {
+			return response.Status.Raft.LogLength == countLines(path)
+		}, "Server #"+strconv.Itoa(number)+" writes one line per log entry")
+	}
+
+	// Tous les serveurs s'arrêtent en même temps puis redémarrent avec leur état enregistré, le journal du serveur #1
+	// se terminant par une entrée incomplète écrite pendant le crash
+	for number, dir := range cluster.dataDirs {
+		cluster.kill(number)
+		cluster.dataDirs[number] = copyRaftState(t, dir)
+	}
+	file, err := os.OpenFile(filepath.Join(cluster.dataDirs[1], "raft.log"), os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	file.WriteString(`{"term":`)
+	file.Close()
+	cluster.startAll()
+	sessions = cluster.connectAll(t)
+	statuses = cluster.connectAllJSON(t)
+
+	waitConverged(t, sessions, 12, "Committed entries are recovered after the whole cluster restarts")
+	status := statuses[1].send(t, types.JSONRequest{Command: utils.STATUS.Name}).Status
+	check(t, status.Raft.Term > term, "Term keeps increasing after the whole cluster restarts")
+}
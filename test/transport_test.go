@@ -0,0 +1,137 @@
+// Auteurs: Jonathan Friedli, Lazar Pavicevic
+// Labo 2 SDR
+
+package test
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Lazzzer/labo1-sdr/internal/server"
+	"github.com/Lazzzer/labo1-sdr/internal/utils"
+)
+
+// readLines lit les lignes reçues sur une connexion et les transmet dans un channel, fermé à la fin de la connexion
+func readLines(conn io.Reader) <-chan string {
+	lines := make(chan string)
+	go func() {
+		defer close(lines)
+		reader := bufio.NewReader(conn)
+		for {
+			line, err := reader.ReadString('\n')
+			if err != nil {
+				return
+			}
+			lines <- line
+		}
+	}()
+	return lines
+}
+
+// expectLine vérifie que la prochaine ligne reçue est celle attendue
+func expectLine(t *testing.T, lines <-chan string, expected string) {
+	select {
+	case line := <-lines:
+		if line != expected {
+			t.Fatal(utils.RED + "FAIL: " + utils.RESET + "Expected " + expected + " but received " + line)
+		}
+	case <-time.After(time.Second):
+		t.Fatal(utils.RED + "FAIL: " + utils.RESET + "Expected " + expected + " but received nothing")
+	}
+}
+
+func TestMemoryTransport(t *testing.T) {
+	network := server.NewMemoryNetwork()
+	a := network.Transport("a:1")
+	b := network.Transport("b:1")
+
+	if _, err := a.Dial("b:1"); err == nil {
+		t.Fatal(utils.RED + "FAIL: " + utils.RESET + "Dial to an address without listener should be refused")
+	}
+
+	listener, err := b.Listen("b:1")
+	if err != nil {
+		t.Fatal(utils.RED + "FAIL: " + utils.RESET + err.Error())
+	}
+	if _, err := b.Listen("b:1"); err == nil {
+		t.Fatal(utils.RED + "FAIL: " + utils.RESET + "Listen on an address already in use should fail")
+	}
+
+	accepted := make(chan net.Conn, 1)
+	go func() {
+		if conn, err := listener.Accept(); err == nil {
+			accepted <- conn
+		}
+	}()
+
+	conn, err := a.Dial("b:1")
+	if err != nil {
+		t.Fatal(utils.RED + "FAIL: " + utils.RESET + err.Error())
+	}
+	lines := readLines(<-accepted)
+
+	// Les données sont livrées dans l'ordre de leur envoi
+	conn.Write([]byte("first\n"))
+	conn.Write([]byte("second\n"))
+	expectLine(t, lines, "first\n")
+	expectLine(t, lines, "second\n")
+	fmt.Println(utils.GREEN + "PASS: " + utils.RESET + "Data is delivered in order")
+
+	// Les données retenues ne sont livrées qu'après Release, avant les données envoyées ensuite
+	network.Hold("a:1", "b:1")
+	conn.Write([]byte("held\n"))
+	select {
+	case line := <-lines:
+		t.Fatal(utils.RED + "FAIL: " + utils.RESET + "Held data should not be delivered, received " + line)
+	case <-time.After(50 * time.Millisecond):
+	}
+	network.Release("a:1", "b:1")
+	conn.Write([]byte("after\n"))
+	expectLine(t, lines, "held\n")
+	expectLine(t, lines, "after\n")
+	fmt.Println(utils.GREEN + "PASS: " + utils.RESET + "Held data is delivered in order after release")
+
+	// Les écritures correspondant au filtre sont perdues jusqu'à son retrait, les autres étant livrées
+	network.Drop("a:1", "b:1", func(data []byte) bool {
+		return strings.HasPrefix(string(data), "drop")
+	})
+	conn.Write([]byte("dropped\n"))
+	conn.Write([]byte("kept\n"))
+	expectLine(t, lines, "kept\n")
+	network.Drop("a:1", "b:1", nil)
+	conn.Write([]byte("dropped again\n"))
+	expectLine(t, lines, "dropped again\n")
+	fmt.Println(utils.GREEN + "PASS: " + utils.RESET + "Data matching the drop filter is lost")
+
+	// Un serveur isolé n'accepte plus de connexions et ses connexions sont fermées
+	network.Isolate("b:1")
+	if _, err := listener.Accept(); err == nil {
+		t.Fatal(utils.RED + "FAIL: " + utils.RESET + "Isolated server should not accept connections")
+	}
+	if _, err := conn.Write([]byte("lost\n")); err == nil {
+		t.Fatal(utils.RED + "FAIL: " + utils.RESET + "Write to an isolated server should fail")
+	}
+	if _, ok := <-lines; ok {
+		t.Fatal(utils.RED + "FAIL: " + utils.RESET + "Connection of an isolated server should be closed")
+	}
+	fmt.Println(utils.GREEN + "PASS: " + utils.RESET + "Isolated server is disconnected")
+
+	// La couche réseau d'un serveur isolé ne peut plus être utilisée, contrairement à celle de sa nouvelle instance
+	if _, err := b.Dial("a:1"); err == nil {
+		t.Fatal(utils.RED + "FAIL: " + utils.RESET + "Isolated server should not dial other servers")
+	}
+	if _, err := b.Listen("b:1"); err == nil {
+		t.Fatal(utils.RED + "FAIL: " + utils.RESET + "Isolated server should not listen again")
+	}
+	restarted, err := network.Transport("b:1").Listen("b:1")
+	if err != nil {
+		t.Fatal(utils.RED + "FAIL: " + utils.RESET + "Restarted server should listen on its address: " + err.Error())
+	}
+	restarted.Close()
+	fmt.Println(utils.GREEN + "PASS: " + utils.RESET + "Restarted server gets a new transport")
+}
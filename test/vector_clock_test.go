@@ -0,0 +1,147 @@
+// Auteurs: Jonathan Friedli, Lazar Pavicevic
+// Labo 2 SDR
+
+package test
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Lazzzer/labo1-sdr/internal/utils"
+	"github.com/Lazzzer/labo1-sdr/internal/utils/types"
+)
+
+// register inscrit un utilisateur à un job d'une manifestation, le test échouant si l'inscription est refusée
+func (cs *clusterSession) register(t *testing.T, idEvent int, idJob int, username string) {
+	description := username + " registers to job #" + strconv.Itoa(idJob) + " of event #" + strconv.Itoa(idEvent) + " on server #" + strconv.Itoa(cs.number)
+	if response := cs.send(t, "register "+strconv.Itoa(idEvent)+" "+strconv.Itoa(idJob)+" "+username+" root"); strings.Contains(response, "User registered") {
+		fmt.Println(utils.GREEN + "PASS: " + utils.RESET + description)
+	} else {
+		t.Error(utils.RED + "FAIL: " + utils.RESET + description + "\nReceived: " + response)
+	}
+}
+
+// statusLine retourne la ligne de la commande "status" du serveur de la session qui commence par le préfixe donné
+func (cs *clusterSession) statusLine(t *testing.T, prefix string) string {
+	for _, line := range strings.Split(cs.send(t, utils.STATUS.Name), "\n") {
+		if line = strings.TrimPrefix(line, utils.RESET); strings.HasPrefix(line, prefix) {
+			return line
+		}
+	}
+	return ""
+}
+
+// conflicts retourne le nombre de modifications concurrentes détectées par le serveur
+func (cs *clusterSession) conflicts(t *testing.T) int {
+	nbConflicts, _ := strconv.Atoi(strings.TrimPrefix(cs.statusLine(t, "Conflicts detected: "), "Conflicts detected: "))
+	return nbConflicts
+}
+
+func TestVectorClockConflicts(t *testing.T) {
+	cluster := newTestCluster(10600, 3, func(config *types.ServerConfig) {
+		fastFailureDetector(config)
+		config.VectorClock = true
+	})
+	cluster.startAll()
+	sessions := cluster.connectAll(t)
+
+	// Des inscriptions successives sur différents serveurs sont ordonnées par la section critique
+	sessions[1].register(t, 3, 1, "jonathan")
+	sessions[3].register(t, 3, 1, "valentin")
+	waitConverged(t, sessions, 3, "Strong reads of all servers converge after successive registrations")
+	for number, session := range sessions {
+		if session.conflicts(t) == 0 {
+			fmt.Println(utils.GREEN + "PASS: " + utils.RESET + "Server #" + strconv.Itoa(number) + " detects no conflict between successive registrations")
+		} else {
+			t.Error(utils.RED + "FAIL: " + utils.RESET + "Server #" + strconv.Itoa(number) + " detects no conflict between successive registrations")
+		}
+	}
+
+	// Le serveur #3 est séparé des deux autres, chaque côté de la coupure modifiant la même manifestation
+	for _, number := range []int{1, 2} {
+		cluster.network.Hold(cluster.peer(3), cluster.peer(number))
+		cluster.network.Hold(cluster.peer(number), cluster.peer(3))
+	}
+	sessions[1].waitPeer(t, 3, types.PeerDown, "Server #1 considers server #3 down during the partition")
+	sessions[3].waitPeer(t, 1, types.PeerDown, "Server #3 considers server #1 down during the partition")
+	sessions[3].waitPeer(t, 2, types.PeerDown, "Server #3 considers server #2 down during the partition")
+	sessions[1].register(t, 3, 1, "john")
+	sessions[3].register(t, 3, 1, "lazar")
+
+	for _, number := range []int{1, 2} {
+		cluster.network.Release(cluster.peer(3), cluster.peer(number))
+		cluster.network.Release(cluster.peer(number), cluster.peer(3))
+	}
+	sessions[1].waitPeer(t, 3, types.PeerAlive, "Server #1 readmits server #3 after the partition")
+	sessions[3].waitPeer(t, 1, types.PeerAlive, "Server #3 reconnects to server #1 after the partition")
+
+	// Le premier serveur qui reçoit la version de l'autre côté détecte le conflit, puis conserve ou transmet la version
+	// ayant la plus grande estampille avec une horloge qui n'est plus concurrente
+	detected := false
+	for deadline := time.Now().Add(10 * time.Second); !detected && time.Now().Before(deadline); time.Sleep(50 * time.Millisecond) {
+		nbConflicts := 0
+		for _, session := range sessions {
+			nbConflicts += session.conflicts(t)
+		}
+		detected = nbConflicts > 0
+	}
+	if detected {
+		fmt.Println(utils.GREEN + "PASS: " + utils.RESET + "Concurrent registrations made during the partition are detected after it heals")
+	} else {
+		t.Error(utils.RED + "FAIL: " + utils.RESET + "Concurrent registrations made during the partition are detected after it heals")
+	}
+	waitConverged(t, sessions, 3, "Strong reads of all servers converge after the partition")
+}
+
+func TestVectorClockStatus(t *testing.T) {
+	cluster := newTestCluster(11200, 3, func(config *types.ServerConfig) {
+		tolerantFailureDetector(config)
+		config.VectorClock = true
+	})
+	cluster.startAll()
+	sessions := cluster.connectAll(t)
+
+	sessions[1].register(t, 3, 1, "jonathan")
+	output := sessions[1].send(t, utils.STATUS.Name)
+	modified := sessions[1].statusLine(t, "EVENT #3\tstamp ")
+	tests := []struct {
+		Description string
+		Expected    string
+	}{
+		{Description: "Status of server #1 shows the server number", Expected: "Server: S1"},
+		{Description: "Status of server #1 shows its vector clock", Expected: "Vector clock: [S1: "},
+		{Description: "Status of server #1 shows the number of conflicts", Expected: "Conflicts detected: 0"},
+		{Description: "Status of server #1 shows the clock of the registration on event #3", Expected: "EVENT #3\tstamp "},
+	}
+	for _, test := range tests {
+		if !strings.Contains(output, test.Expected) {
+			t.Error("\n" + utils.RED + "FAIL: " + utils.RESET + test.Description + utils.GREEN + "\n\nExpected to contain\n" + utils.RESET + test.Expected + utils.RED + "\nReceived\n" + utils.RESET + output)
+		} else {
+			fmt.Println(utils.GREEN + "PASS: " + utils.RESET + test.Description)
+		}
+	}
+	if !strings.Contains(modified, "\t[S1: ") {
+		t.Fatal(utils.RED + "FAIL: " + utils.RESET + "Registration on event #3 should have a clock, received " + modified)
+	}
+	if line := sessions[1].statusLine(t, "EVENT #1\tstamp "); strings.Contains(line, "[") {
+		t.Error(utils.RED + "FAIL: " + utils.RESET + "Event #1 without modification has no clock\nReceived: " + line)
+	} else {
+		fmt.Println(utils.GREEN + "PASS: " + utils.RESET + "Event #1 without modification has no clock")
+	}
+
+	// Avec Lamport, le REL transporte la modification et son horloge aux autres serveurs
+	var received string
+	for deadline := time.Now().Add(10 * time.Second); time.Now().Before(deadline); time.Sleep(50 * time.Millisecond) {
+		if received = sessions[2].statusLine(t, "EVENT #3\tstamp "); received == modified {
+			break
+		}
+	}
+	if received == modified {
+		fmt.Println(utils.GREEN + "PASS: " + utils.RESET + "Server #2 receives the stamp and clock of the registration on event #3")
+	} else {
+		t.Error(utils.RED + "FAIL: " + utils.RESET + "Server #2 receives the stamp and clock of the registration on event #3\nExpected: " + modified + "\nReceived: " + received)
+	}
+}
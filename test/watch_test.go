@@ -0,0 +1,241 @@
+// Auteurs: Jonathan Friedli, Lazar Pavicevic
+// Labo 2 SDR
+
+package test
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Lazzzer/labo1-sdr/internal/utils"
+	"github.com/Lazzzer/labo1-sdr/internal/utils/types"
+)
+
+// waitLine lit les lignes envoyées par le serveur jusqu'à celle contenant tous les textes attendus
+func waitLine(conn net.Conn, reader *bufio.Reader, expected ...string) (string, error) {
+	if err := conn.SetReadDeadline(time.Now().Add(5 * time.Second)); err != nil {
+		return "", err
+	}
+	defer conn.SetReadDeadline(time.Time{})
+
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return line, err
+		}
+		found := true
+		for _, text := range expected {
+			found = found && strings.Contains(line, text)
+		}
+		if found {
+			return line, nil
+		}
+	}
+}
+
+func TestWatch(t *testing.T) {
+	watcher, err := dialStress("watcher")
+	if err != nil {
+		t.Fatal(utils.RED + "FAIL: " + utils.RESET + "Error: could not connect to server")
+	}
+	defer watcher.conn.Close()
+	organizer, err := dialStress("organizer")
+	if err != nil {
+		t.Fatal(utils.RED + "FAIL: " + utils.RESET + "Error: could not connect to server")
+	}
+	defer organizer.conn.Close()
+
+	tests := []TestInput{
+		{Description: "Watch command without argument returns an error", Input: "watch", Expected: utils.MESSAGE.Error.InvalidNbArgs},
+		{Description: "Watch command with a word other than all returns an error", Input: "watch some", Expected: utils.MESSAGE.Error.MustBeInteger},
+		{Description: "Watch command on an event not created yet succeeds", Input: "watch 999", Expected: utils.MESSAGE.WrapSuccess("Watching event #999.\n")},
+	}
+	for _, test := range tests {
+		if response, err := watcher.send(test.Input); err != nil || response != test.Expected {
+			t.Error("\n" + utils.RED + "FAIL: " + utils.RESET + test.Description + utils.GREEN + "\n\nExpected\n" + utils.RESET + test.Expected + utils.RED + "\nReceived\n" + utils.RESET + response)
+		} else {
+			fmt.Println(utils.GREEN + "PASS: " + utils.RESET + test.Description)
+		}
+	}
+
+	created, err := organizer.send("create Watched Bar 2 lazar root")
+	match := createdEvent.FindStringSubmatch(created)
+	if err != nil || match == nil {
+		t.Fatal(utils.RED + "FAIL: " + utils.RESET + "Create failed: " + created)
+	}
+	idEvent := match[1]
+
+	if response, err := watcher.send("watch " + idEvent); err != nil || !strings.Contains(response, "Watching event #"+idEvent) {
+		t.Fatal(utils.RED + "FAIL: " + utils.RESET + "Watch command on an existing event failed: " + response)
+	}
+	fmt.Println(utils.GREEN + "PASS: " + utils.RESET + "Watch command on an existing event succeeds")
+
+	// L'inscription d'un autre client est envoyée au client sans qu'il ait envoyé de commande
+	if response, err := organizer.send("register " + idEvent + " 1 john root"); err != nil || !strings.Contains(response, "SUCCESS") {
+		t.Fatal(utils.RED + "FAIL: " + utils.RESET + "Register failed: " + response)
+	}
+	if line, err := waitLine(watcher.conn, watcher.reader, "#"+idEvent, "Watched", "(1/2)"); err != nil {
+		t.Error(utils.RED + "FAIL: " + utils.RESET + "Registration should be notified to the watcher, received " + line)
+	} else {
+		fmt.Println(utils.GREEN + "PASS: " + utils.RESET + "Registration is notified to the watcher with the job fill count")
+	}
+
+	if response, err := organizer.send("close " + idEvent + " lazar root"); err != nil || !strings.Contains(response, "SUCCESS") {
+		t.Fatal(utils.RED + "FAIL: " + utils.RESET + "Close failed: " + response)
+	}
+	if line, err := waitLine(watcher.conn, watcher.reader, "#"+idEvent, "closed"); err != nil {
+		t.Error(utils.RED + "FAIL: " + utils.RESET + "Closing should be notified to the watcher, received " + line)
+	} else {
+		fmt.Println(utils.GREEN + "PASS: " + utils.RESET + "Closing is notified to the watcher")
+	}
+
+	// Les manifestations qui ne sont pas suivies ne sont pas notifiées
+	if response, err := organizer.send("create Unwatched Bar 2 lazar root"); err != nil || !strings.Contains(response, "SUCCESS") {
+		t.Fatal(utils.RED + "FAIL: " + utils.RESET + "Create failed: " + response)
+	}
+	if response, err := watcher.send("show"); err != nil || strings.Contains(response, "🔔") {
+		t.Error(utils.RED + "FAIL: " + utils.RESET + "Unwatched events should not be notified, received " + response)
+	} else {
+		fmt.Println(utils.GREEN + "PASS: " + utils.RESET + "Unwatched events are not notified")
+	}
+}
+
+func TestWatchFramed(t *testing.T) {
+	conn, err := net.Dial("tcp", stressConfig.Address)
+	if err != nil {
+		t.Fatal(utils.RED + "FAIL: " + utils.RESET + "Error: could not connect to server")
+	}
+	defer conn.Close()
+	if _, err := conn.Write([]byte("framed-watcher " + utils.FRAMED_PROTOCOL + "\n")); err != nil {
+		t.Fatal(utils.RED + "FAIL: " + utils.RESET + "Error: could not send name to server")
+	}
+	if ack, err := utils.ReadFrame(conn); err != nil || ack.Body != utils.FRAMED_PROTOCOL {
+		t.Fatal(utils.RED + "FAIL: " + utils.RESET + "Server did not confirm the framed protocol")
+	}
+
+	if err := utils.WriteFrame(conn, types.Frame{Id: 1, Body: "watch all"}); err != nil {
+		t.Fatal(utils.RED + "FAIL: " + utils.RESET + "Error: could not write to server")
+	}
+	if frame, err := utils.ReadFrame(conn); err != nil || frame.Id != 1 || frame.Status != types.StatusOK || !strings.Contains(frame.Body, "Watching all events.") {
+		t.Fatal(utils.RED + "FAIL: " + utils.RESET + "Watch all command should be confirmed")
+	}
+	fmt.Println(utils.GREEN + "PASS: " + utils.RESET + "Watch all command is confirmed")
+
+	organizer, err := dialStress("framed-organizer")
+	if err != nil {
+		t.Fatal(utils.RED + "FAIL: " + utils.RESET + "Error: could not connect to server")
+	}
+	defer organizer.conn.Close()
+	if response, err := organizer.send("create FramedWatch Bar 2 lazar root"); err != nil || !strings.Contains(response, "SUCCESS") {
+		t.Fatal(utils.RED + "FAIL: " + utils.RESET + "Create failed: " + response)
+	}
+
+	if err := conn.SetReadDeadline(time.Now().Add(5 * time.Second)); err != nil {
+		t.Fatal(err)
+	}
+	if frame, err := utils.ReadFrame(conn); err != nil || frame.Id != 0 || frame.Status != types.StatusNotification || !strings.Contains(frame.Body, "FramedWatch") || !strings.Contains(frame.Body, "created") {
+		t.Error(utils.RED + "FAIL: " + utils.RESET + "Created event should be notified in a notification frame")
+	} else {
+		fmt.Println(utils.GREEN + "PASS: " + utils.RESET + "Created event is notified in a notification frame")
+	}
+}
+
+func TestWatchJSON(t *testing.T) {
+	watcher, err := dialStress("json-watcher " + utils.JSON_MODE)
+	if err != nil {
+		t.Fatal(utils.RED + "FAIL: " + utils.RESET + "Error: could not connect to server")
+	}
+	defer watcher.conn.Close()
+
+	response, err := watcher.sendJSON(`{"command":"create","args":["JsonWatch","Bar","2"],"username":"lazar","password":"root"}`)
+	if err != nil || !response.Ok || response.Event == nil {
+		t.Fatal(utils.RED + "FAIL: " + utils.RESET + "Create failed")
+	}
+	idEvent := strconv.Itoa(response.Event.Id)
+
+	response, err = watcher.sendJSON(`{"command":"watch","args":["` + idEvent + `"]}`)
+	if err != nil || !response.Ok || response.Command != utils.WATCH.Name || response.Event == nil || response.Event.Name != "JsonWatch" {
+		t.Fatal(utils.RED + "FAIL: " + utils.RESET + "Watch command should return the watched event")
+	}
+	fmt.Println(utils.GREEN + "PASS: " + utils.RESET + "Watch command returns the watched event")
+
+	// L'inscription est faite par un autre client, la notification pouvant précéder la réponse de la commande
+	volunteer, err := dialStress("json-volunteer " + utils.JSON_MODE)
+	if err != nil {
+		t.Fatal(utils.RED + "FAIL: " + utils.RESET + "Error: could not connect to server")
+	}
+	defer volunteer.conn.Close()
+	response, err = volunteer.sendJSON(`{"command":"register","args":["` + idEvent + `","1"],"username":"john","password":"root"}`)
+	if err != nil || !response.Ok {
+		t.Fatal(utils.RED + "FAIL: " + utils.RESET + "Register failed")
+	}
+
+	var notification types.Notification
+	line, err := waitLine(watcher.conn, watcher.reader, `"notification"`)
+	if err != nil || json.Unmarshal([]byte(line), &notification) != nil || notification.Type != types.NotifyRegistered || notification.Event.Jobs[0].Volunteers[0] != "john" {
+		t.Error(utils.RED + "FAIL: " + utils.RESET + "Registration should be notified as JSON, received " + line)
+	} else {
+		fmt.Println(utils.GREEN + "PASS: " + utils.RESET + "Registration is notified as JSON")
+	}
+}
+
+func TestWatchCluster(t *testing.T) {
+	watcher := connect(t, 3)
+	defer watcher.conn.Close()
+	watcher.waitFor(t, "leader", "Leader: S"+strconv.Itoa(clusterSize), "Server #3 knows the leader of the cluster")
+	if response := watcher.send(t, "watch all"); !strings.Contains(response, "Watching all events.") {
+		t.Fatal(utils.RED + "FAIL: " + utils.RESET + "Watch all command failed: " + response)
+	}
+
+	session := connect(t, 1)
+	defer session.conn.Close()
+	if created := session.send(t, "create ClusterWatch Bar 2 lazar root"); !strings.Contains(created, "SUCCESS") {
+		t.Fatal(utils.RED + "FAIL: " + utils.RESET + "Create on server #1 failed: " + created)
+	}
+
+	if line, err := waitLine(watcher.conn, watcher.reader, "ClusterWatch", "created"); err != nil {
+		t.Error(utils.RED + "FAIL: " + utils.RESET + "Event created on server #1 should be notified to a watcher of server #3, received " + line)
+	} else {
+		fmt.Println(utils.GREEN + "PASS: " + utils.RESET + "Event created on server #1 is notified to a watcher of server #3")
+	}
+}
+
+func TestWatchTokenAlgorithm(t *testing.T) {
+	cluster := newTestCluster(9300, 3, func(config *types.ServerConfig) {
+		config.Mutex = types.SuzukiKasami
+	})
+	cluster.startAll()
+	sessions := cluster.connectAllJSON(t)
+	for _, session := range sessions {
+		session.waitLeader(t, 3, "Server knows the leader of the cluster")
+	}
+
+	watcher := cluster.connectJSON(t, 3)
+	defer watcher.conn.Close()
+	if response := watcher.send(t, types.JSONRequest{Command: utils.WATCH.Name, Args: []string{"all"}}); !response.Ok {
+		t.Fatal(utils.RED + "FAIL: " + utils.RESET + "Watch all command failed: " + response.Message)
+	}
+
+	// Le serveur #1 détient le jeton de création, le serveur #3 ne reçoit pas la manifestation créée
+	if _, err := sessions[1].create("TokenWatch"); err != nil {
+		t.Fatal(utils.RED + "FAIL: " + utils.RESET + err.Error())
+	}
+	watcher.conn.SetReadDeadline(time.Now().Add(500 * time.Millisecond))
+	line, err := watcher.reader.ReadString('\n')
+	watcher.conn.SetReadDeadline(time.Time{})
+	check(t, err != nil && !strings.Contains(line, "TokenWatch"), "Event created on server #1 is not notified before server #3 receives it")
+
+	// La lecture forte fait passer le jeton et ses manifestations par le serveur #3
+	sessions[3].send(t, types.JSONRequest{Command: utils.SHOW.Name, Strong: true})
+	if line, err := waitLine(watcher.conn, watcher.reader, "TokenWatch", `"created"`); err != nil {
+		t.Error(utils.RED + "FAIL: " + utils.RESET + "Event created on server #1 should be notified once server #3 receives it, received " + line)
+	} else {
+		fmt.Println(utils.GREEN + "PASS: " + utils.RESET + "Event created on server #1 is notified once received by a strong read on server #3")
+	}
+}
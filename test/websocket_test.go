@@ -0,0 +1,200 @@
+// Auteurs: Jonathan Friedli, Lazar Pavicevic
+// Labo 2 SDR
+
+package test
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Lazzzer/labo1-sdr/internal/utils"
+	"github.com/Lazzzer/labo1-sdr/internal/utils/types"
+)
+
+// wsClient est un client WebSocket connecté à la passerelle d'un serveur
+type wsClient struct {
+	conn   net.Conn
+	reader *bufio.Reader
+}
+
+// dialWS ouvre une connexion WebSocket sur la passerelle HTTP écoutant sur le port donné
+func dialWS(port string) (*wsClient, error) {
+	var conn net.Conn
+	var err error
+
+	// Attend que la passerelle soit prête à recevoir des connexions
+	for i := 0; i < 200; i++ {
+		if conn, err = net.Dial("tcp", "localhost:"+port); err == nil {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	key := "dGhlIHNhbXBsZSBub25jZQ=="
+	handshake := "GET /ws?name=ws-client HTTP/1.1\r\nHost: localhost\r\nUpgrade: websocket\r\nConnection: Upgrade\r\nSec-WebSocket-Key: " + key + "\r\nSec-WebSocket-Version: 13\r\n\r\n"
+	if _, err := conn.Write([]byte(handshake)); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	reader := bufio.NewReader(conn)
+	res, err := http.ReadResponse(reader, nil)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	if res.StatusCode != http.StatusSwitchingProtocols || res.Header.Get("Sec-WebSocket-Accept") != utils.WSAcceptKey(key) {
+		conn.Close()
+		return nil, fmt.Errorf("unexpected handshake response %s", res.Status)
+	}
+	return &wsClient{conn: conn, reader: reader}, nil
+}
+
+// send envoie un message texte masqué au serveur
+func (ws *wsClient) send(message string) error {
+	return utils.WriteWSFrame(ws.conn, types.WSFrame{Fin: true, Opcode: types.WSText, Payload: []byte(message)}, true)
+}
+
+// read lit la prochaine frame du serveur
+func (ws *wsClient) read() (types.WSFrame, error) {
+	if err := ws.conn.SetReadDeadline(time.Now().Add(5 * time.Second)); err != nil {
+		return types.WSFrame{}, err
+	}
+	return utils.ReadWSFrame(ws.reader)
+}
+
+// waitNotification lit les messages du serveur jusqu'à la notification de la modification donnée d'une manifestation
+func (ws *wsClient) waitNotification(change types.NotificationType, name string) (types.Notification, error) {
+	for {
+		frame, err := ws.read()
+		if err != nil {
+			return types.Notification{}, err
+		}
+		var notification types.Notification
+		if json.Unmarshal(frame.Payload, &notification) == nil && notification.Type == change && notification.Event.Name == name {
+			return notification, nil
+		}
+	}
+}
+
+func TestWSFrameEncoding(t *testing.T) {
+	for _, size := range []int{0, 125, 126, 70000} {
+		var buf bytes.Buffer
+		sent := types.WSFrame{Fin: true, Opcode: types.WSText, Payload: bytes.Repeat([]byte("a"), size)}
+		if err := utils.WriteWSFrame(&buf, sent, true); err != nil {
+			t.Fatal(utils.RED + "FAIL: " + utils.RESET + err.Error())
+		}
+		received, err := utils.ReadWSFrame(&buf)
+		if err != nil || !received.Fin || received.Opcode != sent.Opcode || !bytes.Equal(received.Payload, sent.Payload) {
+			t.Errorf(utils.RED+"FAIL: "+utils.RESET+"Masked frame of %d bytes was not decoded as it was encoded", size)
+		} else {
+			fmt.Printf(utils.GREEN+"PASS: "+utils.RESET+"Masked frame of %d bytes is decoded as it was encoded\n", size)
+		}
+	}
+
+	// Exemple de la section 1.3 de la RFC 6455
+	if utils.WSAcceptKey("dGhlIHNhbXBsZSBub25jZQ==") != "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=" {
+		t.Error(utils.RED + "FAIL: " + utils.RESET + "Accept key does not match the RFC example")
+	} else {
+		fmt.Println(utils.GREEN + "PASS: " + utils.RESET + "Accept key matches the RFC example")
+	}
+}
+
+func TestWebSocket(t *testing.T) {
+	client, err := dialWS(stressHTTPPort)
+	if err != nil {
+		t.Fatal(utils.RED + "FAIL: " + utils.RESET + "Error: could not open a websocket: " + err.Error())
+	}
+	defer client.conn.Close()
+	fmt.Println(utils.GREEN + "PASS: " + utils.RESET + "Websocket handshake succeeds")
+
+	if err := utils.WriteWSFrame(client.conn, types.WSFrame{Fin: true, Opcode: types.WSPing, Payload: []byte("ping")}, true); err != nil {
+		t.Fatal(utils.RED + "FAIL: " + utils.RESET + "Error: could not write to server")
+	}
+	if frame, err := client.read(); err != nil || frame.Opcode != types.WSPong || string(frame.Payload) != "ping" {
+		t.Error(utils.RED + "FAIL: " + utils.RESET + "Ping should be answered by a pong")
+	} else {
+		fmt.Println(utils.GREEN + "PASS: " + utils.RESET + "Ping is answered by a pong")
+	}
+
+	// La réponse et la notification de la création arrivent dans un ordre quelconque
+	if err := client.send(`{"id":"ws","command":"create","args":["Socket","Job","2"],"username":"lazar","password":"root"}`); err != nil {
+		t.Fatal(utils.RED + "FAIL: " + utils.RESET + "Error: could not write to server")
+	}
+	var response types.Response
+	var notification types.Notification
+	for i := 0; i < 2; i++ {
+		frame, err := client.read()
+		if err != nil {
+			t.Fatal(utils.RED + "FAIL: " + utils.RESET + "Error: could not read from connection")
+		}
+		if strings.Contains(string(frame.Payload), `"notification"`) {
+			_ = json.Unmarshal(frame.Payload, &notification)
+		} else {
+			_ = json.Unmarshal(frame.Payload, &response)
+		}
+	}
+	if !response.Ok || response.Id != "ws" || response.Event == nil {
+		t.Fatal(utils.RED + "FAIL: " + utils.RESET + "Create command over websocket should be answered")
+	}
+	fmt.Println(utils.GREEN + "PASS: " + utils.RESET + "Create command over websocket is answered")
+	if notification.Type != types.NotifyCreated || notification.Event.Id != response.Event.Id {
+		t.Error(utils.RED + "FAIL: " + utils.RESET + "Created event should be notified")
+	} else {
+		fmt.Println(utils.GREEN + "PASS: " + utils.RESET + "Created event is notified")
+	}
+
+	// Une inscription par la passerelle HTTP est notifiée aux clients WebSocket
+	route := TestRoute{Method: http.MethodPost, Path: fmt.Sprintf("/events/%d/jobs/%d/volunteers", response.Event.Id, response.Event.Jobs[0].Id), Username: "john"}
+	if status, _, err := sendHTTP(route, "root"); err != nil || status != http.StatusOK {
+		t.Fatal(utils.RED + "FAIL: " + utils.RESET + "Register over HTTP failed")
+	}
+	if notification, err := client.waitNotification(types.NotifyRegistered, "Socket"); err != nil || len(notification.Event.Jobs[0].Volunteers) != 1 || notification.Event.Jobs[0].NbVolunteers != 2 {
+		t.Error(utils.RED + "FAIL: " + utils.RESET + "Registration should be notified with the job fill count")
+	} else {
+		fmt.Println(utils.GREEN + "PASS: " + utils.RESET + "Registration is notified with the job fill count")
+	}
+
+	if err := client.send(`{"command":"quit"}`); err != nil {
+		t.Fatal(utils.RED + "FAIL: " + utils.RESET + "Error: could not write to server")
+	}
+	frame, err := client.read()
+	if err != nil || json.Unmarshal(frame.Payload, &response) != nil || !response.Ok || response.Command != utils.QUIT.Name {
+		t.Fatal(utils.RED + "FAIL: " + utils.RESET + "Quit command should be confirmed")
+	}
+	if frame, err := client.read(); err != nil || frame.Opcode != types.WSClose {
+		t.Error(utils.RED + "FAIL: " + utils.RESET + "Websocket should be closed after quit")
+	} else {
+		fmt.Println(utils.GREEN + "PASS: " + utils.RESET + "Quit command is confirmed and closes the websocket")
+	}
+}
+
+func TestWebSocketClusterNotification(t *testing.T) {
+	client, err := dialWS(clusterHTTPPort(2))
+	if err != nil {
+		t.Fatal(utils.RED + "FAIL: " + utils.RESET + "Error: could not open a websocket: " + err.Error())
+	}
+	defer client.conn.Close()
+
+	session := connect(t, 1)
+	defer session.conn.Close()
+	if created := session.send(t, "create Remote Job 1 lazar root"); !strings.Contains(created, "SUCCESS") {
+		t.Fatal(utils.RED + "FAIL: " + utils.RESET + "Create on server #1 failed: " + created)
+	}
+
+	if _, err := client.waitNotification(types.NotifyCreated, "Remote"); err != nil {
+		t.Error(utils.RED + "FAIL: " + utils.RESET + "Event created on server #1 should be notified on server #2")
+	} else {
+		fmt.Println(utils.GREEN + "PASS: " + utils.RESET + "Event created on server #1 is notified on server #2")
+	}
+}